@@ -2,14 +2,19 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
 	"go-test/internal/config"
+	"go-test/internal/filelock"
+	"go-test/internal/httpreplay"
+	"go-test/internal/prompts"
 	"go-test/internal/services"
 	"go-test/pkg/logger"
 )
@@ -45,6 +50,8 @@ func main() {
 		})
 	}
 
+	defer services.duplicateChecker.Close()
+
 	// Create context for graceful shutdown
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -58,13 +65,26 @@ func main() {
 		// Continue anyway, some tools might work
 	}
 
-	// Run one cycle
+	// Settle publishes an earlier run left unfinished before posting anything new
+	if err := services.orchestrator.RecoverPendingPublishes(cfg.ReservationTimeout); err != nil {
+		exitIfLocked(err, appLogger)
+		appLogger.Fatal("Failed to recover pending publishes", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	// Run one cycle. In WebSub mode a failed cycle, e.g. during a feed
+	// outage, must not keep the long-running subscriber from starting.
 	appLogger.Info("🎯 Starting Anime Api autonomous cycle...")
-	if err := services.orchestrator.ExecuteCycle(ctx); err != nil {
+	cycleErr := services.orchestrator.ExecuteCycle(ctx)
+	if cycleErr != nil {
+		exitIfLocked(cycleErr, appLogger)
 		appLogger.Error("Autonomous cycle failed", map[string]interface{}{
-			"error": err.Error(),
+			"error": cycleErr.Error(),
 		})
-		os.Exit(1)
+		if cfg.WebSubCallbackURL == "" {
+			os.Exit(1)
+		}
 	}
 
 	// Get status report
@@ -74,19 +94,41 @@ func main() {
 			"error": err.Error(),
 		})
 	} else {
-		appLogger.Info("Final status report", map[string]interface{}{
+		fields := map[string]interface{}{
 			"published_count": status.PublishedCount,
 			"recent_articles": len(status.RecentArticles),
 			"last_run":        status.LastRun.Format("2006-01-02 15:04:05"),
-		})
+		}
+		if status.LLMCache != nil {
+			fields["llm_cache_hits"] = status.LLMCache.Hits
+			fields["llm_cache_misses"] = status.LLMCache.Misses
+		}
+		appLogger.Info("Final status report", fields)
+	}
+
+	if cycleErr == nil {
+		appLogger.Info("Anime Api cycle completed successfully! 🎉")
 	}
 
-	appLogger.Info("Anime Api cycle completed successfully! 🎉")
+	// With a WebSub callback configured, stay up for pushes and keep polling feeds without a hub
+	if cfg.WebSubCallbackURL != "" {
+		setupGracefulShutdown(cancel, appLogger)
+		if err := runWithWebSub(ctx, cfg, services, appLogger); err != nil {
+			appLogger.Fatal("WebSub mode failed", map[string]interface{}{
+				"error": err.Error(),
+			})
+		}
+	}
 }
 
 // ServiceContainer holds all initialized services
 type ServiceContainer struct {
-	orchestrator *services.AnimeApiOrchestrator
+	rssFetcher       *services.RSSFetcher
+	duplicateChecker *services.DuplicateChecker
+	orchestrator     *services.AnimeApiOrchestrator
+
+	// transport is the record/replay harness, when HTTP_REPLAY_MODE is set
+	transport http.RoundTripper
 }
 
 // initializeServices initializes all required services
@@ -94,13 +136,44 @@ func initializeServices(cfg *config.Config, appLogger *logger.Logger) (*ServiceC
 	stdLogger := log.New(os.Stdout, "[ANIME-API] ", log.LstdFlags)
 
 	// Initialize RSS Fetcher
-	rssFetcher := services.NewRSSFetcher()
+	rssFetcher, err := services.NewRSSFetcher(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create RSS fetcher: %w", err)
+	}
 
 	// Initialize Duplicate Checker
-	duplicateChecker := services.NewDuplicateChecker()
+	publishedStore, err := services.OpenPublishedStore(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open published history: %w", err)
+	}
+	duplicateChecker := services.NewDuplicateChecker(publishedStore)
 
 	// Initialize Sinhala Writer
-	sinhalaWriter := services.NewSinhalaWriter(cfg.GeminiAPIKey)
+	writerLLM, err := services.NewLLMProvider(cfg, cfg.LLMModel)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create LLM provider: %w", err)
+	}
+	promptLibrary, err := prompts.Load(cfg.PromptsDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load prompt templates: %w", err)
+	}
+	sinhalaWriter, err := services.NewSinhalaWriter(writerLLM, promptLibrary, cfg.Persona)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Sinhala writer: %w", err)
+	}
+	if err := sinhalaWriter.SetValidator(services.NewPostValidator(cfg), cfg.PostMaxAttempts); err != nil {
+		return nil, fmt.Errorf("failed to set up post validation: %w", err)
+	}
+
+	// Reuse model responses across runs; recordings and replays need the real calls
+	var llmCache *services.LLMCache
+	if cfg.LLMCacheTTL > 0 && cfg.HTTPReplayMode == "off" {
+		llmCache, err = services.NewLLMCache(cfg.LLMCacheDir, cfg.LLMCacheTTL, cfg.LLMCacheBypass)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open LLM cache: %w", err)
+		}
+		sinhalaWriter.SetCache(llmCache)
+	}
 
 	// Initialize Social Media Publisher
 	socialMediaPublisher := services.NewSocialMediaPublisher(
@@ -108,20 +181,98 @@ func initializeServices(cfg *config.Config, appLogger *logger.Logger) (*ServiceC
 		cfg.TelegramChatID,
 	)
 
+	// Initialize Article Extractor (optional)
+	var articleExtractor *services.ArticleExtractor
+	if cfg.ArticleExtraction {
+		articleExtractor = services.NewArticleExtractor(cfg)
+	}
+
+	// Initialize Article Analyzer (optional)
+	var articleAnalyzer *services.ArticleAnalyzer
+	if cfg.ArticleAnalysis {
+		analyzerLLM, err := services.NewLLMProvider(cfg, cfg.LLMAnalyzerModel)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create analyzer LLM provider: %w", err)
+		}
+		articleAnalyzer = services.NewArticleAnalyzer(cfg, analyzerLLM, promptLibrary)
+		if llmCache != nil {
+			articleAnalyzer.SetCache(llmCache)
+		}
+	}
+
+	// Initialize AniList airing schedule (optional)
+	httpServices := []services.HTTPService{rssFetcher, sinhalaWriter, socialMediaPublisher}
+	if articleExtractor != nil {
+		httpServices = append(httpServices, articleExtractor)
+	}
+	if articleAnalyzer != nil {
+		httpServices = append(httpServices, articleAnalyzer)
+	}
+
+	var aniList *services.AniListAiringSource
+	if cfg.AniListEnabled {
+		aniList = services.NewAniListAiringSource(cfg)
+		httpServices = append(httpServices, aniList)
+	}
+
+	// Route all external HTTP traffic through the record/replay harness (optional)
+	var transport http.RoundTripper
+	if cfg.HTTPReplayMode != "off" {
+		replay, err := httpreplay.New(httpreplay.Mode(cfg.HTTPReplayMode), cfg.HTTPCassette, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to set up HTTP %s: %w", cfg.HTTPReplayMode, err)
+		}
+		transport = replay
+		for _, svc := range httpServices {
+			svc.SetTransport(transport)
+		}
+		appLogger.Info("HTTP traffic goes through the record/replay harness", map[string]interface{}{
+			"mode":     cfg.HTTPReplayMode,
+			"cassette": cfg.HTTPCassette,
+		})
+	}
+
 	// Initialize Orchestrator
 	orchestrator := services.NewAnimeApiOrchestrator(
 		rssFetcher,
 		duplicateChecker,
 		sinhalaWriter,
 		socialMediaPublisher,
+		articleExtractor,
 		stdLogger,
 	)
+	if aniList != nil {
+		orchestrator.AddSource(aniList)
+	}
+	if articleAnalyzer != nil {
+		orchestrator.SetArticleAnalyzer(articleAnalyzer, cfg.AnalysisMinRelevance)
+	}
+	orchestrator.SetCycleLock(services.CycleLockPath(cfg.DataDir), cfg.CycleLockWait)
+	orchestrator.SetHistoryRetention(cfg.HistoryRetention, services.PublishedArchiveDir(cfg.DataDir))
+	if llmCache != nil {
+		orchestrator.SetLLMCache(llmCache)
+	}
 
 	return &ServiceContainer{
-		orchestrator: orchestrator,
+		rssFetcher:       rssFetcher,
+		duplicateChecker: duplicateChecker,
+		orchestrator:     orchestrator,
+		transport:        transport,
 	}, nil
 }
 
+// exitIfLocked ends the run without an error when another process holds
+// the cycle lock, e.g. a manual CLI run overlapping a scheduled one
+func exitIfLocked(err error, appLogger *logger.Logger) {
+	if !errors.Is(err, filelock.ErrLocked) {
+		return
+	}
+	appLogger.Warn("Another run is already publishing, exiting", map[string]interface{}{
+		"error": err.Error(),
+	})
+	os.Exit(0)
+}
+
 // setupGracefulShutdown sets up graceful shutdown handling
 func setupGracefulShutdown(cancel context.CancelFunc, appLogger *logger.Logger) {
 	c := make(chan os.Signal, 1)
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"go-test/internal/config"
+	"go-test/internal/filelock"
+	"go-test/internal/services"
+	"go-test/pkg/logger"
+)
+
+// pushRetryDelay is how long a cycle triggered by a push waits before
+// trying again when another run holds the cycle lock
+const pushRetryDelay = time.Minute
+
+// runWithWebSub serves the WebSub callback, subscribes to every feed that
+// advertises a hub and polls the remaining feeds every POLL_INTERVAL until
+// ctx is cancelled. Pushed items go to the push inbox and trigger a cycle,
+// which runs them through the same pipeline as polled items.
+func runWithWebSub(ctx context.Context, cfg *config.Config, container *ServiceContainer, appLogger *logger.Logger) error {
+	// pushed holds at most one pending cycle, so a burst of notifications runs one cycle
+	pushed := make(chan struct{}, 1)
+	triggerCycle := func() {
+		select {
+		case pushed <- struct{}{}:
+		default:
+		}
+	}
+
+	subscriber := services.NewWebSubSubscriber(cfg, func(feed config.FeedConfig, body []byte) {
+		articles, result, err := container.rssFetcher.ReceivePush(feed, body)
+		if err != nil {
+			appLogger.Error("Failed to store WebSub notification", map[string]interface{}{
+				"feed":  feed.Name,
+				"error": err.Error(),
+			})
+			return
+		}
+
+		appLogger.Info("📬 WebSub notification received", map[string]interface{}{
+			"feed":     feed.Name,
+			"items":    len(articles),
+			"filtered": len(result.Rejected()),
+		})
+
+		if len(articles) > 0 {
+			triggerCycle()
+		}
+	})
+	container.rssFetcher.SetPushSource(subscriber)
+	if container.transport != nil {
+		// Discovery and hub requests are recorded and replayed like all other traffic
+		subscriber.SetTransport(container.transport)
+	}
+
+	callback, err := url.Parse(cfg.WebSubCallbackURL)
+	if err != nil {
+		return fmt.Errorf("invalid WebSub callback URL: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(strings.TrimSuffix(callback.Path, "/")+"/", subscriber)
+
+	server := &http.Server{
+		Addr:              ":" + cfg.Port,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	serverErr := make(chan error, 1)
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+		}
+	}()
+
+	hubs := subscriber.Subscribe(ctx, container.rssFetcher.Feeds())
+	appLogger.Info("📡 WebSub subscriptions requested", map[string]interface{}{
+		"callback":  cfg.WebSubCallbackURL,
+		"with_hub":  hubs,
+		"polled":    len(container.rssFetcher.Feeds()) - hubs,
+		"poll_time": cfg.PollInterval.String(),
+	})
+
+	go subscriber.Run(ctx)
+
+	ticker := time.NewTicker(cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case err := <-serverErr:
+			return fmt.Errorf("callback server failed: %w", err)
+
+		case <-ticker.C:
+			if err := container.orchestrator.ExecuteCycle(ctx); err != nil {
+				appLogger.Error("Polling cycle failed", map[string]interface{}{
+					"error": err.Error(),
+				})
+			}
+
+		case <-pushed:
+			err := container.orchestrator.ExecuteCycle(ctx)
+			if errors.Is(err, filelock.ErrLocked) {
+				// The items wait in the inbox; run again once the other run is done
+				appLogger.Warn("Another run holds the cycle lock, retrying pushed items later", map[string]interface{}{
+					"retry_in": pushRetryDelay.String(),
+				})
+				time.AfterFunc(pushRetryDelay, triggerCycle)
+			} else if err != nil {
+				appLogger.Error("Push cycle failed", map[string]interface{}{
+					"error": err.Error(),
+				})
+			}
+
+		case <-ctx.Done():
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			subscriber.Unsubscribe(shutdownCtx)
+			if err := server.Shutdown(shutdownCtx); err != nil {
+				return fmt.Errorf("failed to stop callback server: %w", err)
+			}
+			appLogger.Info("WebSub subscriber stopped")
+			return nil
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"go-test/internal/config"
+)
+
+// runFeedsCommand handles "feeds <subcommand>" for managing the feed registry.
+// It only touches the registry file, so no API keys are needed.
+func runFeedsCommand(args []string) error {
+	if len(args) == 0 {
+		printFeedsUsage()
+		return nil
+	}
+
+	flags := flag.NewFlagSet("feeds "+args[0], flag.ExitOnError)
+	registryPath := flags.String("registry", config.FeedsFilePath(), "Feed registry file")
+	output := flags.String("o", "", "Write OPML to this file instead of stdout (export only)")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "import":
+		if flags.NArg() != 1 {
+			return fmt.Errorf("usage: feeds import [-registry file] <feeds.opml>")
+		}
+		return importOPML(*registryPath, flags.Arg(0))
+
+	case "export":
+		return exportOPML(*registryPath, *output)
+
+	case "enable", "disable":
+		if flags.NArg() != 1 {
+			return fmt.Errorf("usage: feeds %s [-registry file] <category>", args[0])
+		}
+		return setCategoryEnabled(*registryPath, flags.Arg(0), args[0] == "enable")
+
+	case "list":
+		return listFeeds(*registryPath)
+
+	default:
+		printFeedsUsage()
+		return fmt.Errorf("unknown feeds command %q", args[0])
+	}
+}
+
+func importOPML(registryPath, opmlPath string) error {
+	file, err := os.Open(opmlPath)
+	if err != nil {
+		return fmt.Errorf("failed to open OPML file: %w", err)
+	}
+	defer file.Close()
+
+	feeds, err := config.ParseOPML(file)
+	if err != nil {
+		return err
+	}
+
+	registry, err := loadOrCreateRegistry(registryPath)
+	if err != nil {
+		return err
+	}
+
+	added, updated := registry.Merge(feeds)
+	if err := config.SaveFeedRegistry(registryPath, registry); err != nil {
+		return err
+	}
+
+	fmt.Printf("📥 Imported %s: %d feeds added, %d updated, %d already registered\n",
+		opmlPath, added, updated, len(feeds)-added-updated)
+	return nil
+}
+
+func exportOPML(registryPath, outputPath string) error {
+	registry, err := config.LoadFeedRegistry(registryPath)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if outputPath != "" {
+		file, err := os.Create(outputPath)
+		if err != nil {
+			return fmt.Errorf("failed to create OPML file: %w", err)
+		}
+		defer file.Close()
+		w = file
+	}
+
+	if err := config.WriteOPML(w, registry, "Anime Api feeds"); err != nil {
+		return err
+	}
+
+	if outputPath != "" {
+		fmt.Printf("📤 Exported %d feeds to %s\n", len(registry.Feeds), outputPath)
+	}
+	return nil
+}
+
+func setCategoryEnabled(registryPath, category string, enabled bool) error {
+	registry, err := config.LoadFeedRegistry(registryPath)
+	if err != nil {
+		return err
+	}
+
+	registry.SetCategoryEnabled(category, enabled)
+	if err := config.SaveFeedRegistry(registryPath, registry); err != nil {
+		return err
+	}
+
+	state := "disabled"
+	if enabled {
+		state = "enabled"
+	}
+	fmt.Printf("✅ Category %q %s (%d feeds now active)\n", category, state, len(registry.EnabledFeeds()))
+	return nil
+}
+
+func listFeeds(registryPath string) error {
+	registry, err := config.LoadFeedRegistry(registryPath)
+	if err != nil {
+		return err
+	}
+
+	enabled := make(map[string]bool)
+	for _, feed := range registry.EnabledFeeds() {
+		enabled[feed.URL] = true
+	}
+
+	for _, feed := range registry.Feeds {
+		mark := "❌"
+		if enabled[feed.URL] {
+			mark = "✅"
+		}
+		category := feed.Category
+		if category == "" {
+			category = "-"
+		}
+		fmt.Printf("%s %-25s %-12s %s\n", mark, feed.Name, category, feed.URL)
+	}
+	return nil
+}
+
+// loadOrCreateRegistry loads the registry, starting an empty one if the file does not exist yet
+func loadOrCreateRegistry(path string) (*config.FeedRegistry, error) {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return &config.FeedRegistry{}, nil
+	}
+	return config.LoadFeedRegistry(path)
+}
+
+func printFeedsUsage() {
+	fmt.Println("Feed registry commands:")
+	fmt.Println("  feeds import <file.opml>   : Add the feeds and folders of an OPML file")
+	fmt.Println("  feeds export [-o file]     : Write the registry as OPML (stdout by default)")
+	fmt.Println("  feeds enable <category>    : Fetch feeds in a category again")
+	fmt.Println("  feeds disable <category>   : Stop fetching feeds in a category")
+	fmt.Println("  feeds list                 : Show all feeds and whether they are fetched")
+	fmt.Println()
+	fmt.Println("All commands accept -registry <file> (default: FEEDS_FILE or configs/feeds.json).")
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"go-test/internal/config"
+	"go-test/internal/services"
+)
+
+// runHistoryCommand handles "history <subcommand>" for maintaining the
+// published-article history. Like the feeds commands it needs no API keys.
+func runHistoryCommand(args []string) error {
+	if len(args) == 0 {
+		printHistoryUsage()
+		return nil
+	}
+
+	cfg, err := config.LoadHistory()
+	if err != nil {
+		return err
+	}
+
+	flags := flag.NewFlagSet("history "+args[0], flag.ExitOnError)
+	logPath := flags.String("file", services.PublishedLogPath(cfg.DataDir), "History log file (convert only)")
+	days := flags.Int("days", int(cfg.HistoryRetention.Hours()/24), "Archive entries older than this many days (compact only)")
+	sent := flags.Bool("sent", false, "The post is in the channel; log it as published (resolve only)")
+	retry := flags.Bool("retry", false, "The post is not in the channel; allow it to be posted again (resolve only)")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "convert":
+		return convertHistory(*logPath)
+
+	case "compact":
+		if *days <= 0 {
+			return fmt.Errorf("usage: history compact -days <n> (or set HISTORY_RETENTION_DAYS)")
+		}
+		cfg.HistoryRetention = time.Duration(*days) * 24 * time.Hour
+		// Same rule as HISTORY_RETENTION_DAYS: a key archived while its item
+		// is still in the feeds would let the item be posted again
+		if cfg.FeedMaxAge == 0 || cfg.HistoryRetention <= cfg.FeedMaxAge {
+			return fmt.Errorf("-days must be longer than FEED_MAX_AGE (%s), which must be set", cfg.FeedMaxAge)
+		}
+		return compactHistory(cfg)
+
+	case "pending":
+		return listPendingPublishes(cfg)
+
+	case "resolve":
+		if flags.NArg() != 1 || *sent == *retry {
+			return fmt.Errorf("usage: history resolve -sent|-retry <link>")
+		}
+		return resolvePendingPublish(cfg, flags.Arg(0), *sent)
+
+	default:
+		printHistoryUsage()
+		return fmt.Errorf("unknown history command %q", args[0])
+	}
+}
+
+func convertHistory(logPath string) error {
+	converted, damaged, backupPath, err := services.ConvertPublishedLog(logPath)
+	if err != nil {
+		return err
+	}
+
+	if converted == 0 {
+		fmt.Printf("✅ %s is already in the JSON Lines format\n", logPath)
+		return nil
+	}
+
+	fmt.Printf("🔄 Converted %d legacy entries in %s (backup: %s)\n", converted, logPath, backupPath)
+	if damaged > 0 {
+		fmt.Printf("⚠️ Skipped %d damaged lines; they are only in the backup\n", damaged)
+	}
+	return nil
+}
+
+func compactHistory(cfg *config.Config) error {
+	store, err := services.OpenPublishedStore(cfg)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	archiveDir := services.PublishedArchiveDir(cfg.DataDir)
+	archived, err := services.NewDuplicateChecker(store).CompactHistory(cfg.HistoryRetention, archiveDir)
+	if err != nil {
+		return err
+	}
+
+	remaining, err := store.Count()
+	if err != nil {
+		return err
+	}
+
+	if archived == 0 {
+		fmt.Printf("✅ Nothing older than %.0f days; %d entries in the history\n", cfg.HistoryRetention.Hours()/24, remaining)
+		return nil
+	}
+
+	fmt.Printf("🗜️ Archived %d entries to %s; %d remain in the history\n", archived, archiveDir, remaining)
+	return nil
+}
+
+func listPendingPublishes(cfg *config.Config) error {
+	store, err := services.OpenPublishedStore(cfg)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	pending, err := services.NewDuplicateChecker(store).PendingReservations()
+	if err != nil {
+		return err
+	}
+
+	if len(pending) == 0 {
+		fmt.Println("✅ No publishes are waiting to be resolved")
+		return nil
+	}
+
+	fmt.Printf("⏸️ %d publishes were never confirmed. Check the channel, then run \"history resolve -sent|-retry <link>\":\n", len(pending))
+	for _, reservation := range pending {
+		status := "unconfirmed"
+		if reservation.Article.Channel != "" {
+			status = "sent to " + reservation.Article.Channel
+		}
+		fmt.Printf("  %s  %s  %s (%s)\n", reservation.ReservedAt.Format("2006-01-02 15:04"), reservation.Article.Link, reservation.Article.Title, status)
+	}
+	return nil
+}
+
+func resolvePendingPublish(cfg *config.Config, link string, sent bool) error {
+	store, err := services.OpenPublishedStore(cfg)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	article, err := services.NewDuplicateChecker(store).ResolveReservation(link, sent)
+	if err != nil {
+		return err
+	}
+
+	if sent {
+		fmt.Printf("📋 Logged %s as published\n", article.Title)
+	} else {
+		fmt.Printf("🔁 Released %s; it can be posted again\n", article.Title)
+	}
+	return nil
+}
+
+func printHistoryUsage() {
+	fmt.Println("Published history commands:")
+	fmt.Println("  history convert           : Rewrite a pipe-delimited log as JSON Lines, keeping a .bak copy")
+	fmt.Println("  history compact [-days n] : Move entries older than n days to gzip archives in $DATA_DIR/archive")
+	fmt.Println("  history pending           : List publishes that were never confirmed")
+	fmt.Println("  history resolve -sent|-retry <link> : Log an unconfirmed publish as posted, or release it")
+	fmt.Println()
+	fmt.Println("convert accepts -file <log> (default: $DATA_DIR/published_articles.txt).")
+	fmt.Println("compact uses HISTORY_BACKEND and defaults -days to HISTORY_RETENTION_DAYS.")
+}
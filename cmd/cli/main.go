@@ -8,14 +8,37 @@ import (
 	"os"
 
 	"go-test/internal/config"
+	"go-test/internal/httpreplay"
+	"go-test/internal/prompts"
 	"go-test/internal/services"
 )
 
 func main() {
+	// Subcommands that manage files only run before the flags and config are loaded
+	if len(os.Args) > 1 && os.Args[1] == "feeds" {
+		if err := runFeedsCommand(os.Args[2:]); err != nil {
+			log.Fatalf("Feeds command failed: %v", err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "prompts" {
+		if err := runPromptsCommand(os.Args[2:]); err != nil {
+			log.Fatalf("Prompts command failed: %v", err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "history" {
+		if err := runHistoryCommand(os.Args[2:]); err != nil {
+			log.Fatalf("History command failed: %v", err)
+		}
+		return
+	}
+
 	var (
 		testTools  = flag.Bool("test", false, "Test all tools without posting")
 		showStatus = flag.Bool("status", false, "Show current status")
 		runCycle   = flag.Bool("run", false, "Run one complete cycle")
+		noCache    = flag.Bool("no-cache", false, "Ask the model again instead of using cached responses")
 	)
 	flag.Parse()
 
@@ -24,29 +47,118 @@ func main() {
 	if err != nil {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
+	if *noCache {
+		cfg.LLMCacheBypass = true
+	}
 
 	// Initialize services
 	stdLogger := log.New(os.Stdout, "[ANIME-API-CLI] ", log.LstdFlags)
 
-	rssFetcher := services.NewRSSFetcher()
-	duplicateChecker := services.NewDuplicateChecker()
-	sinhalaWriter := services.NewSinhalaWriter(cfg.GeminiAPIKey)
+	rssFetcher, err := services.NewRSSFetcher(cfg)
+	if err != nil {
+		log.Fatalf("Failed to load feed registry: %v", err)
+	}
+	publishedStore, err := services.OpenPublishedStore(cfg)
+	if err != nil {
+		log.Fatalf("Failed to open published history: %v", err)
+	}
+	defer publishedStore.Close()
+	duplicateChecker := services.NewDuplicateChecker(publishedStore)
+	writerLLM, err := services.NewLLMProvider(cfg, cfg.LLMModel)
+	if err != nil {
+		log.Fatalf("Failed to create LLM provider: %v", err)
+	}
+	promptLibrary, err := prompts.Load(cfg.PromptsDir)
+	if err != nil {
+		log.Fatalf("Failed to load prompt templates: %v", err)
+	}
+	sinhalaWriter, err := services.NewSinhalaWriter(writerLLM, promptLibrary, cfg.Persona)
+	if err != nil {
+		log.Fatalf("Failed to create Sinhala writer: %v", err)
+	}
+	if err := sinhalaWriter.SetValidator(services.NewPostValidator(cfg), cfg.PostMaxAttempts); err != nil {
+		log.Fatalf("Failed to set up post validation: %v", err)
+	}
+	var llmCache *services.LLMCache
+	if cfg.LLMCacheTTL > 0 && cfg.HTTPReplayMode == "off" {
+		llmCache, err = services.NewLLMCache(cfg.LLMCacheDir, cfg.LLMCacheTTL, cfg.LLMCacheBypass)
+		if err != nil {
+			log.Fatalf("Failed to open LLM cache: %v", err)
+		}
+		sinhalaWriter.SetCache(llmCache)
+	}
 	socialMediaPublisher := services.NewSocialMediaPublisher(cfg.TelegramBotToken, cfg.TelegramChatID)
 
+	httpServices := []services.HTTPService{rssFetcher, sinhalaWriter, socialMediaPublisher}
+
+	var articleExtractor *services.ArticleExtractor
+	if cfg.ArticleExtraction {
+		articleExtractor = services.NewArticleExtractor(cfg)
+		httpServices = append(httpServices, articleExtractor)
+	}
+
+	var articleAnalyzer *services.ArticleAnalyzer
+	if cfg.ArticleAnalysis {
+		analyzerLLM, err := services.NewLLMProvider(cfg, cfg.LLMAnalyzerModel)
+		if err != nil {
+			log.Fatalf("Failed to create analyzer LLM provider: %v", err)
+		}
+		articleAnalyzer = services.NewArticleAnalyzer(cfg, analyzerLLM, promptLibrary)
+		if llmCache != nil {
+			articleAnalyzer.SetCache(llmCache)
+		}
+		httpServices = append(httpServices, articleAnalyzer)
+	}
+
+	var aniList *services.AniListAiringSource
+	if cfg.AniListEnabled {
+		aniList = services.NewAniListAiringSource(cfg)
+		httpServices = append(httpServices, aniList)
+	}
+
+	if cfg.HTTPReplayMode != "off" {
+		transport, err := httpreplay.New(httpreplay.Mode(cfg.HTTPReplayMode), cfg.HTTPCassette, nil)
+		if err != nil {
+			log.Fatalf("Failed to set up HTTP %s: %v", cfg.HTTPReplayMode, err)
+		}
+		for _, svc := range httpServices {
+			svc.SetTransport(transport)
+		}
+		fmt.Printf("📼 HTTP %s mode using %s\n", cfg.HTTPReplayMode, cfg.HTTPCassette)
+	}
+
 	orchestrator := services.NewAnimeApiOrchestrator(
 		rssFetcher,
 		duplicateChecker,
 		sinhalaWriter,
 		socialMediaPublisher,
+		articleExtractor,
 		stdLogger,
 	)
+	if aniList != nil {
+		orchestrator.AddSource(aniList)
+	}
+	if articleAnalyzer != nil {
+		orchestrator.SetArticleAnalyzer(articleAnalyzer, cfg.AnalysisMinRelevance)
+	}
+	orchestrator.SetCycleLock(services.CycleLockPath(cfg.DataDir), cfg.CycleLockWait)
+	orchestrator.SetHistoryRetention(cfg.HistoryRetention, services.PublishedArchiveDir(cfg.DataDir))
+	if llmCache != nil {
+		orchestrator.SetLLMCache(llmCache)
+	}
 
 	ctx := context.Background()
 
 	switch {
 	case *testTools:
 		fmt.Println("🧪 Testing all tools...")
-		if err := orchestrator.TestAllTools(ctx); err != nil {
+		err := orchestrator.TestAllTools(ctx)
+		if llmCache != nil {
+			if flushErr := llmCache.Flush(); flushErr != nil {
+				log.Printf("⚠️  %v", flushErr)
+			}
+		}
+		if err != nil {
 			log.Fatalf("Tool testing failed: %v", err)
 		}
 		fmt.Println("✅ All tools tested successfully!")
@@ -62,6 +174,9 @@ func main() {
 		fmt.Printf("   Published Articles: %d\n", status.PublishedCount)
 		fmt.Printf("   Last Run: %s\n", status.LastRun.Format("2006-01-02 15:04:05"))
 		fmt.Printf("   Recent Articles: %d\n", len(status.RecentArticles))
+		if cache := status.LLMCache; cache != nil {
+			fmt.Printf("   LLM Cache: %d hits, %d misses, %d entries\n", cache.Hits, cache.Misses, cache.Entries)
+		}
 
 		for _, svc := range status.ServiceStatuses {
 			fmt.Printf("   %s: %s", svc.Name, svc.Status)
@@ -73,6 +188,9 @@ func main() {
 
 	case *runCycle:
 		fmt.Println("🎯 Running complete autonomous cycle...")
+		if err := orchestrator.RecoverPendingPublishes(cfg.ReservationTimeout); err != nil {
+			log.Fatalf("Recovery failed: %v", err)
+		}
 		if err := orchestrator.ExecuteCycle(ctx); err != nil {
 			log.Fatalf("Cycle failed: %v", err)
 		}
@@ -85,10 +203,19 @@ func main() {
 		fmt.Println("  --test    : Test all tools without posting")
 		fmt.Println("  --status  : Show current status")
 		fmt.Println("  --run     : Run one complete cycle")
+		fmt.Println("  --no-cache: With --test or --run, ask the model again instead of using cached responses")
+		fmt.Println("  feeds     : Import, export and manage the feed registry (see: feeds help)")
+		fmt.Println("  history   : Maintain the published-article history (see: history help)")
+		fmt.Println("  prompts   : List and preview the prompt templates (see: prompts help)")
 		fmt.Println()
 		fmt.Println("Examples:")
-		fmt.Println("  go run cmd/cli/main.go --test")
-		fmt.Println("  go run cmd/cli/main.go --status")
-		fmt.Println("  go run cmd/cli/main.go --run")
+		fmt.Println("  go run ./cmd/cli --test")
+		fmt.Println("  go run ./cmd/cli --status")
+		fmt.Println("  go run ./cmd/cli --run")
+		fmt.Println("  go run ./cmd/cli --no-cache --test")
+		fmt.Println("  go run ./cmd/cli feeds import subscriptions.opml")
+		fmt.Println("  go run ./cmd/cli history convert")
+		fmt.Println("  go run ./cmd/cli history compact -days 180")
+		fmt.Println("  go run ./cmd/cli prompts render -persona meme_page -title \"Frieren season 2\"")
 	}
 }
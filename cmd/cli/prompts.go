@@ -0,0 +1,76 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"go-test/internal/config"
+	"go-test/internal/prompts"
+	"go-test/internal/services"
+)
+
+// runPromptsCommand handles "prompts <subcommand>" for previewing the prompt
+// templates. It renders locally and calls no model, so no API keys are needed.
+func runPromptsCommand(args []string) error {
+	if len(args) == 0 {
+		printPromptsUsage()
+		return nil
+	}
+
+	flags := flag.NewFlagSet("prompts "+args[0], flag.ExitOnError)
+	dir := flags.String("dir", config.PromptsDirPath(), "Prompt template directory")
+	persona := flags.String("persona", config.PersonaName(), "Persona that writes the post (render only)")
+	analysis := flags.Bool("analysis", false, "Render the analysis prompt instead of a persona (render only)")
+	lines := flags.Bool("lines", false, "With -analysis, render the line format used without structured output")
+	title := flags.String("title", "", "Article title (render only)")
+	summary := flags.String("summary", "", "Article summary or full text (render only)")
+	link := flags.String("link", "", "Article link (render only)")
+	source := flags.String("source", "", "Article source name (render only)")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	library, err := prompts.Load(*dir)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "render":
+		if *title == "" {
+			return fmt.Errorf("usage: prompts render -title <title> [-summary text] [-link url] [-source name] [-persona name | -analysis [-lines]]")
+		}
+
+		var rendered prompts.Rendered
+		if *analysis {
+			rendered, err = library.Render("analysis", services.AnalysisPrompt{Title: *title, Description: *summary, Source: *source, Structured: !*lines})
+		} else {
+			rendered, err = library.Render(prompts.PersonaPrefix+*persona, services.PostPrompt{Title: *title, Summary: *summary, Link: *link, Source: *source})
+		}
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("📝 %s\n---\n%s\n---\n", rendered.ID(), rendered.Text)
+		return nil
+
+	case "list":
+		for _, prompt := range library.List() {
+			fmt.Printf("%-30s %s\n", prompt.Name, prompt.Version)
+		}
+		return nil
+
+	default:
+		printPromptsUsage()
+		return fmt.Errorf("unknown prompts command %q", args[0])
+	}
+}
+
+func printPromptsUsage() {
+	fmt.Println("Prompt template commands:")
+	fmt.Println("  prompts list                : Show the templates and their versions")
+	fmt.Println("  prompts render -title <t>   : Preview the prompt for an article (-summary, -link, -source)")
+	fmt.Println("                                with -persona <name> (default: PERSONA) or -analysis [-lines]")
+	fmt.Println()
+	fmt.Println("All commands accept -dir <directory> (default: PROMPTS_DIR or configs/prompts).")
+}
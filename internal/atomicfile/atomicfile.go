@@ -0,0 +1,50 @@
+// Package atomicfile replaces files so that readers and crashes only ever
+// see the old or the new content, never a torn mix of both.
+package atomicfile
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// WriteFile replaces path with data through a uniquely named temp file in
+// the same directory. The data is synced to disk before the rename, so a
+// crash never leaves a torn or empty file, and two processes saving at once
+// never rename each other's half-written copy.
+func WriteFile(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmpPath, 0644)
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, path)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	syncDir(filepath.Dir(path))
+	return nil
+}
+
+// syncDir makes the rename itself durable. It is best effort: some
+// platforms cannot open or sync a directory, and the data is already safe.
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
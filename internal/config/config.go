@@ -2,9 +2,13 @@ package config
 
 import (
 	"fmt"
+	"net/url"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
+	_ "time/tzdata" // ANILIST_TIMEZONE must resolve in images without a zoneinfo database
 
 	"github.com/joho/godotenv"
 )
@@ -19,6 +23,31 @@ type Config struct {
 	TelegramBotToken string
 	TelegramChatID   string
 
+	// LLM
+	LLMProvider      string
+	LLMModel         string
+	LLMAnalyzerModel string
+	LLMEmbedModel    string
+	LLMBaseURL       string
+	LLMAPIKey        string
+	LLMTimeout       time.Duration
+	LLMCacheDir      string
+	LLMCacheTTL      time.Duration
+	LLMCacheBypass   bool
+
+	// Prompts
+	PromptsDir string
+	Persona    string
+
+	// Post Validation
+	PostMaxAttempts     int
+	PostMinSinhalaRatio float64
+	PostMinLength       int
+	PostMaxLength       int
+	PostRequireLink     bool
+	PostRequireQuestion bool
+	PostBannedPhrases   []string
+
 	// Application Configuration
 	Port        string
 	Environment string
@@ -29,6 +58,64 @@ type Config struct {
 	RetryAttempts  int
 	RateLimitDelay time.Duration
 
+	// Storage
+	DataDir            string
+	HistoryBackend     string
+	ReservationTimeout time.Duration
+	CycleLockWait      time.Duration
+	HistoryRetention   time.Duration
+
+	// Feed Fetching
+	FeedsFile       string
+	RelevanceFile   string
+	FeedConcurrency int
+	FeedTimeout     time.Duration
+	FeedCacheDir    string
+	FeedMaxItems    int
+	FeedMaxAge      time.Duration
+	SeenItemsFile   string
+
+	// Article Extraction
+	ArticleExtraction    bool
+	ArticleMaxChars      int
+	ArticleHostDelay     time.Duration
+	ArticleMaxCrawlDelay time.Duration
+	ArticleRobotsTTL     time.Duration
+
+	// Article Analysis
+	ArticleAnalysis      bool
+	AnalysisMinRelevance float64
+
+	// Feed Health
+	FeedHealthFile       string
+	FeedFailureThreshold int
+	FeedBackoff          time.Duration
+	FeedMaxBackoff       time.Duration
+
+	// Story Clustering
+	ClusterSimilarity float64
+	ClusterWindow     time.Duration
+
+	// AniList Airing Schedule
+	AniListEnabled       bool
+	AniListEndpoint      string
+	AniListWindow        time.Duration
+	AniListMinPopularity int
+	AniListFollowedIDs   []int
+	AniListMaxItems      int
+	AniListTimezone      string
+
+	// WebSub Push
+	WebSubCallbackURL string
+	WebSubSecret      string
+	WebSubLease       time.Duration
+	PollInterval      time.Duration
+	PushInboxFile     string
+
+	// HTTP Record/Replay
+	HTTPReplayMode string
+	HTTPCassette   string
+
 	// Security
 	AllowedOrigins []string
 	MaxRequestSize int64
@@ -38,11 +125,92 @@ type Config struct {
 	LogFormat string
 }
 
+const (
+	defaultFeedsFile  = "configs/feeds.json"
+	defaultDataDir    = "data"
+	defaultPromptsDir = "configs/prompts"
+	defaultPersona    = "casual_youth"
+
+	// telegramMaxMessageLength is the most characters a Telegram message can hold
+	telegramMaxMessageLength = 4096
+)
+
+// llmDefaults holds the endpoint and models used for each LLM_PROVIDER
+// unless LLM_BASE_URL, LLM_MODEL or LLM_EMBED_MODEL say otherwise
+var llmDefaults = map[string]struct {
+	baseURL    string
+	model      string
+	embedModel string
+}{
+	"gemini":   {"https://generativelanguage.googleapis.com/v1beta", "gemini-2.5-pro", "text-embedding-004"},
+	"openai":   {"https://api.openai.com/v1", "gpt-4o-mini", "text-embedding-3-small"},
+	"ollama":   {"http://localhost:11434", "llama3.1", "nomic-embed-text"},
+	"llamacpp": {"http://localhost:8080/v1", "default", "default"},
+}
+
+// FeedsFilePath returns the feed registry location from FEEDS_FILE. Unlike
+// Load it needs no API keys, so feed management commands work without them.
+func FeedsFilePath() string {
+	_ = godotenv.Load()
+	return getEnv("FEEDS_FILE", defaultFeedsFile)
+}
+
+// DataDirPath returns the data directory from DATA_DIR. Like FeedsFilePath
+// it needs no API keys, for commands that only maintain local files.
+func DataDirPath() string {
+	_ = godotenv.Load()
+	return getEnv("DATA_DIR", defaultDataDir)
+}
+
+// PromptsDirPath returns the prompt template directory from PROMPTS_DIR.
+// Like FeedsFilePath it needs no API keys, so prompts can be previewed
+// without them.
+func PromptsDirPath() string {
+	_ = godotenv.Load()
+	return getEnv("PROMPTS_DIR", defaultPromptsDir)
+}
+
+// PersonaName returns the persona that writes posts from PERSONA
+func PersonaName() string {
+	_ = godotenv.Load()
+	return getEnv("PERSONA", defaultPersona)
+}
+
+// LoadHistory reads only the published history settings. Like
+// FeedsFilePath it needs no API keys, for commands that maintain the
+// history.
+func LoadHistory() (*Config, error) {
+	_ = godotenv.Load()
+
+	cfg := &Config{
+		DataDir:          getEnv("DATA_DIR", defaultDataDir),
+		HistoryBackend:   getEnv("HISTORY_BACKEND", "file"),
+		HistoryRetention: historyRetention(),
+		FeedMaxAge:       getEnvAsDuration("FEED_MAX_AGE", "72h"),
+	}
+	if err := cfg.validateHistory(); err != nil {
+		return nil, fmt.Errorf("configuration validation failed: %w", err)
+	}
+	return cfg, nil
+}
+
+// historyRetention reads HISTORY_RETENTION_DAYS; 0 keeps the history forever
+func historyRetention() time.Duration {
+	return time.Duration(getEnvAsInt("HISTORY_RETENTION_DAYS", 0)) * 24 * time.Hour
+}
+
 // Load reads configuration from environment variables
 func Load() (*Config, error) {
 	// Load .env file if it exists (ignore error in production)
 	_ = godotenv.Load()
 
+	// Files the app writes default to locations inside DATA_DIR
+	dataDir := getEnv("DATA_DIR", defaultDataDir)
+
+	llmProvider := getEnv("LLM_PROVIDER", "gemini")
+	llm := llmDefaults[llmProvider]
+	llmModel := getEnv("LLM_MODEL", llm.model)
+
 	cfg := &Config{
 		// Required API keys
 		GeminiAPIKey: getEnv("GEMINI_API_KEY", ""),
@@ -52,6 +220,31 @@ func Load() (*Config, error) {
 		TelegramBotToken: getEnv("TELEGRAM_BOT_TOKEN", ""),
 		TelegramChatID:   getEnv("TELEGRAM_CHAT_ID", ""),
 
+		// LLM settings
+		LLMProvider:      llmProvider,
+		LLMModel:         llmModel,
+		LLMAnalyzerModel: getEnv("LLM_ANALYZER_MODEL", llmModel),
+		LLMEmbedModel:    getEnv("LLM_EMBED_MODEL", llm.embedModel),
+		LLMBaseURL:       getEnv("LLM_BASE_URL", llm.baseURL),
+		LLMAPIKey:        getEnv("LLM_API_KEY", ""),
+		LLMTimeout:       getEnvAsDuration("LLM_TIMEOUT", "60s"),
+		LLMCacheDir:      getEnv("LLM_CACHE_DIR", filepath.Join(dataDir, "llm_cache")),
+		LLMCacheTTL:      getEnvAsDuration("LLM_CACHE_TTL", "24h"),
+		LLMCacheBypass:   getEnvAsBool("LLM_CACHE_BYPASS", false),
+
+		// Prompt templates
+		PromptsDir: getEnv("PROMPTS_DIR", defaultPromptsDir),
+		Persona:    getEnv("PERSONA", defaultPersona),
+
+		// Post validation defaults
+		PostMaxAttempts:     getEnvAsInt("POST_MAX_ATTEMPTS", 3),
+		PostMinSinhalaRatio: getEnvAsFloat("POST_MIN_SINHALA_RATIO", 0.3),
+		PostMinLength:       getEnvAsInt("POST_MIN_LENGTH", 80),
+		PostMaxLength:       getEnvAsInt("POST_MAX_LENGTH", telegramMaxMessageLength),
+		PostRequireLink:     getEnvAsBool("POST_REQUIRE_LINK", true),
+		PostRequireQuestion: getEnvAsBool("POST_REQUIRE_QUESTION", true),
+		PostBannedPhrases:   getEnvAsStringSlice("POST_BANNED_PHRASES"),
+
 		// Application settings
 		Port:        getEnv("PORT", "8080"),
 		Environment: getEnv("ENVIRONMENT", "development"),
@@ -62,6 +255,64 @@ func Load() (*Config, error) {
 		RetryAttempts:  getEnvAsInt("RETRY_ATTEMPTS", 3),
 		RateLimitDelay: getEnvAsDuration("RATE_LIMIT_DELAY", "1s"),
 
+		// Storage
+		DataDir:            dataDir,
+		HistoryBackend:     getEnv("HISTORY_BACKEND", "file"),
+		ReservationTimeout: getEnvAsDuration("RESERVATION_TIMEOUT", "10m"),
+		CycleLockWait:      getEnvAsDuration("CYCLE_LOCK_WAIT", "0s"),
+		HistoryRetention:   historyRetention(),
+
+		// Feed fetching defaults
+		FeedsFile:       getEnv("FEEDS_FILE", defaultFeedsFile),
+		RelevanceFile:   getEnv("RELEVANCE_FILE", "configs/relevance.json"),
+		FeedConcurrency: getEnvAsInt("FEED_CONCURRENCY", 4),
+		FeedTimeout:     getEnvAsDuration("FEED_TIMEOUT", "20s"),
+		FeedCacheDir:    getEnv("FEED_CACHE_DIR", filepath.Join(dataDir, "feed_cache")),
+		FeedMaxItems:    getEnvAsInt("FEED_MAX_ITEMS", 15),
+		FeedMaxAge:      getEnvAsDuration("FEED_MAX_AGE", "72h"),
+		SeenItemsFile:   getEnv("SEEN_ITEMS_FILE", filepath.Join(dataDir, "seen_items.json")),
+
+		// Article extraction defaults
+		ArticleExtraction:    getEnvAsBool("ARTICLE_EXTRACTION", false),
+		ArticleMaxChars:      getEnvAsInt("ARTICLE_MAX_CHARS", 4000),
+		ArticleHostDelay:     getEnvAsDuration("ARTICLE_HOST_DELAY", "5s"),
+		ArticleMaxCrawlDelay: getEnvAsDuration("ARTICLE_MAX_CRAWL_DELAY", "30s"),
+		ArticleRobotsTTL:     getEnvAsDuration("ARTICLE_ROBOTS_TTL", "24h"),
+
+		// Article analysis defaults
+		ArticleAnalysis:      getEnvAsBool("ARTICLE_ANALYSIS", false),
+		AnalysisMinRelevance: getEnvAsFloat("ANALYSIS_MIN_RELEVANCE", 0.5),
+
+		// Feed health defaults
+		FeedHealthFile:       getEnv("FEED_HEALTH_FILE", filepath.Join(dataDir, "feed_health.json")),
+		FeedFailureThreshold: getEnvAsInt("FEED_FAILURE_THRESHOLD", 3),
+		FeedBackoff:          getEnvAsDuration("FEED_BACKOFF", "15m"),
+		FeedMaxBackoff:       getEnvAsDuration("FEED_MAX_BACKOFF", "6h"),
+
+		// Story clustering defaults
+		ClusterSimilarity: getEnvAsFloat("CLUSTER_SIMILARITY", 0.6),
+		ClusterWindow:     getEnvAsDuration("CLUSTER_WINDOW", "48h"),
+
+		// AniList airing schedule defaults
+		AniListEnabled:       getEnvAsBool("ANILIST_ENABLED", false),
+		AniListEndpoint:      getEnv("ANILIST_ENDPOINT", "https://graphql.anilist.co"),
+		AniListWindow:        getEnvAsDuration("ANILIST_WINDOW", "24h"),
+		AniListMinPopularity: getEnvAsInt("ANILIST_MIN_POPULARITY", 50000),
+		AniListFollowedIDs:   getEnvAsIntSlice("ANILIST_FOLLOWED_IDS"),
+		AniListMaxItems:      getEnvAsInt("ANILIST_MAX_ITEMS", 5),
+		AniListTimezone:      getEnv("ANILIST_TIMEZONE", "Asia/Colombo"),
+
+		// WebSub defaults (an empty callback URL disables push)
+		WebSubCallbackURL: getEnv("WEBSUB_CALLBACK_URL", ""),
+		WebSubSecret:      getEnv("WEBSUB_SECRET", ""),
+		WebSubLease:       getEnvAsDuration("WEBSUB_LEASE", "24h"),
+		PollInterval:      getEnvAsDuration("POLL_INTERVAL", "30m"),
+		PushInboxFile:     getEnv("PUSH_INBOX_FILE", filepath.Join(dataDir, "push_inbox.json")),
+
+		// HTTP record/replay defaults (off talks to the real APIs)
+		HTTPReplayMode: getEnv("HTTP_REPLAY_MODE", "off"),
+		HTTPCassette:   getEnv("HTTP_CASSETTE", filepath.Join(dataDir, "cassettes", "pipeline.json")),
+
 		// Security defaults
 		AllowedOrigins: []string{"*"},                                // Configure properly in production
 		MaxRequestSize: getEnvAsInt64("MAX_REQUEST_SIZE", 1024*1024), // 1MB
@@ -80,10 +331,50 @@ func Load() (*Config, error) {
 
 // validate ensures all required configuration is present
 func (c *Config) validate() error {
-	if c.GeminiAPIKey == "" {
+	if _, ok := llmDefaults[c.LLMProvider]; !ok {
+		return fmt.Errorf("LLM_PROVIDER must be gemini, openai, ollama or llamacpp")
+	}
+
+	if c.LLMProvider == "gemini" && c.GeminiAPIKey == "" {
 		return fmt.Errorf("GEMINI_API_KEY is required")
 	}
 
+	if c.LLMProvider == "openai" && c.LLMAPIKey == "" {
+		return fmt.Errorf("LLM_API_KEY is required for LLM_PROVIDER=openai")
+	}
+
+	if c.LLMModel == "" {
+		return fmt.Errorf("LLM_MODEL is required")
+	}
+
+	if c.LLMTimeout <= 0 {
+		return fmt.Errorf("LLM_TIMEOUT must be positive")
+	}
+
+	if c.PostMaxAttempts < 1 {
+		return fmt.Errorf("POST_MAX_ATTEMPTS must be at least 1")
+	}
+
+	if c.PostMinSinhalaRatio < 0 || c.PostMinSinhalaRatio > 1 {
+		return fmt.Errorf("POST_MIN_SINHALA_RATIO must be between 0 and 1")
+	}
+
+	if c.AnalysisMinRelevance < 0 || c.AnalysisMinRelevance > 1 {
+		return fmt.Errorf("ANALYSIS_MIN_RELEVANCE must be between 0 and 1")
+	}
+
+	if c.PostMaxLength < 1 || c.PostMaxLength > telegramMaxMessageLength {
+		return fmt.Errorf("POST_MAX_LENGTH must be between 1 and %d", telegramMaxMessageLength)
+	}
+
+	if c.PostMinLength < 0 || c.PostMinLength > c.PostMaxLength {
+		return fmt.Errorf("POST_MIN_LENGTH must be between 0 and POST_MAX_LENGTH")
+	}
+
+	if c.LLMCacheTTL < 0 {
+		return fmt.Errorf("LLM_CACHE_TTL must not be negative")
+	}
+
 	if c.NewsAPIKey == "" {
 		return fmt.Errorf("NEWS_API_KEY is required")
 	}
@@ -92,6 +383,86 @@ func (c *Config) validate() error {
 		return fmt.Errorf("MAX_ARTICLES must be between 1 and 100")
 	}
 
+	if c.RetryAttempts < 1 {
+		return fmt.Errorf("RETRY_ATTEMPTS must be at least 1")
+	}
+
+	if c.FeedConcurrency <= 0 {
+		return fmt.Errorf("FEED_CONCURRENCY must be at least 1")
+	}
+
+	if c.WebSubCallbackURL != "" {
+		if parsed, err := url.Parse(c.WebSubCallbackURL); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return fmt.Errorf("WEBSUB_CALLBACK_URL must be an absolute http(s) URL")
+		}
+		if c.PollInterval <= 0 {
+			return fmt.Errorf("POLL_INTERVAL must be positive")
+		}
+	}
+
+	if c.ArticleMaxCrawlDelay < 0 || c.ArticleRobotsTTL < 0 {
+		return fmt.Errorf("ARTICLE_MAX_CRAWL_DELAY and ARTICLE_ROBOTS_TTL must not be negative")
+	}
+
+	if c.FeedMaxItems <= 0 {
+		return fmt.Errorf("FEED_MAX_ITEMS must be at least 1")
+	}
+
+	if c.AniListEnabled && c.AniListWindow <= 0 {
+		return fmt.Errorf("ANILIST_WINDOW must be positive")
+	}
+
+	if _, err := time.LoadLocation(c.AniListTimezone); c.AniListEnabled && err != nil {
+		return fmt.Errorf("ANILIST_TIMEZONE must be an IANA time zone such as Asia/Colombo: %w", err)
+	}
+
+	if err := c.validateHistory(); err != nil {
+		return err
+	}
+
+	if c.ReservationTimeout <= 0 {
+		return fmt.Errorf("RESERVATION_TIMEOUT must be positive")
+	}
+
+	if c.CycleLockWait < 0 {
+		return fmt.Errorf("CYCLE_LOCK_WAIT must not be negative")
+	}
+
+	switch c.HTTPReplayMode {
+	case "off", "record", "replay":
+	default:
+		return fmt.Errorf("HTTP_REPLAY_MODE must be off, record or replay")
+	}
+
+	if c.ClusterSimilarity < 0 || c.ClusterSimilarity > 1 {
+		return fmt.Errorf("CLUSTER_SIMILARITY must be between 0 and 1")
+	}
+
+	return nil
+}
+
+// validateHistory checks the settings shared by Load and LoadHistory
+func (c *Config) validateHistory() error {
+	switch c.HistoryBackend {
+	case "file", "bolt":
+	default:
+		return fmt.Errorf("HISTORY_BACKEND must be file or bolt")
+	}
+
+	if c.HistoryRetention < 0 {
+		return fmt.Errorf("HISTORY_RETENTION_DAYS must not be negative")
+	}
+
+	if c.FeedMaxAge < 0 {
+		return fmt.Errorf("FEED_MAX_AGE must not be negative")
+	}
+
+	// Items are only fetched up to FEED_MAX_AGE old, so keys kept at least
+	// that long are enough to stop reposts
+	if c.HistoryRetention > 0 && (c.FeedMaxAge == 0 || c.HistoryRetention <= c.FeedMaxAge) {
+		return fmt.Errorf("HISTORY_RETENTION_DAYS must be longer than FEED_MAX_AGE, which must be set")
+	}
+
 	return nil
 }
 
@@ -122,6 +493,36 @@ func getEnvAsInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
+// getEnvAsIntSlice parses a comma-separated list of integers, skipping invalid entries
+func getEnvAsIntSlice(key string) []int {
+	var values []int
+	for _, part := range strings.Split(os.Getenv(key), ",") {
+		if intValue, err := strconv.Atoi(strings.TrimSpace(part)); err == nil {
+			values = append(values, intValue)
+		}
+	}
+	return values
+}
+
+func getEnvAsStringSlice(key string) []string {
+	var values []string
+	for _, part := range strings.Split(os.Getenv(key), ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			values = append(values, part)
+		}
+	}
+	return values
+}
+
+func getEnvAsBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+	}
+	return defaultValue
+}
+
 func getEnvAsInt64(key string, defaultValue int64) int64 {
 	if value := os.Getenv(key); value != "" {
 		if intValue, err := strconv.ParseInt(value, 10, 64); err == nil {
@@ -131,6 +532,15 @@ func getEnvAsInt64(key string, defaultValue int64) int64 {
 	return defaultValue
 }
 
+func getEnvAsFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if floatValue, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatValue
+		}
+	}
+	return defaultValue
+}
+
 func getEnvAsDuration(key string, defaultValue string) time.Duration {
 	if value := os.Getenv(key); value != "" {
 		if duration, err := time.ParseDuration(value); err == nil {
@@ -0,0 +1,243 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"go-test/internal/atomicfile"
+)
+
+// FeedConfig describes a single RSS feed in the feed registry
+type FeedConfig struct {
+	URL      string   `json:"url"`
+	Name     string   `json:"name"`
+	Language string   `json:"language,omitempty"`
+	Enabled  *bool    `json:"enabled,omitempty"`
+	Weight   float64  `json:"weight,omitempty"`
+	Keywords []string `json:"keywords,omitempty"`
+	Category string   `json:"category,omitempty"`
+}
+
+// IsEnabled reports whether the feed should be fetched; feeds are enabled unless disabled explicitly
+func (f FeedConfig) IsEnabled() bool {
+	return f.Enabled == nil || *f.Enabled
+}
+
+// FeedCategory groups feeds so a whole group can be switched off at once
+type FeedCategory struct {
+	Name    string `json:"name"`
+	Enabled *bool  `json:"enabled,omitempty"`
+}
+
+// IsEnabled reports whether feeds in the category should be fetched; categories are enabled unless disabled explicitly
+func (c FeedCategory) IsEnabled() bool {
+	return c.Enabled == nil || *c.Enabled
+}
+
+// FeedRegistry is the declarative list of feeds the RSS fetcher polls
+type FeedRegistry struct {
+	Categories []FeedCategory `json:"categories,omitempty"`
+	Feeds      []FeedConfig   `json:"feeds"`
+}
+
+// LoadFeedRegistry reads and validates a feed registry from a JSON file
+func LoadFeedRegistry(path string) (*FeedRegistry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read feed registry %s: %w", path, err)
+	}
+
+	var registry FeedRegistry
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&registry); err != nil {
+		return nil, fmt.Errorf("failed to parse feed registry %s: %w", path, err)
+	}
+
+	if err := registry.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid feed registry %s: %w", path, err)
+	}
+
+	return &registry, nil
+}
+
+// SaveFeedRegistry validates a registry and writes it to path. The file is
+// replaced atomically through a uniquely named temp file, so the fetcher
+// never reads a half-written registry and two CLI runs saving at once never
+// rename each other's copy.
+func SaveFeedRegistry(path string, registry *FeedRegistry) error {
+	if err := registry.Validate(); err != nil {
+		return fmt.Errorf("invalid feed registry: %w", err)
+	}
+
+	data, err := json.MarshalIndent(registry, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode feed registry: %w", err)
+	}
+
+	if err := atomicfile.WriteFile(path, append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write feed registry %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// Validate checks every feed entry and reports all problems at once
+func (r *FeedRegistry) Validate() error {
+	var problems []error
+	seen := make(map[string]int)
+
+	if len(r.Feeds) == 0 {
+		problems = append(problems, fmt.Errorf("no feeds defined"))
+	}
+
+	categories := make(map[string]int)
+	for i, category := range r.Categories {
+		key := strings.ToLower(strings.TrimSpace(category.Name))
+		if key == "" {
+			problems = append(problems, fmt.Errorf("categories[%d]: name is required", i))
+		} else if first, ok := categories[key]; ok {
+			problems = append(problems, fmt.Errorf("categories[%d]: duplicate name %q (already defined in categories[%d])", i, category.Name, first))
+		} else {
+			categories[key] = i
+		}
+	}
+
+	for i, feed := range r.Feeds {
+		prefix := fmt.Sprintf("feeds[%d]", i)
+
+		if strings.TrimSpace(feed.URL) == "" {
+			problems = append(problems, fmt.Errorf("%s: url is required", prefix))
+		} else if parsed, err := url.Parse(feed.URL); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			problems = append(problems, fmt.Errorf("%s: url %q must be an absolute http(s) URL", prefix, feed.URL))
+		} else {
+			key := feedKey(feed.URL)
+			if first, ok := seen[key]; ok {
+				problems = append(problems, fmt.Errorf("%s: duplicate url %q (already defined in feeds[%d])", prefix, feed.URL, first))
+			} else {
+				seen[key] = i
+			}
+		}
+
+		if strings.TrimSpace(feed.Name) == "" {
+			problems = append(problems, fmt.Errorf("%s: name is required", prefix))
+		}
+
+		if feed.Weight < 0 {
+			problems = append(problems, fmt.Errorf("%s: weight must not be negative", prefix))
+		}
+
+		for j, keyword := range feed.Keywords {
+			if strings.TrimSpace(keyword) == "" {
+				problems = append(problems, fmt.Errorf("%s: keywords[%d] is empty", prefix, j))
+			}
+		}
+	}
+
+	return errors.Join(problems...)
+}
+
+// EnabledFeeds returns the feeds that should be fetched: the feed itself
+// and its category, if the category is declared, must both be enabled
+func (r *FeedRegistry) EnabledFeeds() []FeedConfig {
+	var feeds []FeedConfig
+	for _, feed := range r.Feeds {
+		if !feed.IsEnabled() {
+			continue
+		}
+		if category := r.Category(feed.Category); category != nil && !category.IsEnabled() {
+			continue
+		}
+		feeds = append(feeds, feed)
+	}
+	return feeds
+}
+
+// Category returns the declared category with the given name, matched
+// case-insensitively, or nil if it is not declared
+func (r *FeedRegistry) Category(name string) *FeedCategory {
+	if strings.TrimSpace(name) == "" {
+		return nil
+	}
+	for i := range r.Categories {
+		if strings.EqualFold(r.Categories[i].Name, strings.TrimSpace(name)) {
+			return &r.Categories[i]
+		}
+	}
+	return nil
+}
+
+// CategoryNames returns the declared categories followed by any category
+// that feeds use without declaring it, in first-seen order
+func (r *FeedRegistry) CategoryNames() []string {
+	var names []string
+	for _, category := range r.Categories {
+		names = append(names, category.Name)
+	}
+	for _, feed := range r.Feeds {
+		if feed.Category != "" && r.Category(feed.Category) == nil && !containsFold(names, feed.Category) {
+			names = append(names, feed.Category)
+		}
+	}
+	return names
+}
+
+// SetCategoryEnabled switches a whole category on or off, declaring it if needed
+func (r *FeedRegistry) SetCategoryEnabled(name string, enabled bool) {
+	category := r.Category(name)
+	if category == nil {
+		r.Categories = append(r.Categories, FeedCategory{Name: strings.TrimSpace(name)})
+		category = &r.Categories[len(r.Categories)-1]
+	}
+	category.Enabled = &enabled
+}
+
+// Merge adds feeds to the registry. Feeds whose URL is already registered
+// keep their settings and only take the incoming category when they have
+// none. New categories are declared enabled. It returns how many feeds were
+// added and how many existing feeds were updated.
+func (r *FeedRegistry) Merge(feeds []FeedConfig) (added, updated int) {
+	index := make(map[string]int, len(r.Feeds))
+	for i, feed := range r.Feeds {
+		index[feedKey(feed.URL)] = i
+	}
+
+	for _, feed := range feeds {
+		if feed.Category != "" && r.Category(feed.Category) == nil {
+			r.Categories = append(r.Categories, FeedCategory{Name: feed.Category})
+		}
+
+		if i, ok := index[feedKey(feed.URL)]; ok {
+			if r.Feeds[i].Category == "" && feed.Category != "" {
+				r.Feeds[i].Category = feed.Category
+				updated++
+			}
+			continue
+		}
+
+		index[feedKey(feed.URL)] = len(r.Feeds)
+		r.Feeds = append(r.Feeds, feed)
+		added++
+	}
+
+	return added, updated
+}
+
+// feedKey normalizes a feed URL for duplicate detection
+func feedKey(feedURL string) string {
+	return strings.ToLower(strings.TrimSuffix(strings.TrimSpace(feedURL), "/"))
+}
+
+func containsFold(list []string, value string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+	return false
+}
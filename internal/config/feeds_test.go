@@ -0,0 +1,177 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadFeedRegistry_Default(t *testing.T) {
+	registry, err := LoadFeedRegistry(filepath.Join("..", "..", "configs", "feeds.json"))
+	if err != nil {
+		t.Fatalf("LoadFeedRegistry() error = %v", err)
+	}
+
+	if len(registry.EnabledFeeds()) == 0 {
+		t.Error("default registry has no enabled feeds")
+	}
+}
+
+func TestLoadFeedRegistry_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{"malformed json", `{"feeds": [`, "failed to parse"},
+		{"unknown field", `{"feeds": [{"url": "https://a.com/rss", "name": "A", "colour": "red"}]}`, "unknown field"},
+		{"no feeds", `{"feeds": []}`, "no feeds defined"},
+		{"missing url", `{"feeds": [{"name": "A"}]}`, "feeds[0]: url is required"},
+		{"relative url", `{"feeds": [{"url": "/rss.xml", "name": "A"}]}`, "must be an absolute http(s) URL"},
+		{"missing name", `{"feeds": [{"url": "https://a.com/rss"}]}`, "feeds[0]: name is required"},
+		{"negative weight", `{"feeds": [{"url": "https://a.com/rss", "name": "A", "weight": -1}]}`, "weight must not be negative"},
+		{"empty keyword", `{"feeds": [{"url": "https://a.com/rss", "name": "A", "keywords": [" "]}]}`, "keywords[0] is empty"},
+		{
+			"duplicate category",
+			`{"categories": [{"name": "news"}, {"name": "News"}], "feeds": [{"url": "https://a.com/rss", "name": "A"}]}`,
+			"categories[1]: duplicate name",
+		},
+		{
+			"duplicate url",
+			`{"feeds": [{"url": "https://a.com/rss", "name": "A"}, {"url": "https://A.com/rss/", "name": "B"}]}`,
+			"feeds[1]: duplicate url",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "feeds.json")
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := LoadFeedRegistry(path)
+			if err == nil {
+				t.Fatalf("LoadFeedRegistry() error = nil; want %q", tt.wantErr)
+			}
+
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("LoadFeedRegistry() error = %q; want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFeedRegistry_EnabledFeeds(t *testing.T) {
+	disabled := false
+	registry := &FeedRegistry{Feeds: []FeedConfig{
+		{URL: "https://a.com/rss", Name: "A"},
+		{URL: "https://b.com/rss", Name: "B", Enabled: &disabled},
+	}}
+
+	feeds := registry.EnabledFeeds()
+	if len(feeds) != 1 || feeds[0].Name != "A" {
+		t.Errorf("EnabledFeeds() = %+v; want only feed A", feeds)
+	}
+}
+
+func TestFeedRegistry_Categories(t *testing.T) {
+	registry := &FeedRegistry{
+		Categories: []FeedCategory{{Name: "news"}},
+		Feeds: []FeedConfig{
+			{URL: "https://a.com/rss", Name: "A", Category: "news"},
+			{URL: "https://b.com/rss", Name: "B", Category: "reviews"},
+			{URL: "https://c.com/rss", Name: "C"},
+		},
+	}
+
+	registry.SetCategoryEnabled("News", false)
+	if got := feedNames(registry.EnabledFeeds()); got != "B,C" {
+		t.Errorf("EnabledFeeds() with news disabled = %s; want B,C", got)
+	}
+
+	registry.SetCategoryEnabled("reviews", false)
+	if got := feedNames(registry.EnabledFeeds()); got != "C" {
+		t.Errorf("EnabledFeeds() with reviews disabled = %s; want C", got)
+	}
+
+	if len(registry.Categories) != 2 {
+		t.Errorf("Categories = %+v; want news and a newly declared reviews", registry.Categories)
+	}
+
+	registry.SetCategoryEnabled("news", true)
+	if got := feedNames(registry.EnabledFeeds()); got != "A,C" {
+		t.Errorf("EnabledFeeds() with news re-enabled = %s; want A,C", got)
+	}
+}
+
+func TestFeedRegistry_Merge(t *testing.T) {
+	weight := 5.0
+	registry := &FeedRegistry{Feeds: []FeedConfig{
+		{URL: "https://a.com/rss", Name: "A", Weight: weight},
+	}}
+
+	added, updated := registry.Merge([]FeedConfig{
+		{URL: "https://A.com/rss/", Name: "A from reader", Category: "news"},
+		{URL: "https://b.com/rss", Name: "B", Category: "industry"},
+		{URL: "https://b.com/rss", Name: "B again", Category: "industry"},
+	})
+
+	if added != 1 || updated != 1 {
+		t.Errorf("Merge() = %d added, %d updated; want 1, 1", added, updated)
+	}
+	if a := registry.Feeds[0]; a.Name != "A" || a.Weight != weight || a.Category != "news" {
+		t.Errorf("existing feed = %+v; want its settings kept and the category filled in", a)
+	}
+	if got := registry.CategoryNames(); strings.Join(got, ",") != "news,industry" {
+		t.Errorf("CategoryNames() = %v; want [news industry]", got)
+	}
+	if err := registry.Validate(); err != nil {
+		t.Errorf("Validate() after Merge() error = %v", err)
+	}
+}
+
+func feedNames(feeds []FeedConfig) string {
+	var names []string
+	for _, feed := range feeds {
+		names = append(names, feed.Name)
+	}
+	return strings.Join(names, ",")
+}
+
+func TestLoadRelevanceConfig_Default(t *testing.T) {
+	cfg, err := LoadRelevanceConfig(filepath.Join("..", "..", "configs", "relevance.json"))
+	if err != nil {
+		t.Fatalf("LoadRelevanceConfig() error = %v", err)
+	}
+
+	if got := cfg.ThresholdFor("Anime News Network"); got != 1 {
+		t.Errorf("ThresholdFor(Anime News Network) = %v; want 1", got)
+	}
+
+	if got := cfg.ThresholdFor("Unknown Source"); got != cfg.Threshold {
+		t.Errorf("ThresholdFor(Unknown Source) = %v; want default %v", got, cfg.Threshold)
+	}
+}
+
+func TestRelevanceConfig_Validate(t *testing.T) {
+	cfg := &RelevanceConfig{
+		Threshold:        0,
+		TitleMultiplier:  1,
+		BodyMultiplier:   1,
+		Positive:         map[string]float64{"anime": -1},
+		SourceThresholds: map[string]float64{"ANN": 0},
+	}
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("Validate() error = nil; want problems")
+	}
+
+	for _, want := range []string{"threshold must be positive", `positive["anime"]: weight must be positive`, `source_thresholds["ANN"]`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Validate() error = %q; want it to contain %q", err, want)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package config
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// opmlDocument is the subset of OPML 2.0 that feed readers use for subscription lists
+type opmlDocument struct {
+	XMLName xml.Name `xml:"opml"`
+	Version string   `xml:"version,attr"`
+	Head    opmlHead `xml:"head"`
+	Body    opmlBody `xml:"body"`
+}
+
+type opmlHead struct {
+	Title       string `xml:"title,omitempty"`
+	DateCreated string `xml:"dateCreated,omitempty"`
+}
+
+type opmlBody struct {
+	Outlines []opmlOutline `xml:"outline"`
+}
+
+type opmlOutline struct {
+	Text     string        `xml:"text,attr"`
+	Title    string        `xml:"title,attr,omitempty"`
+	Type     string        `xml:"type,attr,omitempty"`
+	XMLURL   string        `xml:"xmlUrl,attr,omitempty"`
+	HTMLURL  string        `xml:"htmlUrl,attr,omitempty"`
+	Language string        `xml:"language,attr,omitempty"`
+	Category string        `xml:"category,attr,omitempty"`
+	Outlines []opmlOutline `xml:"outline"`
+}
+
+// ParseOPML reads the feed subscriptions from an OPML document. Folders
+// (outlines without an xmlUrl) become categories; a feed nested in several
+// folders takes the innermost one. Without a folder, the first entry of the
+// outline's category attribute is used instead.
+func ParseOPML(r io.Reader) ([]FeedConfig, error) {
+	var doc opmlDocument
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to parse OPML: %w", err)
+	}
+
+	var feeds []FeedConfig
+	var walk func(outlines []opmlOutline, category string)
+	walk = func(outlines []opmlOutline, category string) {
+		for _, outline := range outlines {
+			name := strings.TrimSpace(outline.Text)
+			if name == "" {
+				name = strings.TrimSpace(outline.Title)
+			}
+
+			if strings.TrimSpace(outline.XMLURL) == "" {
+				walk(outline.Outlines, name)
+				continue
+			}
+
+			feed := FeedConfig{
+				URL:      strings.TrimSpace(outline.XMLURL),
+				Name:     name,
+				Language: strings.TrimSpace(outline.Language),
+				Category: category,
+			}
+			if feed.Category == "" {
+				feed.Category = opmlCategory(outline.Category)
+			}
+			if feed.Name == "" {
+				if parsed, err := url.Parse(feed.URL); err == nil {
+					feed.Name = parsed.Host
+				}
+			}
+
+			feeds = append(feeds, feed)
+		}
+	}
+	walk(doc.Body.Outlines, "")
+
+	if len(feeds) == 0 {
+		return nil, fmt.Errorf("OPML contains no feeds")
+	}
+
+	return feeds, nil
+}
+
+// opmlCategory takes the first entry of an OPML category attribute,
+// e.g. "/news/industry,/reviews" becomes "news/industry"
+func opmlCategory(attr string) string {
+	first, _, _ := strings.Cut(attr, ",")
+	return strings.Trim(strings.TrimSpace(first), "/")
+}
+
+// WriteOPML writes the registry as an OPML 2.0 document with one folder per
+// category. Uncategorized feeds are written at the top level. OPML has no
+// notion of disabled feeds or weights, so those settings stay in the registry.
+func WriteOPML(w io.Writer, registry *FeedRegistry, title string) error {
+	doc := opmlDocument{
+		Version: "2.0",
+		Head: opmlHead{
+			Title:       title,
+			DateCreated: time.Now().UTC().Format(time.RFC1123Z),
+		},
+	}
+
+	folders := make(map[string]int)
+	for _, name := range registry.CategoryNames() {
+		folders[strings.ToLower(name)] = len(doc.Body.Outlines)
+		doc.Body.Outlines = append(doc.Body.Outlines, opmlOutline{Text: name, Title: name})
+	}
+
+	for _, feed := range registry.Feeds {
+		outline := opmlOutline{
+			Text:     feed.Name,
+			Title:    feed.Name,
+			Type:     "rss",
+			XMLURL:   feed.URL,
+			Language: feed.Language,
+		}
+
+		if i, ok := folders[strings.ToLower(feed.Category)]; ok && feed.Category != "" {
+			doc.Body.Outlines[i].Outlines = append(doc.Body.Outlines[i].Outlines, outline)
+		} else {
+			doc.Body.Outlines = append(doc.Body.Outlines, outline)
+		}
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return fmt.Errorf("failed to write OPML: %w", err)
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return fmt.Errorf("failed to write OPML: %w", err)
+	}
+
+	if _, err := io.WriteString(w, "\n"); err != nil {
+		return fmt.Errorf("failed to write OPML: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,119 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseOPML(t *testing.T) {
+	file, err := os.Open(filepath.Join("testdata", "reader.opml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	feeds, err := ParseOPML(file)
+	if err != nil {
+		t.Fatalf("ParseOPML() error = %v", err)
+	}
+
+	want := []FeedConfig{
+		{URL: "https://www.animenewsnetwork.com/all/rss.xml", Name: "Anime News Network", Category: "News"},
+		{URL: "https://www.otakunews.com/feed/", Name: "Otaku News", Language: "en", Category: "Japan"},
+		{URL: "https://www.animefeminist.com/feed/", Name: "Anime Feminist", Category: "Reviews"},
+		{URL: "https://example.com/industry.xml", Name: "Anime Business", Category: "industry/licensing"},
+		{URL: "https://blog.example.org/rss", Name: "blog.example.org"},
+	}
+
+	if !reflect.DeepEqual(feeds, want) {
+		t.Errorf("ParseOPML() =\n%+v\nwant\n%+v", feeds, want)
+	}
+}
+
+func TestParseOPML_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{"not xml", "feeds", "failed to parse OPML"},
+		{"no feeds", `<opml version="2.0"><body><outline text="News"/></body></opml>`, "no feeds"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseOPML(strings.NewReader(tt.content))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParseOPML() error = %v; want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWriteOPML_RoundTrip(t *testing.T) {
+	registry, err := LoadFeedRegistry(filepath.Join("..", "..", "configs", "feeds.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry.Feeds = append(registry.Feeds, FeedConfig{URL: "https://c.com/rss", Name: "Uncategorized"})
+
+	var buf bytes.Buffer
+	if err := WriteOPML(&buf, registry, "Test"); err != nil {
+		t.Fatalf("WriteOPML() error = %v", err)
+	}
+
+	feeds, err := ParseOPML(&buf)
+	if err != nil {
+		t.Fatalf("ParseOPML() of exported OPML error = %v", err)
+	}
+
+	if len(feeds) != len(registry.Feeds) {
+		t.Fatalf("round trip returned %d feeds; want %d", len(feeds), len(registry.Feeds))
+	}
+	for _, feed := range feeds {
+		found := false
+		for _, original := range registry.Feeds {
+			if original.URL == feed.URL {
+				found = true
+				if feed.Name != original.Name || feed.Category != original.Category || feed.Language != original.Language {
+					t.Errorf("round trip of %s = %+v; want %+v", feed.URL, feed, original)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("round trip returned unknown feed %s", feed.URL)
+		}
+	}
+}
+
+func TestSaveFeedRegistry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "feeds.json")
+	disabled := false
+	registry := &FeedRegistry{
+		Categories: []FeedCategory{{Name: "reviews", Enabled: &disabled}},
+		Feeds:      []FeedConfig{{URL: "https://a.com/rss", Name: "A", Category: "reviews"}},
+	}
+
+	if err := SaveFeedRegistry(path, registry); err != nil {
+		t.Fatalf("SaveFeedRegistry() error = %v", err)
+	}
+
+	loaded, err := LoadFeedRegistry(path)
+	if err != nil {
+		t.Fatalf("LoadFeedRegistry() error = %v", err)
+	}
+	if !reflect.DeepEqual(loaded, registry) {
+		t.Errorf("LoadFeedRegistry() = %+v; want %+v", loaded, registry)
+	}
+	if leftovers, _ := filepath.Glob(path + ".*.tmp"); len(leftovers) != 0 {
+		t.Errorf("SaveFeedRegistry() left temp files %v", leftovers)
+	}
+
+	if err := SaveFeedRegistry(path, &FeedRegistry{}); err == nil {
+		t.Error("SaveFeedRegistry() of an empty registry error = nil; want validation error")
+	}
+}
@@ -0,0 +1,96 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// RelevanceConfig holds the keyword weights used to decide whether a feed
+// item is anime news. Positive keywords add to an item's score, negative
+// keywords subtract from it, and matches in the title count more than
+// matches in the body.
+type RelevanceConfig struct {
+	Threshold         float64            `json:"threshold"`
+	TitleMultiplier   float64            `json:"title_multiplier"`
+	BodyMultiplier    float64            `json:"body_multiplier"`
+	FeedKeywordWeight float64            `json:"feed_keyword_weight"`
+	Positive          map[string]float64 `json:"positive"`
+	Negative          map[string]float64 `json:"negative"`
+	SourceThresholds  map[string]float64 `json:"source_thresholds,omitempty"`
+}
+
+// LoadRelevanceConfig reads and validates relevance scoring settings from a JSON file
+func LoadRelevanceConfig(path string) (*RelevanceConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read relevance config %s: %w", path, err)
+	}
+
+	var cfg RelevanceConfig
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse relevance config %s: %w", path, err)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid relevance config %s: %w", path, err)
+	}
+
+	return &cfg, nil
+}
+
+// Validate checks the scoring settings and reports all problems at once
+func (c *RelevanceConfig) Validate() error {
+	var problems []error
+
+	if c.Threshold <= 0 {
+		problems = append(problems, fmt.Errorf("threshold must be positive"))
+	}
+	if c.TitleMultiplier <= 0 {
+		problems = append(problems, fmt.Errorf("title_multiplier must be positive"))
+	}
+	if c.BodyMultiplier <= 0 {
+		problems = append(problems, fmt.Errorf("body_multiplier must be positive"))
+	}
+	if c.FeedKeywordWeight < 0 {
+		problems = append(problems, fmt.Errorf("feed_keyword_weight must not be negative"))
+	}
+	if len(c.Positive) == 0 {
+		problems = append(problems, fmt.Errorf("positive keywords are required"))
+	}
+
+	for _, list := range []struct {
+		name     string
+		keywords map[string]float64
+	}{{"positive", c.Positive}, {"negative", c.Negative}} {
+		for keyword, weight := range list.keywords {
+			if strings.TrimSpace(keyword) == "" {
+				problems = append(problems, fmt.Errorf("%s: empty keyword", list.name))
+			}
+			if weight <= 0 {
+				problems = append(problems, fmt.Errorf("%s[%q]: weight must be positive", list.name, keyword))
+			}
+		}
+	}
+
+	for source, threshold := range c.SourceThresholds {
+		if threshold <= 0 {
+			problems = append(problems, fmt.Errorf("source_thresholds[%q]: threshold must be positive", source))
+		}
+	}
+
+	return errors.Join(problems...)
+}
+
+// ThresholdFor returns the score an item from source needs to pass
+func (c *RelevanceConfig) ThresholdFor(source string) float64 {
+	if threshold, ok := c.SourceThresholds[source]; ok {
+		return threshold
+	}
+	return c.Threshold
+}
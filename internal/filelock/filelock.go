@@ -0,0 +1,41 @@
+// Package filelock provides advisory, whole-file locks shared between
+// processes, and an instance lock that keeps a task to one process at a
+// time. Locks are released by the operating system when a process dies, so
+// a crash never leaves a lock held.
+package filelock
+
+import (
+	"errors"
+	"os"
+	"time"
+)
+
+// ErrLocked is returned when another process holds the lock
+var ErrLocked = errors.New("file is locked by another process")
+
+// pollInterval is how often Lock retries while waiting
+const pollInterval = 50 * time.Millisecond
+
+// Lock takes an exclusive lock on f, waiting up to timeout for another
+// process to release it. It returns ErrLocked if the wait runs out.
+func Lock(f *os.File, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		err := TryLock(f)
+		if !errors.Is(err, ErrLocked) || !time.Now().Before(deadline) {
+			return err
+		}
+		time.Sleep(pollInterval)
+	}
+}
+
+// TryLock takes an exclusive lock on f without waiting. It returns
+// ErrLocked if another process holds it.
+func TryLock(f *os.File) error {
+	return tryLock(f)
+}
+
+// Unlock releases a lock taken with Lock or TryLock
+func Unlock(f *os.File) error {
+	return unlock(f)
+}
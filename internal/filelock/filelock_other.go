@@ -0,0 +1,15 @@
+//go:build !unix && !windows
+
+package filelock
+
+import "os"
+
+// Platforms without file locking run unlocked; the app targets Unix and Windows
+
+func tryLock(f *os.File) error {
+	return nil
+}
+
+func unlock(f *os.File) error {
+	return nil
+}
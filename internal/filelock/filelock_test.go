@@ -0,0 +1,101 @@
+package filelock
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLock_ExcludesOtherHandles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.lock")
+
+	first, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first.Close()
+	second, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer second.Close()
+
+	if err := Lock(first, time.Second); err != nil {
+		t.Fatalf("Lock() error = %v", err)
+	}
+	if err := Lock(second, 100*time.Millisecond); !errors.Is(err, ErrLocked) {
+		t.Fatalf("Lock() on a held file = %v, want ErrLocked", err)
+	}
+
+	released := make(chan struct{})
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		Unlock(first)
+		close(released)
+	}()
+
+	if err := Lock(second, 5*time.Second); err != nil {
+		t.Fatalf("Lock() after release error = %v", err)
+	}
+	<-released
+	Unlock(second)
+}
+
+func TestAcquireInstance(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cycle.lock")
+
+	lock, err := AcquireInstance(path, 0)
+	if err != nil {
+		t.Fatalf("AcquireInstance() error = %v", err)
+	}
+	if lock.Stale != nil {
+		t.Errorf("fresh lock reported stale owner %v", lock.Stale)
+	}
+
+	_, err = AcquireInstance(path, 50*time.Millisecond)
+	var held *HeldError
+	if !errors.As(err, &held) {
+		t.Fatalf("second AcquireInstance() error = %v, want *HeldError", err)
+	}
+	if held.Owner.PID != os.Getpid() {
+		t.Errorf("held by PID %d, want %d", held.Owner.PID, os.Getpid())
+	}
+	if !errors.Is(err, ErrLocked) {
+		t.Error("HeldError should match ErrLocked")
+	}
+
+	if err := lock.Release(); err != nil {
+		t.Fatalf("Release() error = %v", err)
+	}
+
+	lock, err = AcquireInstance(path, 0)
+	if err != nil {
+		t.Fatalf("AcquireInstance() after release error = %v", err)
+	}
+	if lock.Stale != nil {
+		t.Errorf("released lock reported stale owner %v", lock.Stale)
+	}
+	lock.Release()
+}
+
+func TestAcquireInstance_ReportsStaleOwner(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cycle.lock")
+
+	// A crashed run leaves its owner record behind without holding the lock
+	stale := `{"pid":424242,"host":"old-host","started_at":"2024-01-02T03:04:05Z"}`
+	if err := os.WriteFile(path, []byte(stale), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	lock, err := AcquireInstance(path, 0)
+	if err != nil {
+		t.Fatalf("AcquireInstance() error = %v", err)
+	}
+	defer lock.Release()
+
+	if lock.Stale == nil || lock.Stale.PID != 424242 || lock.Stale.Host != "old-host" {
+		t.Errorf("Stale = %v, want PID 424242 on old-host", lock.Stale)
+	}
+}
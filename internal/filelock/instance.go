@@ -0,0 +1,131 @@
+package filelock
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Owner describes the process holding an instance lock
+type Owner struct {
+	PID       int       `json:"pid"`
+	Host      string    `json:"host"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+func (o Owner) String() string {
+	return fmt.Sprintf("PID %d on %s, started %s", o.PID, o.Host, o.StartedAt.Format(time.RFC3339))
+}
+
+// HeldError is returned by AcquireInstance when another process holds the lock
+type HeldError struct {
+	Path  string
+	Owner Owner
+}
+
+func (e *HeldError) Error() string {
+	if e.Owner.PID == 0 {
+		return fmt.Sprintf("another process holds the lock %s", e.Path)
+	}
+	return fmt.Sprintf("another process (%s) holds the lock %s", e.Owner, e.Path)
+}
+
+// Is lets errors.Is(err, ErrLocked) match a HeldError
+func (e *HeldError) Is(target error) bool {
+	return target == ErrLocked
+}
+
+// InstanceLock keeps a task to one process at a time. The lock file records
+// the owner, so a process that finds it taken can say who holds it.
+type InstanceLock struct {
+	file *os.File
+
+	// Stale is the previous owner when it exited without releasing the
+	// lock, e.g. after a crash, and nil otherwise
+	Stale *Owner
+}
+
+// AcquireInstance takes the instance lock at path, waiting up to wait for
+// the current owner to finish. The lock file outlives a crashed owner, but
+// the operating system drops the lock itself, so a stale file never blocks
+// a new process.
+func AcquireInstance(path string, wait time.Duration) (*InstanceLock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create lock directory: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+
+	if err := Lock(file, wait); err != nil {
+		owner, _ := readOwner(file)
+		file.Close()
+		if errors.Is(err, ErrLocked) {
+			return nil, &HeldError{Path: path, Owner: owner}
+		}
+		return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+	}
+
+	lock := &InstanceLock{file: file}
+	if owner, err := readOwner(file); err == nil && owner.PID != 0 {
+		lock.Stale = &owner
+	}
+
+	if err := lock.writeOwner(); err != nil {
+		lock.Release()
+		return nil, err
+	}
+
+	return lock, nil
+}
+
+// Release gives up the lock and clears the owner, so the next process does
+// not report it as stale
+func (l *InstanceLock) Release() error {
+	if l == nil || l.file == nil {
+		return nil
+	}
+
+	l.file.Truncate(0)
+	err := Unlock(l.file)
+	if closeErr := l.file.Close(); err == nil {
+		err = closeErr
+	}
+	l.file = nil
+	return err
+}
+
+func (l *InstanceLock) writeOwner() error {
+	host, _ := os.Hostname()
+	data, err := json.Marshal(Owner{PID: os.Getpid(), Host: host, StartedAt: time.Now()})
+	if err != nil {
+		return fmt.Errorf("failed to encode lock owner: %w", err)
+	}
+
+	// The file stays open and locked, so it is rewritten in place
+	if err := l.file.Truncate(0); err != nil {
+		return fmt.Errorf("failed to write lock file: %w", err)
+	}
+	if _, err := l.file.WriteAt(append(data, '\n'), 0); err != nil {
+		return fmt.Errorf("failed to write lock file: %w", err)
+	}
+	return nil
+}
+
+func readOwner(file *os.File) (Owner, error) {
+	var owner Owner
+	data, err := os.ReadFile(file.Name())
+	if err != nil {
+		return owner, err
+	}
+	if len(data) == 0 {
+		return owner, nil
+	}
+	err = json.Unmarshal(data, &owner)
+	return owner, err
+}
@@ -0,0 +1,380 @@
+// Package httpreplay records HTTP traffic to JSON cassettes and replays it,
+// so services that talk to external APIs can run in tests and CI without a
+// network. Secrets such as API keys, bot tokens and auth headers are
+// redacted before anything is written to disk.
+package httpreplay
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"go-test/internal/atomicfile"
+)
+
+// Mode selects what a Transport does with requests
+type Mode string
+
+const (
+	// ModeOff passes requests through untouched
+	ModeOff Mode = "off"
+	// ModeRecord passes requests through and saves every interaction
+	ModeRecord Mode = "record"
+	// ModeReplay answers requests from the cassette without any network access
+	ModeReplay Mode = "replay"
+)
+
+// Redacted replaces secrets in recorded requests and responses
+const Redacted = "REDACTED"
+
+// secretParams are query parameters whose values are never recorded
+var secretParams = []string{"key", "apikey", "api_key", "access_token", "token", "secret", "hub.secret"}
+
+// secretHeaders are headers whose values are never recorded
+var secretHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key", "X-Goog-Api-Key"}
+
+// telegramTokenPattern matches the bot token in Telegram Bot API paths
+var telegramTokenPattern = regexp.MustCompile(`/bot[^/]+/`)
+
+// Cassette is the recorded traffic of one test scenario
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is one recorded request and its response
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// RecordedRequest is a redacted HTTP request
+type RecordedRequest struct {
+	Method  string      `json:"method"`
+	URL     string      `json:"url"`
+	Headers http.Header `json:"headers,omitempty"`
+	Body    Body        `json:"body,omitempty"`
+}
+
+// RecordedResponse is a redacted HTTP response
+type RecordedResponse struct {
+	Status  int         `json:"status"`
+	Headers http.Header `json:"headers,omitempty"`
+	Body    Body        `json:"body,omitempty"`
+}
+
+// Body is a message body stored as text when it is valid UTF-8 and as
+// base64 otherwise, so cassettes stay readable in code review
+type Body []byte
+
+// MarshalJSON implements json.Marshaler
+func (b Body) MarshalJSON() ([]byte, error) {
+	if utf8.Valid(b) {
+		return json.Marshal(string(b))
+	}
+	return json.Marshal(map[string]string{"base64": base64.StdEncoding.EncodeToString(b)})
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (b *Body) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*b = Body(text)
+		return nil
+	}
+
+	var encoded struct {
+		Base64 string `json:"base64"`
+	}
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return fmt.Errorf("body must be a string or {\"base64\": ...}: %w", err)
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(encoded.Base64)
+	if err != nil {
+		return fmt.Errorf("invalid base64 body: %w", err)
+	}
+	*b = decoded
+	return nil
+}
+
+// Transport is an http.RoundTripper that records or replays interactions
+type Transport struct {
+	mode Mode
+	path string
+	base http.RoundTripper
+
+	mu       sync.Mutex
+	cassette Cassette
+	keys     []string // match key of each interaction, see matchKey
+	used     []bool
+}
+
+// New creates a transport for mode using the cassette at path. base is the
+// transport used for real requests and defaults to http.DefaultTransport.
+// Replay mode requires the cassette to exist; record mode starts a new one.
+func New(mode Mode, path string, base http.RoundTripper) (*Transport, error) {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	t := &Transport{mode: mode, path: path, base: base}
+
+	if mode == ModeReplay {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read cassette: %w", err)
+		}
+		if err := json.Unmarshal(data, &t.cassette); err != nil {
+			return nil, fmt.Errorf("failed to decode cassette %s: %w", path, err)
+		}
+		t.keys = make([]string, len(t.cassette.Interactions))
+		for i, interaction := range t.cassette.Interactions {
+			t.keys[i] = matchKey(interaction.Request.Method, interaction.Request.URL, interaction.Request.Body)
+		}
+		t.used = make([]bool, len(t.cassette.Interactions))
+	}
+
+	return t, nil
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	switch t.mode {
+	case ModeReplay:
+		return t.replay(req)
+	case ModeRecord:
+		return t.record(req)
+	default:
+		return t.base.RoundTrip(req)
+	}
+}
+
+// Client returns an HTTP client that uses the transport
+func (t *Transport) Client() *http.Client {
+	return &http.Client{Transport: t}
+}
+
+// record sends the request for real and saves the redacted interaction
+func (t *Transport) record(req *http.Request) (*http.Response, error) {
+	reqBody, err := readBody(&req.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body: %w", err)
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	respBody, err := readBody(&resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	interaction := Interaction{
+		Request: RecordedRequest{
+			Method:  req.Method,
+			URL:     RedactURL(req.URL),
+			Headers: redactHeaders(req.Header),
+			Body:    redactBody(reqBody, req.Header.Get("Content-Type")),
+		},
+		Response: RecordedResponse{
+			Status:  resp.StatusCode,
+			Headers: redactHeaders(resp.Header),
+			Body:    respBody,
+		},
+	}
+
+	t.mu.Lock()
+	t.cassette.Interactions = append(t.cassette.Interactions, interaction)
+	err = t.saveLocked()
+	t.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// replay answers from the first unused interaction with the same method,
+// redacted URL and redacted body. Each interaction answers once, so a
+// request the recording did not make as often fails instead of silently
+// getting a repeated response.
+func (t *Transport) replay(req *http.Request) (*http.Response, error) {
+	reqBody, err := readBody(&req.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body: %w", err)
+	}
+	target := RedactURL(req.URL)
+	key := matchKey(req.Method, target, redactBody(reqBody, req.Header.Get("Content-Type")))
+
+	t.mu.Lock()
+	match, matches := -1, 0
+	for i := range t.cassette.Interactions {
+		if t.keys[i] != key {
+			continue
+		}
+		matches++
+		if !t.used[i] {
+			match = i
+			t.used[i] = true
+			break
+		}
+	}
+	t.mu.Unlock()
+
+	if match < 0 {
+		if matches > 0 {
+			return nil, fmt.Errorf("httpreplay: all %d recorded interactions for %s %s with this body in %s were used", matches, req.Method, target, t.path)
+		}
+		return nil, fmt.Errorf("httpreplay: no recorded interaction for %s %s with this body in %s", req.Method, target, t.path)
+	}
+
+	recorded := t.cassette.Interactions[match].Response
+	header := recorded.Headers.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", recorded.Status, http.StatusText(recorded.Status)),
+		StatusCode:    recorded.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(recorded.Body)),
+		ContentLength: int64(len(recorded.Body)),
+		Request:       req,
+	}, nil
+}
+
+// Unused returns the interactions replay never served, which usually means
+// the code under test skipped a call it was expected to make
+func (t *Transport) Unused() []Interaction {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var unused []Interaction
+	for i, used := range t.used {
+		if !used {
+			unused = append(unused, t.cassette.Interactions[i])
+		}
+	}
+	return unused
+}
+
+func (t *Transport) saveLocked() error {
+	data, err := json.MarshalIndent(t.cassette, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode cassette: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(t.path), 0755); err != nil {
+		return fmt.Errorf("failed to create cassette directory: %w", err)
+	}
+
+	if err := atomicfile.WriteFile(t.path, append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write cassette: %w", err)
+	}
+
+	return nil
+}
+
+// matchKey identifies a request by its method, redacted URL and a hash of
+// its redacted body, so calls to the same endpoint with different payloads,
+// such as two prompts to one model, get their own answers
+func matchKey(method, redactedURL string, redactedBody []byte) string {
+	sum := sha256.Sum256(redactedBody)
+	return method + " " + redactedURL + " " + hex.EncodeToString(sum[:])
+}
+
+// RedactURL returns the URL with secret query parameters and Telegram bot
+// tokens replaced. Parameters are re-encoded in sorted order so the result
+// can be used as a stable lookup key.
+func RedactURL(u *url.URL) string {
+	redacted := *u
+	redacted.Path = telegramTokenPattern.ReplaceAllString(redacted.Path, "/bot"+Redacted+"/")
+	redacted.RawPath = ""
+
+	query := redacted.Query()
+	for name := range query {
+		if isSecret(name, secretParams) {
+			query[name] = []string{Redacted}
+		}
+	}
+	redacted.RawQuery = query.Encode()
+	redacted.User = nil
+
+	return redacted.String()
+}
+
+func redactHeaders(header http.Header) http.Header {
+	if len(header) == 0 {
+		return nil
+	}
+
+	redacted := header.Clone()
+	for name := range redacted {
+		if isSecret(name, secretHeaders) {
+			redacted[name] = []string{Redacted}
+		}
+	}
+	return redacted
+}
+
+// redactBody hides secret fields of form-encoded request bodies, such as
+// the hub.secret of a WebSub subscription
+func redactBody(body []byte, contentType string) Body {
+	if !strings.HasPrefix(contentType, "application/x-www-form-urlencoded") {
+		return body
+	}
+
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		return body
+	}
+	for name := range form {
+		if isSecret(name, secretParams) {
+			form[name] = []string{Redacted}
+		}
+	}
+	return Body(form.Encode())
+}
+
+func isSecret(name string, secrets []string) bool {
+	for _, secret := range secrets {
+		if strings.EqualFold(name, secret) {
+			return true
+		}
+	}
+	return false
+}
+
+// readBody reads a body and replaces it with an in-memory copy
+func readBody(body *io.ReadCloser) ([]byte, error) {
+	if *body == nil || *body == http.NoBody {
+		return nil, nil
+	}
+
+	data, err := io.ReadAll(*body)
+	(*body).Close()
+	if err != nil {
+		return nil, err
+	}
+
+	*body = io.NopCloser(bytes.NewReader(data))
+	return data, nil
+}
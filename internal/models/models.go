@@ -0,0 +1,127 @@
+package models
+
+import "time"
+
+// Media types for MediaItem
+const (
+	MediaTypeImage = "image"
+	MediaTypeVideo = "video"
+)
+
+// MediaItem is an image or video attached to a news item. MIME type and
+// dimensions are only set when the source reported them.
+type MediaItem struct {
+	URL      string `json:"url"`
+	Type     string `json:"type"`
+	MIMEType string `json:"mime_type,omitempty"`
+	Width    int    `json:"width,omitempty"`
+	Height   int    `json:"height,omitempty"`
+}
+
+// AnimeNews represents a single anime news item collected from a feed
+type AnimeNews struct {
+	Title       string      `json:"title"`
+	Summary     string      `json:"summary"`
+	Content     string      `json:"content,omitempty"` // Extracted article body, when enabled
+	Link        string      `json:"link"`
+	GUID        string      `json:"guid,omitempty"` // Feed item GUID, when the feed provides one
+	Source      string      `json:"source"`
+	PublishedAt time.Time   `json:"published_at"`
+	Media       []MediaItem `json:"media,omitempty"` // Ranked best first
+	Score       float64     `json:"score,omitempty"` // Relevance score from the feed filter
+
+	// ReleaseAt is when the episode airs, for items from the airing schedule
+	ReleaseAt *time.Time `json:"release_at,omitempty"`
+
+	// AlternateLinks are the URLs of the same story reported by lower-priority feeds
+	AlternateLinks []string `json:"alternate_links,omitempty"`
+}
+
+// SinhalaPost represents a generated Sinhala post for a news item
+type SinhalaPost struct {
+	OriginalNews AnimeNews `json:"original_news"`
+	SinhalaText  string    `json:"sinhala_text"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// PublishedArticle represents one publish event in the published articles log.
+// Entries imported from the legacy text log only have a link, title and time.
+type PublishedArticle struct {
+	Link           string    `json:"link"`
+	AlternateLinks []string  `json:"alternate_links,omitempty"`
+	GUID           string    `json:"guid,omitempty"`
+	ContentHash    string    `json:"content_hash,omitempty"` // SHA-256 of the normalized title and summary
+	Title          string    `json:"title"`
+	Channel        string    `json:"channel,omitempty"` // e.g. "telegram:@animenews"
+	MessageID      string    `json:"message_id,omitempty"`
+	Model          string    `json:"model,omitempty"`  // AI model that wrote the post
+	Prompt         string    `json:"prompt,omitempty"` // prompt template version, e.g. "personas/casual_youth@casual_youth-1"
+	PostText       string    `json:"post_text,omitempty"`
+	Rejections     []string  `json:"rejections,omitempty"` // why earlier drafts of the post failed validation
+	PublishedAt    time.Time `json:"published_at"`
+}
+
+// ServiceStatus represents the health of a single external service
+type ServiceStatus struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// CacheStats counts the lookups of a response cache across runs
+type CacheStats struct {
+	Hits    int64 `json:"hits"`
+	Misses  int64 `json:"misses"`
+	Entries int   `json:"entries"`
+}
+
+// OrchestratorStatus represents the overall status of the orchestrator
+type OrchestratorStatus struct {
+	LastRun         time.Time          `json:"last_run"`
+	PublishedCount  int                `json:"published_count"`
+	RecentArticles  []PublishedArticle `json:"recent_articles"`
+	ServiceStatuses []ServiceStatus    `json:"service_statuses"`
+	LLMCache        *CacheStats        `json:"llm_cache,omitempty"`
+}
+
+// Source represents the publisher of a news API article
+type Source struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// Article represents a news article returned by the news API
+type Article struct {
+	ID          string      `json:"id"`
+	Title       string      `json:"title"`
+	Description string      `json:"description"`
+	Content     string      `json:"content"`
+	URL         string      `json:"url"`
+	URLToImage  string      `json:"url_to_image"`
+	PublishedAt time.Time   `json:"published_at"`
+	Source      Source      `json:"source"`
+	Author      string      `json:"author"`
+	Media       []MediaItem `json:"media,omitempty"` // Ranked best first
+}
+
+// ToAnimeNews converts a news API article into the feed news model
+func (a Article) ToAnimeNews() AnimeNews {
+	return AnimeNews{
+		Title:       a.Title,
+		Summary:     a.Description,
+		Link:        a.URL,
+		Source:      a.Source.Name,
+		PublishedAt: a.PublishedAt,
+		Media:       a.Media,
+	}
+}
+
+// AIAnalysis represents the AI analysis of an article
+type AIAnalysis struct {
+	ArticleID   string    `json:"article_id"`
+	Summary     string    `json:"summary"`
+	KeyPoints   []string  `json:"key_points"`
+	Sentiment   string    `json:"sentiment"`
+	Relevance   float64   `json:"relevance"`
+	ProcessedAt time.Time `json:"processed_at"`
+}
@@ -0,0 +1,231 @@
+// Package prompts loads the LLM prompts from text/template files, so the
+// personas and instructions can be edited without a rebuild. Every template
+// carries a version ID that is recorded with what it produced, and edited
+// files are picked up on the next render.
+package prompts
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// templateExt is the extension of prompt files
+const templateExt = ".tmpl"
+
+// PersonaPrefix is the directory of the persona templates that write posts
+const PersonaPrefix = "personas/"
+
+// versionPattern matches the version comment a template starts with, e.g.
+// {{/* version: casual_youth-3 */}}
+var versionPattern = regexp.MustCompile(`^\s*{{-?\s*/\*\s*version:\s*(\S+?)\s*\*/\s*-?}}`)
+
+// Prompt is one parsed template
+type Prompt struct {
+	Name    string
+	Version string
+	tmpl    *template.Template
+}
+
+// Rendered is a filled-in prompt and the template it came from
+type Rendered struct {
+	Name    string
+	Version string
+	Text    string
+}
+
+// ID identifies the template version, e.g. "personas/casual_youth@casual_youth-3"
+func (r Rendered) ID() string {
+	return r.Name + "@" + r.Version
+}
+
+// Library holds the templates of a directory. Names are the file paths
+// relative to it without the extension, such as "analysis" or
+// "personas/casual_youth".
+type Library struct {
+	dir string
+
+	mu      sync.Mutex
+	prompts map[string]*Prompt
+	stamp   string // sizes and modification times of the loaded files
+}
+
+// Load parses every template in dir
+func Load(dir string) (*Library, error) {
+	library := &Library{dir: dir}
+
+	stamp, err := library.scan()
+	if err != nil {
+		return nil, err
+	}
+	prompts, err := library.parse()
+	if err != nil {
+		return nil, err
+	}
+
+	library.prompts = prompts
+	library.stamp = stamp
+	return library, nil
+}
+
+// Render fills in the template name with data. Templates edited since the
+// last render are reloaded first; if an edit does not parse, the previous
+// version stays in use and the error is logged.
+func (l *Library) Render(name string, data interface{}) (Rendered, error) {
+	prompt, err := l.get(name)
+	if err != nil {
+		return Rendered{}, err
+	}
+
+	var text bytes.Buffer
+	if err := prompt.tmpl.Execute(&text, data); err != nil {
+		return Rendered{}, fmt.Errorf("failed to render prompt %s: %w", name, err)
+	}
+
+	return Rendered{
+		Name:    prompt.Name,
+		Version: prompt.Version,
+		Text:    strings.TrimSpace(text.String()),
+	}, nil
+}
+
+// Has reports whether the library has a template called name
+func (l *Library) Has(name string) bool {
+	_, err := l.get(name)
+	return err == nil
+}
+
+// List returns the loaded templates sorted by name
+func (l *Library) List() []Prompt {
+	l.reload()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	list := make([]Prompt, 0, len(l.prompts))
+	for _, prompt := range l.prompts {
+		list = append(list, *prompt)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+func (l *Library) get(name string) (*Prompt, error) {
+	l.reload()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	prompt, ok := l.prompts[name]
+	if !ok {
+		return nil, fmt.Errorf("no prompt template %q in %s", name, l.dir)
+	}
+	return prompt, nil
+}
+
+// reload parses the directory again if any file was added, removed or changed
+func (l *Library) reload() {
+	stamp, err := l.scan()
+	if err != nil {
+		log.Printf("Failed to check prompt templates for changes: %v", err)
+		return
+	}
+
+	l.mu.Lock()
+	unchanged := stamp == l.stamp
+	l.mu.Unlock()
+	if unchanged {
+		return
+	}
+
+	prompts, err := l.parse()
+	if err != nil {
+		log.Printf("Keeping the previous prompt templates: %v", err)
+		return
+	}
+
+	l.mu.Lock()
+	l.prompts = prompts
+	l.stamp = stamp
+	l.mu.Unlock()
+	log.Printf("Reloaded prompt templates from %s", l.dir)
+}
+
+// scan summarises the template files, so changes show up as a different stamp
+func (l *Library) scan() (string, error) {
+	var stamp strings.Builder
+	err := l.walk(func(name, path string, info fs.FileInfo) error {
+		fmt.Fprintf(&stamp, "%s:%d:%d;", name, info.Size(), info.ModTime().UnixNano())
+		return nil
+	})
+	return stamp.String(), err
+}
+
+func (l *Library) parse() (map[string]*Prompt, error) {
+	prompts := make(map[string]*Prompt)
+	err := l.walk(func(name, path string, _ fs.FileInfo) error {
+		source, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read prompt template: %w", err)
+		}
+
+		tmpl, err := template.New(name).Option("missingkey=error").Parse(string(source))
+		if err != nil {
+			return fmt.Errorf("failed to parse prompt template: %w", err)
+		}
+
+		prompts[name] = &Prompt{Name: name, Version: version(source), tmpl: tmpl}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(prompts) == 0 {
+		return nil, fmt.Errorf("no prompt templates found in %s", l.dir)
+	}
+	return prompts, nil
+}
+
+// walk calls fn for every template file under the library directory
+func (l *Library) walk(fn func(name, path string, info fs.FileInfo) error) error {
+	return filepath.WalkDir(l.dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return fmt.Errorf("failed to read prompt directory: %w", err)
+		}
+		if entry.IsDir() || filepath.Ext(path) != templateExt {
+			return nil
+		}
+
+		rel, err := filepath.Rel(l.dir, path)
+		if err != nil {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return fmt.Errorf("failed to read prompt template: %w", err)
+		}
+		return fn(strings.TrimSuffix(filepath.ToSlash(rel), templateExt), path, info)
+	})
+}
+
+// version returns the version declared at the top of a template or, for
+// templates without one, a hash of its content
+func version(source []byte) string {
+	if match := versionPattern.FindSubmatch(source); match != nil {
+		return string(match[1])
+	}
+
+	sum := sha256.Sum256(source)
+	return "sha-" + hex.EncodeToString(sum[:4])
+}
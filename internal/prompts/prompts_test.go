@@ -0,0 +1,129 @@
+package prompts
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type article struct {
+	Title string
+	Link  string
+}
+
+func writeTemplate(t *testing.T, dir, name, source string) {
+	t.Helper()
+
+	path := filepath.Join(dir, filepath.FromSlash(name)+templateExt)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLibrary_Render(t *testing.T) {
+	dir := t.TempDir()
+	writeTemplate(t, dir, "personas/casual", "{{/* version: casual-2 */ -}}\nHey! {{.Title}} {{.Link}}\n")
+	writeTemplate(t, dir, "unversioned", "Title: {{.Title}}")
+
+	library, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rendered, err := library.Render(PersonaPrefix+"casual", article{Title: "Frieren", Link: "https://example.com/f"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rendered.Text != "Hey! Frieren https://example.com/f" {
+		t.Errorf("Text = %q", rendered.Text)
+	}
+	if rendered.ID() != "personas/casual@casual-2" {
+		t.Errorf("ID() = %q; want personas/casual@casual-2", rendered.ID())
+	}
+
+	unversioned, err := library.Render("unversioned", article{Title: "Frieren"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(unversioned.Version, "sha-") {
+		t.Errorf("unversioned template has version %q; want a content hash", unversioned.Version)
+	}
+
+	if _, err := library.Render("missing", article{}); err == nil {
+		t.Error("Render() of an unknown template returned no error")
+	}
+	if _, err := library.Render("unversioned", map[string]string{}); err == nil {
+		t.Error("Render() with a missing field returned no error")
+	}
+}
+
+func TestLibrary_HotReload(t *testing.T) {
+	dir := t.TempDir()
+	writeTemplate(t, dir, "post", "{{/* version: v1 */}}First {{.Title}}")
+
+	library, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeTemplate(t, dir, "post", "{{/* version: v2 */}}Second take on {{.Title}}")
+	rendered, err := library.Render("post", article{Title: "Frieren"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rendered.Version != "v2" || rendered.Text != "Second take on Frieren" {
+		t.Errorf("after edit = %+v; want the v2 template", rendered)
+	}
+
+	// A broken edit keeps the last good version in use
+	writeTemplate(t, dir, "post", "{{/* version: v3 */}}Broken {{.Title")
+	later := time.Now().Add(time.Second)
+	os.Chtimes(filepath.Join(dir, "post"+templateExt), later, later)
+
+	rendered, err = library.Render("post", article{Title: "Frieren"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rendered.Version != "v2" {
+		t.Errorf("after broken edit version = %q; want v2 kept", rendered.Version)
+	}
+}
+
+func TestLoad_ShippedPrompts(t *testing.T) {
+	library, err := Load(filepath.Join("..", "..", "configs", "prompts"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	news := struct{ Title, Summary, Link, Source string }{"Frieren season 2", "Coming in January.", "https://example.com/f", "Example"}
+	for _, persona := range []string{"casual_youth", "news_anchor", "meme_page"} {
+		rendered, err := library.Render(PersonaPrefix+persona, news)
+		if err != nil {
+			t.Errorf("persona %s: %v", persona, err)
+			continue
+		}
+		if !strings.HasPrefix(rendered.Version, persona+"-") || !strings.Contains(rendered.Text, news.Link) {
+			t.Errorf("persona %s rendered %+v", persona, rendered)
+		}
+	}
+
+	for _, structured := range []bool{true, false} {
+		analysis := struct {
+			Title, Description, Source string
+			Structured                 bool
+		}{"Frieren season 2", "Coming in January.", "Example", structured}
+		rendered, err := library.Render("analysis", analysis)
+		if err != nil {
+			t.Errorf("analysis (structured %v): %v", structured, err)
+			continue
+		}
+		if strings.Contains(rendered.Text, "SUMMARY:") == structured {
+			t.Errorf("analysis (structured %v) rendered the wrong format:\n%s", structured, rendered.Text)
+		}
+	}
+}
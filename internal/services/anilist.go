@@ -0,0 +1,320 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"go-test/internal/config"
+	"go-test/internal/models"
+	"go-test/pkg/errors"
+)
+
+const (
+	aniListSource   = "AniList"
+	aniListPerPage  = 50
+	aniListMaxPages = 5
+)
+
+// aniListAiringQuery asks for episodes airing between two Unix timestamps
+const aniListAiringQuery = `query ($start: Int, $end: Int, $page: Int, $perPage: Int) {
+  Page(page: $page, perPage: $perPage) {
+    pageInfo { hasNextPage }
+    airingSchedules(airingAt_greater: $start, airingAt_lesser: $end, sort: TIME) {
+      episode
+      airingAt
+      media {
+        id
+        siteUrl
+        episodes
+        popularity
+        isAdult
+        title { romaji english }
+        coverImage { extraLarge large }
+        bannerImage
+      }
+    }
+  }
+}`
+
+// AniListAiringSource turns the AniList airing schedule into "episode airs
+// today" news items for popular and followed titles
+type AniListAiringSource struct {
+	client        *http.Client
+	endpoint      string
+	window        time.Duration
+	minPopularity int
+	followed      map[int]bool
+	maxItems      int
+	location      *time.Location
+	now           func() time.Time
+}
+
+type aniListAiringSchedule struct {
+	Episode  int   `json:"episode"`
+	AiringAt int64 `json:"airingAt"`
+	Media    struct {
+		ID         int    `json:"id"`
+		SiteURL    string `json:"siteUrl"`
+		Episodes   int    `json:"episodes"`
+		Popularity int    `json:"popularity"`
+		IsAdult    bool   `json:"isAdult"`
+		Title      struct {
+			Romaji  string `json:"romaji"`
+			English string `json:"english"`
+		} `json:"title"`
+		CoverImage struct {
+			ExtraLarge string `json:"extraLarge"`
+			Large      string `json:"large"`
+		} `json:"coverImage"`
+		BannerImage string `json:"bannerImage"`
+	} `json:"media"`
+}
+
+// NewAniListAiringSource creates a new AniList airing schedule source
+func NewAniListAiringSource(cfg *config.Config) *AniListAiringSource {
+	followed := make(map[int]bool, len(cfg.AniListFollowedIDs))
+	for _, id := range cfg.AniListFollowedIDs {
+		followed[id] = true
+	}
+
+	// Release days and times are given in the audience's time zone
+	location, err := time.LoadLocation(cfg.AniListTimezone)
+	if err != nil {
+		location = time.Local
+	}
+
+	return &AniListAiringSource{
+		client: &http.Client{
+			Timeout: cfg.RequestTimeout,
+		},
+		endpoint:      cfg.AniListEndpoint,
+		window:        cfg.AniListWindow,
+		minPopularity: cfg.AniListMinPopularity,
+		followed:      followed,
+		maxItems:      cfg.AniListMaxItems,
+		location:      location,
+		now:           time.Now,
+	}
+}
+
+// SetTransport replaces the transport used for GraphQL queries
+func (s *AniListAiringSource) SetTransport(rt http.RoundTripper) {
+	s.client.Transport = rt
+}
+
+// Name identifies the source in logs
+func (s *AniListAiringSource) Name() string {
+	return aniListSource
+}
+
+// FetchAnimeNews returns the episodes of popular or followed titles that
+// air within the configured window, followed titles first and then by
+// popularity
+func (s *AniListAiringSource) FetchAnimeNews(ctx context.Context) ([]models.AnimeNews, error) {
+	now := s.now()
+
+	var schedules []aniListAiringSchedule
+	for page := 1; page <= aniListMaxPages; page++ {
+		batch, hasNext, err := s.fetchPage(ctx, now, page)
+		if err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, batch...)
+		if !hasNext {
+			break
+		}
+	}
+
+	var selected []aniListAiringSchedule
+	for _, schedule := range schedules {
+		if schedule.Media.IsAdult {
+			continue
+		}
+		if s.followed[schedule.Media.ID] || schedule.Media.Popularity >= s.minPopularity {
+			selected = append(selected, schedule)
+		}
+	}
+
+	sort.SliceStable(selected, func(i, j int) bool {
+		fi, fj := s.followed[selected[i].Media.ID], s.followed[selected[j].Media.ID]
+		if fi != fj {
+			return fi
+		}
+		return selected[i].Media.Popularity > selected[j].Media.Popularity
+	})
+
+	if s.maxItems > 0 && len(selected) > s.maxItems {
+		selected = selected[:s.maxItems]
+	}
+
+	news := make([]models.AnimeNews, 0, len(selected))
+	for _, schedule := range selected {
+		news = append(news, s.toAnimeNews(schedule, now))
+	}
+
+	return news, nil
+}
+
+// fetchPage queries one page of the airing schedule
+func (s *AniListAiringSource) fetchPage(ctx context.Context, now time.Time, page int) ([]aniListAiringSchedule, bool, error) {
+	payload, err := json.Marshal(map[string]interface{}{
+		"query": aniListAiringQuery,
+		"variables": map[string]interface{}{
+			"start":   now.Unix(),
+			"end":     now.Add(s.window).Unix(),
+			"page":    page,
+			"perPage": aniListPerPage,
+		},
+	})
+	if err != nil {
+		return nil, false, errors.Wrap(err, http.StatusInternalServerError, "Failed to encode AniList query")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", s.endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return nil, false, errors.Wrap(err, http.StatusInternalServerError, "Failed to create AniList request")
+	}
+
+	req.Header.Set("User-Agent", "AnimeNewsAI/1.0")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, false, errors.Wrap(err, http.StatusServiceUnavailable, "AniList request failed")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return nil, false, errors.ErrAPIQuotaExceeded
+	}
+
+	var result struct {
+		Data struct {
+			Page struct {
+				PageInfo struct {
+					HasNextPage bool `json:"hasNextPage"`
+				} `json:"pageInfo"`
+				AiringSchedules []aniListAiringSchedule `json:"airingSchedules"`
+			} `json:"Page"`
+		} `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return nil, false, errors.New(resp.StatusCode, fmt.Sprintf("AniList returned status: %d", resp.StatusCode))
+		}
+		return nil, false, errors.Wrap(err, http.StatusInternalServerError, "Failed to decode AniList response")
+	}
+
+	// GraphQL reports query problems in the body, often alongside a 200 status
+	if len(result.Errors) > 0 {
+		messages := make([]string, 0, len(result.Errors))
+		for _, e := range result.Errors {
+			messages = append(messages, e.Message)
+		}
+		code := resp.StatusCode
+		if code == http.StatusOK {
+			code = http.StatusBadGateway
+		}
+		return nil, false, errors.New(code, "AniList query failed: "+strings.Join(messages, "; "))
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, false, errors.New(resp.StatusCode, fmt.Sprintf("AniList returned status: %d", resp.StatusCode))
+	}
+
+	data := result.Data.Page
+	return data.AiringSchedules, data.PageInfo.HasNextPage, nil
+}
+
+// toAnimeNews turns a schedule entry into a news item. The link carries the
+// episode number so every episode is a separate item for duplicate checks.
+func (s *AniListAiringSource) toAnimeNews(schedule aniListAiringSchedule, now time.Time) models.AnimeNews {
+	media := schedule.Media
+
+	title := media.Title.English
+	if title == "" {
+		title = media.Title.Romaji
+	}
+
+	episode := fmt.Sprintf("Episode %d", schedule.Episode)
+	if media.Episodes > 0 {
+		episode = fmt.Sprintf("Episode %d of %d", schedule.Episode, media.Episodes)
+	}
+
+	releaseAt := time.Unix(schedule.AiringAt, 0).UTC()
+	localRelease := releaseAt.In(s.location)
+	day := airingDay(localRelease, now.In(s.location))
+	at := airingTime(localRelease)
+
+	summary := fmt.Sprintf("%s of %s airs %s at %s.", episode, title, day, at)
+	if media.Title.Romaji != "" && media.Title.Romaji != title {
+		summary = fmt.Sprintf("%s of %s (%s) airs %s at %s.", episode, title, media.Title.Romaji, day, at)
+	}
+	if media.Episodes > 0 && schedule.Episode == media.Episodes {
+		summary += " This is the final episode."
+	}
+
+	link := media.SiteURL
+	if link == "" {
+		link = fmt.Sprintf("https://anilist.co/anime/%d", media.ID)
+	}
+	link = fmt.Sprintf("%s?episode=%d", strings.TrimSuffix(link, "/"), schedule.Episode)
+
+	cover := media.CoverImage.ExtraLarge
+	if cover == "" {
+		cover = media.CoverImage.Large
+	}
+
+	var items []models.MediaItem
+	for _, image := range []string{cover, media.BannerImage} {
+		if imageURL := resolveURL(image, nil); imageURL != "" {
+			items = append(items, models.MediaItem{URL: imageURL, Type: models.MediaTypeImage})
+		}
+	}
+
+	return models.AnimeNews{
+		Title:       fmt.Sprintf("%s %s airs %s", title, episode, day),
+		Summary:     summary,
+		Link:        link,
+		Source:      aniListSource,
+		PublishedAt: now,
+		Media:       items,
+		ReleaseAt:   &releaseAt,
+	}
+}
+
+// airingDay describes the day of release relative to now: "today",
+// "tomorrow" or the weekday, e.g. "on Friday". Both times must be in the
+// same location, which decides where the day boundary falls.
+func airingDay(releaseAt, now time.Time) string {
+	const layout = "2006-01-02"
+	switch releaseAt.Format(layout) {
+	case now.Format(layout):
+		return "today"
+	case now.AddDate(0, 0, 1).Format(layout):
+		return "tomorrow"
+	default:
+		return "on " + releaseAt.Format("Monday")
+	}
+}
+
+// airingTime formats the release time with its zone, e.g. "15:00 UTC" or
+// "20:30 UTC+05:30" for zones without a letter abbreviation
+func airingTime(releaseAt time.Time) string {
+	zone, _ := releaseAt.Zone()
+	if strings.HasPrefix(zone, "+") || strings.HasPrefix(zone, "-") {
+		return releaseAt.Format("15:04 UTC-07:00")
+	}
+	return releaseAt.Format("15:04 MST")
+}
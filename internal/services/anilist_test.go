@@ -0,0 +1,179 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go-test/internal/config"
+)
+
+// aniListPages are the stub GraphQL responses, one per page
+var aniListPages = []string{
+	`{"data": {"Page": {"pageInfo": {"hasNextPage": true}, "airingSchedules": [
+		{"episode": 5, "airingAt": 1759330800, "media": {"id": 1, "siteUrl": "https://anilist.co/anime/1", "episodes": 12, "popularity": 250000,
+			"title": {"romaji": "Sousou no Frieren", "english": "Frieren: Beyond Journey's End"},
+			"coverImage": {"extraLarge": "https://img.anilist.co/1-xl.jpg", "large": "https://img.anilist.co/1-l.jpg"}, "bannerImage": "https://img.anilist.co/1-banner.jpg"}},
+		{"episode": 3, "airingAt": 1759334400, "media": {"id": 2, "siteUrl": "https://anilist.co/anime/2", "popularity": 900,
+			"title": {"romaji": "Niche Show"}}},
+		{"episode": 1, "airingAt": 1759338000, "media": {"id": 3, "siteUrl": "https://anilist.co/anime/3", "popularity": 999999, "isAdult": true,
+			"title": {"romaji": "Adult Show"}}}
+	]}}}`,
+	`{"data": {"Page": {"pageInfo": {"hasNextPage": false}, "airingSchedules": [
+		{"episode": 24, "airingAt": 1759420800, "media": {"id": 4, "siteUrl": "https://anilist.co/anime/4/", "episodes": 24, "popularity": 80000,
+			"title": {"romaji": "Kusuriya no Hitorigoto", "english": "The Apothecary Diaries"}}},
+		{"episode": 8, "airingAt": 1759345200, "media": {"id": 5, "siteUrl": "https://anilist.co/anime/5", "popularity": 10,
+			"title": {"romaji": "Followed Show"}}}
+	]}}}`,
+}
+
+func TestAniListAiringSource_FetchAnimeNews(t *testing.T) {
+	now := time.Date(2025, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Query     string         `json:"query"`
+			Variables map[string]int `json:"variables"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("request body is not a GraphQL query: %v", err)
+			return
+		}
+		if !strings.Contains(body.Query, "airingSchedules") {
+			t.Errorf("query = %q; want an airingSchedules query", body.Query)
+		}
+		if body.Variables["start"] != int(now.Unix()) || body.Variables["end"] != int(now.Add(36*time.Hour).Unix()) {
+			t.Errorf("variables = %v; want the configured window", body.Variables)
+		}
+
+		requests++
+		fmt.Fprint(w, aniListPages[body.Variables["page"]-1])
+	}))
+	defer server.Close()
+
+	source := NewAniListAiringSource(&config.Config{
+		RequestTimeout:       5 * time.Second,
+		AniListEndpoint:      server.URL,
+		AniListWindow:        36 * time.Hour,
+		AniListMinPopularity: 50000,
+		AniListFollowedIDs:   []int{5},
+		AniListMaxItems:      5,
+	})
+	source.now = func() time.Time { return now }
+
+	news, err := source.FetchAnimeNews(context.Background())
+	if err != nil {
+		t.Fatalf("FetchAnimeNews() error = %v", err)
+	}
+
+	if requests != 2 {
+		t.Errorf("server saw %d requests; want both pages", requests)
+	}
+
+	var titles []string
+	for _, item := range news {
+		titles = append(titles, item.Title)
+	}
+	want := []string{
+		"Followed Show Episode 8 airs today",
+		"Frieren: Beyond Journey's End Episode 5 of 12 airs today",
+		"The Apothecary Diaries Episode 24 of 24 airs tomorrow",
+	}
+	if strings.Join(titles, "|") != strings.Join(want, "|") {
+		t.Fatalf("titles = %q; want %q", titles, want)
+	}
+
+	frieren := news[1]
+	if frieren.Link != "https://anilist.co/anime/1?episode=5" {
+		t.Errorf("Link = %q; want the AniList page with the episode", frieren.Link)
+	}
+	if frieren.ReleaseAt == nil || !frieren.ReleaseAt.Equal(time.Unix(1759330800, 0)) {
+		t.Errorf("ReleaseAt = %v; want the airing time", frieren.ReleaseAt)
+	}
+	if frieren.Summary != "Episode 5 of 12 of Frieren: Beyond Journey's End (Sousou no Frieren) airs today at 15:00 UTC." {
+		t.Errorf("Summary = %q", frieren.Summary)
+	}
+	if len(frieren.Media) != 2 || frieren.Media[0].URL != "https://img.anilist.co/1-xl.jpg" {
+		t.Errorf("Media = %+v; want the large cover and the banner", frieren.Media)
+	}
+
+	if finale := news[2]; !strings.HasSuffix(finale.Summary, "This is the final episode.") || finale.Link != "https://anilist.co/anime/4?episode=24" {
+		t.Errorf("finale = %+v; want the final episode noted", finale)
+	}
+}
+
+func TestAniListAiringSource_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		wantErr string
+	}{
+		{"graphql error", http.StatusOK, `{"errors": [{"message": "Invalid field"}], "data": null}`, "AniList query failed: Invalid field"},
+		{"rate limited", http.StatusTooManyRequests, `{"errors": [{"message": "Too Many Requests."}]}`, "API quota exceeded"},
+		{"server error", http.StatusBadGateway, `<html>bad gateway</html>`, "AniList returned status: 502"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.body)
+			}))
+			defer server.Close()
+
+			source := NewAniListAiringSource(&config.Config{
+				RequestTimeout:  5 * time.Second,
+				AniListEndpoint: server.URL,
+				AniListWindow:   time.Hour,
+			})
+
+			_, err := source.FetchAnimeNews(context.Background())
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("FetchAnimeNews() error = %v; want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAniListAiringSource_TimeZone(t *testing.T) {
+	// 17:30 in Colombo; the episode airs at 01:00 the next day there
+	now := time.Date(2025, 10, 1, 12, 0, 0, 0, time.UTC)
+	airingAt := time.Date(2025, 10, 1, 19, 30, 0, 0, time.UTC).Unix()
+
+	tests := []struct {
+		timezone    string
+		wantTitle   string
+		wantSummary string
+	}{
+		{"Asia/Colombo", "Frieren Episode 5 airs tomorrow", "Episode 5 of Frieren airs tomorrow at 01:00 UTC+05:30."},
+		{"UTC", "Frieren Episode 5 airs today", "Episode 5 of Frieren airs today at 19:30 UTC."},
+		{"Asia/Tokyo", "Frieren Episode 5 airs tomorrow", "Episode 5 of Frieren airs tomorrow at 04:30 JST."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.timezone, func(t *testing.T) {
+			source := NewAniListAiringSource(&config.Config{AniListTimezone: tt.timezone})
+
+			var schedule aniListAiringSchedule
+			schedule.Episode = 5
+			schedule.AiringAt = airingAt
+			schedule.Media.ID = 1
+			schedule.Media.Title.Romaji = "Frieren"
+
+			item := source.toAnimeNews(schedule, now)
+			if item.Title != tt.wantTitle {
+				t.Errorf("Title = %q; want %q", item.Title, tt.wantTitle)
+			}
+			if item.Summary != tt.wantSummary {
+				t.Errorf("Summary = %q; want %q", item.Summary, tt.wantSummary)
+			}
+		})
+	}
+}
@@ -0,0 +1,422 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go-test/internal/config"
+	"go-test/internal/models"
+	"go-test/internal/prompts"
+	"go-test/pkg/errors"
+)
+
+// ArticleAnalyzer summarises and rates news articles with the configured LLM
+type ArticleAnalyzer struct {
+	llm     LLMProvider
+	prompts *prompts.Library
+	config  *config.Config
+
+	// textOnly is set once the provider turns out not to support
+	// structured output, so later analyses skip straight to the line format
+	textOnly atomic.Bool
+
+	// cache, when set, answers repeated prompts without calling the model
+	cache *LLMCache
+}
+
+// AnalysisPrompt is the article data the analysis template is rendered with.
+// Structured asks for JSON instead of the SUMMARY:/KEY_POINTS: line format.
+type AnalysisPrompt struct {
+	Title       string
+	Description string
+	Source      string
+	Structured  bool
+}
+
+// analysisPromptName is the template that asks for an analysis
+const analysisPromptName = "analysis"
+
+// analysisSentiments are the values the sentiment of an analysis may take
+var analysisSentiments = []string{"POSITIVE", "NEUTRAL", "NEGATIVE"}
+
+// analysisSchema is the structured output AnalyzeArticle asks for. Its
+// properties are the JSON fields of models.AIAnalysis the model fills in.
+var analysisSchema = JSONSchema{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"summary": map[string]interface{}{
+			"type":        "string",
+			"description": "Brief 2-3 sentence summary focusing on the key news",
+		},
+		"key_points": map[string]interface{}{
+			"type":        "array",
+			"items":       map[string]interface{}{"type": "string"},
+			"description": "3-5 key points",
+		},
+		"sentiment": map[string]interface{}{
+			"type": "string",
+			"enum": analysisSentiments,
+		},
+		"relevance": map[string]interface{}{
+			"type":        "number",
+			"description": "Anime/manga relevance from 0.0 to 1.0",
+		},
+	},
+	"required": []string{"summary", "key_points", "sentiment", "relevance"},
+}
+
+// SchemaViolation is one way a structured response breaks its schema
+type SchemaViolation struct {
+	Field   string // JSON property, empty when the whole document is wrong
+	Problem string
+}
+
+func (v SchemaViolation) String() string {
+	if v.Field == "" {
+		return v.Problem
+	}
+	return v.Field + " " + v.Problem
+}
+
+// SchemaError is returned when a model's structured response does not
+// match the schema it was asked for
+type SchemaError struct {
+	Provider   string
+	Violations []SchemaViolation
+	Response   string
+}
+
+func (e *SchemaError) Error() string {
+	problems := make([]string, 0, len(e.Violations))
+	for _, violation := range e.Violations {
+		problems = append(problems, violation.String())
+	}
+	return fmt.Sprintf("%s response does not match the schema: %s", e.Provider, strings.Join(problems, "; "))
+}
+
+// NewArticleAnalyzer creates a new analyzer that asks llm for its analyses
+// using the analysis template from library
+func NewArticleAnalyzer(cfg *config.Config, llm LLMProvider, library *prompts.Library) *ArticleAnalyzer {
+	return &ArticleAnalyzer{
+		llm:     llm,
+		prompts: library,
+		config:  cfg,
+	}
+}
+
+// GeminiService is the analyzer's name from before it worked with any LLM
+// provider.
+//
+// Deprecated: use ArticleAnalyzer.
+type GeminiService = ArticleAnalyzer
+
+// NewGeminiService creates an analyzer for LLM_PROVIDER and
+// LLM_ANALYZER_MODEL, which default to Gemini, with the analysis template
+// from PROMPTS_DIR.
+//
+// Deprecated: use NewArticleAnalyzer, which takes the provider and
+// templates and reports setup errors.
+func NewGeminiService(cfg *config.Config) *GeminiService {
+	llm, err := NewLLMProvider(cfg, cfg.LLMAnalyzerModel)
+	if err != nil {
+		// Only a config that skipped validation gets here; fall back to Gemini
+		log.Printf("⚠️  %v, analyzing with Gemini", err)
+		gemini := *cfg
+		gemini.LLMProvider = "gemini"
+		llm, _ = NewLLMProvider(&gemini, cfg.LLMAnalyzerModel)
+	}
+
+	library, err := prompts.Load(cfg.PromptsDir)
+	if err != nil {
+		log.Printf("⚠️  Article analysis will fail: %v", err)
+	}
+
+	return NewArticleAnalyzer(cfg, llm, library)
+}
+
+// SetCache makes the analyzer reuse the responses stored in cache. Only
+// responses that parse into an analysis are stored.
+func (s *ArticleAnalyzer) SetCache(cache *LLMCache) {
+	s.cache = cache
+}
+
+// SetTransport replaces the HTTP transport, e.g. to replay recorded model responses
+func (s *ArticleAnalyzer) SetTransport(rt http.RoundTripper) {
+	s.llm.SetTransport(rt)
+}
+
+// AnalyzeArticle analyzes a news article using the LLM. The analysis is
+// requested as JSON matching analysisSchema; a response that does not
+// match is reported as a *SchemaError. Providers without structured
+// output are asked for the line format instead.
+func (s *ArticleAnalyzer) AnalyzeArticle(ctx context.Context, article models.Article) (*models.AIAnalysis, error) {
+	if s.textOnly.Load() {
+		return s.analyzeText(ctx, article)
+	}
+
+	prompt, err := s.renderPrompt(article, true)
+	if err != nil {
+		return nil, err
+	}
+
+	response, cached, err := s.generateCached(ctx, prompt, analysisSchema)
+	if stderrors.Is(err, ErrSchemaUnsupported) {
+		log.Printf("⚠️  %s has no structured output, falling back to the line format: %v", s.llm.Name(), err)
+		s.textOnly.Store(true)
+		return s.analyzeText(ctx, article)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	analysis, violations := decodeAnalysis(response)
+	if len(violations) > 0 {
+		schemaErr := &SchemaError{Provider: s.llm.Name(), Violations: violations, Response: response}
+		return nil, errors.Wrap(schemaErr, http.StatusBadGateway, "Invalid analysis from "+s.llm.Name())
+	}
+	if !cached {
+		s.store(prompt, response)
+	}
+
+	analysis.ArticleID = article.ID
+	analysis.ProcessedAt = time.Now()
+	return analysis, nil
+}
+
+// analyzeText asks for the SUMMARY:/KEY_POINTS: line format, for providers
+// that cannot return structured output
+func (s *ArticleAnalyzer) analyzeText(ctx context.Context, article models.Article) (*models.AIAnalysis, error) {
+	prompt, err := s.renderPrompt(article, false)
+	if err != nil {
+		return nil, err
+	}
+
+	response, cached, err := s.generateCached(ctx, prompt, nil)
+	if err != nil {
+		return nil, err
+	}
+	if !cached {
+		s.store(prompt, response)
+	}
+
+	return s.parseAnalysisResponse(article.ID, response), nil
+}
+
+// generateCached answers prompt from the cache if it can and from the
+// model otherwise; cached reports which
+func (s *ArticleAnalyzer) generateCached(ctx context.Context, prompt prompts.Rendered, schema JSONSchema) (response string, cached bool, err error) {
+	if s.cache != nil {
+		if response, ok := s.cache.Get(s.llm, prompt.ID(), prompt.Text); ok {
+			return response, true, nil
+		}
+	}
+
+	response, err = s.generate(ctx, prompt.Text, schema)
+	return response, false, err
+}
+
+// store caches a usable response to prompt
+func (s *ArticleAnalyzer) store(prompt prompts.Rendered, response string) {
+	if s.cache == nil {
+		return
+	}
+	if err := s.cache.Put(s.llm, prompt.ID(), prompt.Text, response); err != nil {
+		log.Printf("⚠️  Failed to cache analysis: %v", err)
+	}
+}
+
+func (s *ArticleAnalyzer) renderPrompt(article models.Article, structured bool) (prompts.Rendered, error) {
+	if s.prompts == nil {
+		return prompts.Rendered{}, errors.New(http.StatusInternalServerError, "No prompt templates loaded for analysis")
+	}
+
+	prompt, err := s.prompts.Render(analysisPromptName, AnalysisPrompt{
+		Title:       article.Title,
+		Description: article.Description,
+		Source:      article.Source.Name,
+		Structured:  structured,
+	})
+	if err != nil {
+		return prompt, errors.Wrap(err, http.StatusInternalServerError, "Failed to build analysis prompt")
+	}
+	return prompt, nil
+}
+
+// BatchAnalyze analyzes multiple articles
+func (s *ArticleAnalyzer) BatchAnalyze(ctx context.Context, articles []models.Article) ([]models.AIAnalysis, error) {
+	analyses := make([]models.AIAnalysis, 0, len(articles))
+
+	for _, article := range articles {
+		// Rate limiting
+		time.Sleep(s.config.RateLimitDelay)
+
+		analysis, err := s.AnalyzeArticle(ctx, article)
+		if err != nil {
+			// Log error but continue with other articles
+			continue
+		}
+
+		analyses = append(analyses, *analysis)
+	}
+
+	return analyses, nil
+}
+
+// decodeAnalysis decodes a structured analysis, returning every way it
+// breaks analysisSchema
+func decodeAnalysis(response string) (*models.AIAnalysis, []SchemaViolation) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(response), &fields); err != nil {
+		return nil, []SchemaViolation{{Problem: "is not a JSON object: " + err.Error()}}
+	}
+
+	analysis := &models.AIAnalysis{}
+	var violations []SchemaViolation
+	decode := func(name string, target interface{}, want string) bool {
+		raw, ok := fields[name]
+		if !ok {
+			violations = append(violations, SchemaViolation{Field: name, Problem: "is missing"})
+			return false
+		}
+		if err := json.Unmarshal(raw, target); err != nil || string(raw) == "null" {
+			violations = append(violations, SchemaViolation{Field: name, Problem: "must be " + want})
+			return false
+		}
+		return true
+	}
+
+	if decode("summary", &analysis.Summary, "a string") && strings.TrimSpace(analysis.Summary) == "" {
+		violations = append(violations, SchemaViolation{Field: "summary", Problem: "must not be empty"})
+	}
+
+	if decode("key_points", &analysis.KeyPoints, "an array of strings") && len(analysis.KeyPoints) == 0 {
+		violations = append(violations, SchemaViolation{Field: "key_points", Problem: "must not be empty"})
+	}
+
+	if decode("sentiment", &analysis.Sentiment, "a string") && !isAnalysisSentiment(analysis.Sentiment) {
+		violations = append(violations, SchemaViolation{
+			Field:   "sentiment",
+			Problem: fmt.Sprintf("must be one of %s, got %q", strings.Join(analysisSentiments, ", "), analysis.Sentiment),
+		})
+	}
+
+	if decode("relevance", &analysis.Relevance, "a number") && (analysis.Relevance < 0 || analysis.Relevance > 1) {
+		violations = append(violations, SchemaViolation{
+			Field:   "relevance",
+			Problem: fmt.Sprintf("must be between 0.0 and 1.0, got %g", analysis.Relevance),
+		})
+	}
+
+	return analysis, violations
+}
+
+func isAnalysisSentiment(sentiment string) bool {
+	for _, allowed := range analysisSentiments {
+		if sentiment == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// parseAnalysisResponse parses the model's line-format response into
+// structured data, falling back to defaults for lines it cannot read
+func (s *ArticleAnalyzer) parseAnalysisResponse(articleID, response string) *models.AIAnalysis {
+	analysis := &models.AIAnalysis{
+		ArticleID:   articleID,
+		ProcessedAt: time.Now(),
+		Sentiment:   "NEUTRAL",
+		Relevance:   0.5,
+	}
+
+	// Parse structured response
+	lines := strings.Split(response, "\n")
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+
+		if strings.HasPrefix(line, "SUMMARY:") {
+			analysis.Summary = strings.TrimSpace(strings.TrimPrefix(line, "SUMMARY:"))
+		} else if strings.HasPrefix(line, "KEY_POINTS:") {
+			keyPointsStr := strings.TrimSpace(strings.TrimPrefix(line, "KEY_POINTS:"))
+			analysis.KeyPoints = strings.Split(keyPointsStr, ";")
+			// Trim whitespace from each key point
+			for i, point := range analysis.KeyPoints {
+				analysis.KeyPoints[i] = strings.TrimSpace(point)
+			}
+		} else if strings.HasPrefix(line, "SENTIMENT:") {
+			sentiment := strings.TrimSpace(strings.TrimPrefix(line, "SENTIMENT:"))
+			if sentiment == "POSITIVE" || sentiment == "NEGATIVE" || sentiment == "NEUTRAL" {
+				analysis.Sentiment = sentiment
+			}
+		} else if strings.HasPrefix(line, "RELEVANCE:") {
+			relevanceStr := strings.TrimSpace(strings.TrimPrefix(line, "RELEVANCE:"))
+			if relevance, err := strconv.ParseFloat(relevanceStr, 64); err == nil && relevance >= 0.0 && relevance <= 1.0 {
+				analysis.Relevance = relevance
+			}
+		}
+	}
+
+	// Fallback if parsing fails
+	if analysis.Summary == "" {
+		analysis.Summary = response
+	}
+
+	return analysis
+}
+
+// generate asks the LLM for an answer, as JSON matching schema unless it
+// is nil, retrying server errors and network failures. It always makes at
+// least one attempt.
+func (s *ArticleAnalyzer) generate(ctx context.Context, prompt string, schema JSONSchema) (string, error) {
+	attempts := max(s.config.RetryAttempts, 1)
+
+	var response string
+	var err error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if schema != nil {
+			response, err = s.llm.GenerateWithSchema(ctx, prompt, schema)
+		} else {
+			response, err = s.llm.Generate(ctx, prompt)
+		}
+
+		var statusErr *LLMStatusError
+		if err == nil || stderrors.Is(err, ErrSchemaUnsupported) || (stderrors.As(err, &statusErr) && statusErr.StatusCode < 500) {
+			break
+		}
+
+		if attempt < attempts-1 {
+			time.Sleep(s.config.RateLimitDelay * time.Duration(attempt+1))
+		}
+	}
+
+	var statusErr *LLMStatusError
+	if stderrors.As(err, &statusErr) {
+		switch statusErr.StatusCode {
+		case http.StatusUnauthorized:
+			return "", errors.ErrInvalidAPIKey
+		case http.StatusTooManyRequests:
+			return "", errors.ErrAPIQuotaExceeded
+		default:
+			return "", errors.New(statusErr.StatusCode, fmt.Sprintf("%s API returned status: %d", s.llm.Name(), statusErr.StatusCode))
+		}
+	}
+	if err != nil {
+		return "", errors.Wrap(err, http.StatusServiceUnavailable, s.llm.Name()+" API request failed")
+	}
+
+	return response, nil
+}
+
+// ValidateAPIKey checks that the LLM accepts requests
+func (s *ArticleAnalyzer) ValidateAPIKey(ctx context.Context) error {
+	_, err := s.generate(ctx, "Hello, this is a test.", nil)
+	return err
+}
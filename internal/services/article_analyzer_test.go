@@ -0,0 +1,145 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"go-test/internal/config"
+	"go-test/internal/models"
+	"go-test/internal/prompts"
+)
+
+func TestArticleAnalyzer_AnalyzeArticle(t *testing.T) {
+	const lineResponse = "SUMMARY: Frieren returns in January.\nKEY_POINTS: Season 2; January\nSENTIMENT: POSITIVE\nRELEVANCE: 0.9"
+
+	tests := []struct {
+		name string
+		// response answers schema requests; an empty one rejects response_format
+		response string
+		want     *models.AIAnalysis
+		// wantViolations are the fields a *SchemaError should name
+		wantViolations []string
+	}{
+		{
+			name:     "structured",
+			response: `{"summary":"Frieren returns in January.","key_points":["Season 2","January"],"sentiment":"POSITIVE","relevance":0.9}`,
+			want:     &models.AIAnalysis{ArticleID: "a1", Summary: "Frieren returns in January.", KeyPoints: []string{"Season 2", "January"}, Sentiment: "POSITIVE", Relevance: 0.9},
+		},
+		{
+			name:           "schema violations",
+			response:       `{"summary":"Frieren returns.","sentiment":"EXCITED","relevance":2}`,
+			wantViolations: []string{"key_points", "sentiment", "relevance"},
+		},
+		{
+			name:           "not JSON",
+			response:       "SUMMARY: Frieren returns.",
+			wantViolations: []string{""},
+		},
+		{
+			name: "falls back without schema support",
+			want: &models.AIAnalysis{ArticleID: "a1", Summary: "Frieren returns in January.", KeyPoints: []string{"Season 2", "January"}, Sentiment: "POSITIVE", Relevance: 0.9},
+		},
+	}
+
+	library, err := prompts.Load(filepath.Join("..", "..", "configs", "prompts"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var request openAIChatRequest
+				json.NewDecoder(r.Body).Decode(&request)
+
+				content := lineResponse
+				if request.ResponseFormat != nil {
+					if tt.response == "" {
+						http.Error(w, `{"error":"'response_format' is not supported"}`, http.StatusBadRequest)
+						return
+					}
+					content = tt.response
+				}
+				json.NewEncoder(w).Encode(openAIChatResponse{Choices: []struct {
+					Message openAIMessage `json:"message"`
+				}{{Message: openAIMessage{Role: "assistant", Content: content}}}})
+			}))
+			defer server.Close()
+
+			cfg := &config.Config{
+				LLMProvider:   "llamacpp",
+				LLMBaseURL:    server.URL,
+				LLMTimeout:    5 * time.Second,
+				RetryAttempts: 1,
+			}
+			llm, err := NewLLMProvider(cfg, "test-model")
+			if err != nil {
+				t.Fatal(err)
+			}
+			analyzer := NewArticleAnalyzer(cfg, llm, library)
+
+			analysis, err := analyzer.AnalyzeArticle(context.Background(), models.Article{ID: "a1", Title: "Frieren season 2"})
+			if tt.wantViolations != nil {
+				var schemaErr *SchemaError
+				if !errors.As(err, &schemaErr) {
+					t.Fatalf("AnalyzeArticle() error = %v; want a SchemaError", err)
+				}
+				var fields []string
+				for _, violation := range schemaErr.Violations {
+					fields = append(fields, violation.Field)
+				}
+				if !reflect.DeepEqual(fields, tt.wantViolations) {
+					t.Errorf("violations = %v; want fields %v", schemaErr.Violations, tt.wantViolations)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("AnalyzeArticle() error = %v", err)
+			}
+
+			if analysis.ProcessedAt.IsZero() {
+				t.Error("analysis has no processing time")
+			}
+			analysis.ProcessedAt = time.Time{}
+			if !reflect.DeepEqual(analysis, tt.want) {
+				t.Errorf("AnalyzeArticle() = %+v; want %+v", analysis, tt.want)
+			}
+		})
+	}
+}
+
+func TestArticleAnalyzer_AttemptsOnceWithoutRetries(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		json.NewEncoder(w).Encode(openAIChatResponse{Choices: []struct {
+			Message openAIMessage `json:"message"`
+		}{{Message: openAIMessage{Role: "assistant", Content: `{"summary":"Frieren returns.","key_points":["Season 2"],"sentiment":"POSITIVE","relevance":0.9}`}}}})
+	}))
+	defer server.Close()
+
+	library, err := prompts.Load(filepath.Join("..", "..", "configs", "prompts"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{LLMProvider: "llamacpp", LLMBaseURL: server.URL, LLMTimeout: 5 * time.Second, RetryAttempts: 0}
+	llm, err := NewLLMProvider(cfg, "test-model")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	analysis, err := NewArticleAnalyzer(cfg, llm, library).AnalyzeArticle(context.Background(), models.Article{ID: "a1", Title: "Frieren season 2"})
+	if err != nil {
+		t.Fatalf("AnalyzeArticle() error = %v", err)
+	}
+	if requests != 1 || analysis.Summary != "Frieren returns." {
+		t.Errorf("AnalyzeArticle() made %d requests and summarised %q; want one request answered", requests, analysis.Summary)
+	}
+}
@@ -0,0 +1,470 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"go-test/internal/config"
+	"go-test/internal/models"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+// ErrDisallowedByRobots is returned when robots.txt forbids fetching an article
+var ErrDisallowedByRobots = errors.New("article fetch disallowed by robots.txt")
+
+// ErrCrawlDelayTooLong is returned for hosts whose robots.txt Crawl-delay is
+// longer than the extractor is willing to wait
+var ErrCrawlDelayTooLong = errors.New("robots.txt crawl-delay exceeds the configured maximum")
+
+const (
+	extractorUserAgent  = "AnimeNewsAI/1.0"
+	extractorRobotsName = "animenewsai"
+	maxArticleSize      = 5 << 20 // 5MB
+	minParagraphLength  = 25
+)
+
+var (
+	positiveCandidatePattern = regexp.MustCompile(`(?i)article|body|content|entry|main|page|post|text|blog|story`)
+	negativeCandidatePattern = regexp.MustCompile(`(?i)comment|meta|footer|footnote|sidebar|widget|share|social|related|nav|promo|sponsor|advert|breadcrumb|menu`)
+)
+
+// ArticleExtractor fetches article pages and keeps only their main body
+// text using readability-style scoring. It honours robots.txt and waits
+// at least hostDelay between requests to the same host. Hosts asking for a
+// Crawl-delay above maxCrawlDelay are skipped rather than waited for.
+type ArticleExtractor struct {
+	httpClient    *http.Client
+	maxChars      int
+	hostDelay     time.Duration
+	maxCrawlDelay time.Duration
+	robotsTTL     time.Duration
+	now           func() time.Time
+
+	mu        sync.Mutex
+	nextFetch map[string]time.Time
+	robots    map[string]*robotsRules
+}
+
+// ExtractedArticle is the main content of an article page
+type ExtractedArticle struct {
+	Text   string
+	Images []models.MediaItem
+}
+
+// NewArticleExtractor creates a new article extractor
+func NewArticleExtractor(cfg *config.Config) *ArticleExtractor {
+	return &ArticleExtractor{
+		httpClient: &http.Client{
+			Timeout: cfg.RequestTimeout,
+		},
+		maxChars:      cfg.ArticleMaxChars,
+		hostDelay:     cfg.ArticleHostDelay,
+		maxCrawlDelay: cfg.ArticleMaxCrawlDelay,
+		robotsTTL:     cfg.ArticleRobotsTTL,
+		now:           time.Now,
+		nextFetch:     make(map[string]time.Time),
+		robots:        make(map[string]*robotsRules),
+	}
+}
+
+// SetTransport replaces the transport used for article pages and robots.txt
+func (ae *ArticleExtractor) SetTransport(rt http.RoundTripper) {
+	ae.httpClient.Transport = rt
+}
+
+// Enrich fills news.Content with the extracted article body and adds any
+// og:image artwork from the page to news.Media
+func (ae *ArticleExtractor) Enrich(ctx context.Context, news *models.AnimeNews) error {
+	article, err := ae.Extract(ctx, news.Link)
+	if err != nil {
+		return err
+	}
+
+	news.Content = article.Text
+
+	c := newMediaCollector(news.Link)
+	for _, item := range news.Media {
+		c.add(item, mediaRankContent)
+	}
+	for _, image := range article.Images {
+		c.add(image, mediaRankOpenGraph)
+	}
+	news.Media = c.ranked()
+
+	return nil
+}
+
+// Extract fetches link and returns its main content
+func (ae *ArticleExtractor) Extract(ctx context.Context, link string) (*ExtractedArticle, error) {
+	pageURL, err := url.Parse(link)
+	if err != nil || (pageURL.Scheme != "http" && pageURL.Scheme != "https") {
+		return nil, fmt.Errorf("invalid article URL %q", link)
+	}
+
+	rules, err := ae.robotsFor(ctx, pageURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if !rules.allowed(pageURL.RequestURI()) {
+		return nil, fmt.Errorf("%w: %s", ErrDisallowedByRobots, link)
+	}
+
+	if ae.maxCrawlDelay > 0 && rules.crawlDelay > ae.maxCrawlDelay {
+		return nil, fmt.Errorf("%w: %s asks for %s", ErrCrawlDelayTooLong, pageURL.Host, rules.crawlDelay)
+	}
+
+	if err := ae.waitForHost(ctx, pageURL.Host, rules.crawlDelay); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", link, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create article request: %w", err)
+	}
+	req.Header.Set("User-Agent", extractorUserAgent)
+	req.Header.Set("Accept", "text/html")
+
+	resp, err := ae.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch article: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("article %s returned status: %d", link, resp.StatusCode)
+	}
+
+	doc, err := goquery.NewDocumentFromReader(io.LimitReader(resp.Body, maxArticleSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse article: %w", err)
+	}
+
+	// Read og:image before ExtractMainContent prunes the document
+	images := openGraphFromDocument(doc)
+
+	return &ExtractedArticle{
+		Text:   ExtractMainContent(doc, link, ae.maxChars),
+		Images: images,
+	}, nil
+}
+
+// waitForHost blocks until the politeness delay for host has passed
+func (ae *ArticleExtractor) waitForHost(ctx context.Context, host string, crawlDelay time.Duration) error {
+	delay := ae.hostDelay
+	if crawlDelay > delay {
+		delay = crawlDelay
+	}
+
+	ae.mu.Lock()
+	now := ae.now()
+	start := ae.nextFetch[host]
+	if start.Before(now) {
+		start = now
+	}
+	ae.nextFetch[host] = start.Add(delay)
+	ae.mu.Unlock()
+
+	wait := start.Sub(now)
+	if wait <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ExtractMainContent scores the block elements of a page and returns the
+// text of the best candidate, capped at maxChars runes (0 means no cap)
+func ExtractMainContent(doc *goquery.Document, baseURL string, maxChars int) string {
+	doc.Find(droppedElements).Remove()
+	doc.Find("[class], [id]").Each(func(_ int, s *goquery.Selection) {
+		class, _ := s.Attr("class")
+		id, _ := s.Attr("id")
+		if boilerplatePattern.MatchString(class) || boilerplatePattern.MatchString(id) {
+			s.Remove()
+		}
+	})
+
+	scores := make(map[*html.Node]float64)
+	var candidates []*html.Node
+
+	addScore := func(n *html.Node, score float64) {
+		if n == nil || n.Type != html.ElementNode || n.Data == "body" || n.Data == "html" {
+			return
+		}
+		if _, ok := scores[n]; !ok {
+			scores[n] = initialCandidateScore(n)
+			candidates = append(candidates, n)
+		}
+		scores[n] += score
+	}
+
+	doc.Find("p, pre, td, blockquote").Each(func(_ int, s *goquery.Selection) {
+		text := strings.TrimSpace(s.Text())
+		length := utf8.RuneCountInString(text)
+		if length < minParagraphLength {
+			return
+		}
+
+		score := 1 + float64(strings.Count(text, ",")) + math.Min(float64(length)/100, 3)
+		node := s.Get(0)
+		addScore(node.Parent, score)
+		if node.Parent != nil {
+			addScore(node.Parent.Parent, score/2)
+		}
+	})
+
+	var best *html.Node
+	bestScore := 0.0
+	for _, candidate := range candidates {
+		score := scores[candidate] * (1 - linkDensity(goquery.NewDocumentFromNode(candidate).Selection))
+		if best == nil || score > bestScore {
+			best, bestScore = candidate, score
+		}
+	}
+
+	if best == nil {
+		return ""
+	}
+
+	rendered, err := goquery.OuterHtml(goquery.NewDocumentFromNode(best).Selection)
+	if err != nil {
+		return ""
+	}
+
+	return truncateText(SanitizeHTML(rendered, baseURL).Text, maxChars)
+}
+
+// initialCandidateScore weighs a candidate by its tag and class/id names
+func initialCandidateScore(n *html.Node) float64 {
+	score := 0.0
+	switch n.Data {
+	case "article":
+		score += 10
+	case "div", "section", "main":
+		score += 5
+	case "pre", "td", "blockquote":
+		score += 3
+	case "form", "ul", "ol", "dl", "li":
+		score -= 3
+	case "h1", "h2", "h3", "h4", "h5", "h6", "th":
+		score -= 5
+	}
+
+	for _, name := range []string{attr(n, "class"), attr(n, "id")} {
+		if name == "" {
+			continue
+		}
+		if negativeCandidatePattern.MatchString(name) {
+			score -= 25
+		}
+		if positiveCandidatePattern.MatchString(name) {
+			score += 25
+		}
+	}
+
+	return score
+}
+
+// linkDensity is the share of a selection's text that sits inside links
+func linkDensity(s *goquery.Selection) float64 {
+	textLength := utf8.RuneCountInString(strings.TrimSpace(s.Text()))
+	if textLength == 0 {
+		return 0
+	}
+
+	linkLength := 0
+	s.Find("a").Each(func(_ int, a *goquery.Selection) {
+		linkLength += utf8.RuneCountInString(strings.TrimSpace(a.Text()))
+	})
+
+	return float64(linkLength) / float64(textLength)
+}
+
+// truncateText caps text at maxChars runes, cutting at a word boundary
+func truncateText(text string, maxChars int) string {
+	if maxChars <= 0 || utf8.RuneCountInString(text) <= maxChars {
+		return text
+	}
+
+	runes := []rune(text)
+	cut := string(runes[:maxChars])
+	if i := strings.LastIndexAny(cut, " \n"); i > len(cut)/2 {
+		cut = cut[:i]
+	}
+
+	return strings.TrimSpace(cut) + "…"
+}
+
+// robotsRules are the robots.txt rules that apply to this bot on one host
+type robotsRules struct {
+	allow      []string
+	disallow   []string
+	crawlDelay time.Duration
+	fetchedAt  time.Time
+}
+
+// allowed applies the longest matching rule; Allow wins ties
+func (r *robotsRules) allowed(path string) bool {
+	longestAllow, longestDisallow := -1, -1
+	for _, rule := range r.allow {
+		if robotsRuleMatches(rule, path) && len(rule) > longestAllow {
+			longestAllow = len(rule)
+		}
+	}
+	for _, rule := range r.disallow {
+		if robotsRuleMatches(rule, path) && len(rule) > longestDisallow {
+			longestDisallow = len(rule)
+		}
+	}
+	return longestDisallow < 0 || longestAllow >= longestDisallow
+}
+
+// robotsRuleMatches matches a path against a rule, supporting the "*"
+// wildcard and the "$" end anchor
+func robotsRuleMatches(rule, path string) bool {
+	if !strings.ContainsAny(rule, "*$") {
+		return strings.HasPrefix(path, rule)
+	}
+
+	anchored := strings.HasSuffix(rule, "$")
+	parts := strings.Split(strings.TrimSuffix(rule, "$"), "*")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+
+	pattern := "^" + strings.Join(parts, ".*")
+	if anchored {
+		pattern += "$"
+	}
+
+	matched, err := regexp.MatchString(pattern, path)
+	return err == nil && matched
+}
+
+// robotsFor returns the cached robots.txt rules for a host, fetching them
+// on first use and again once they are older than robotsTTL
+func (ae *ArticleExtractor) robotsFor(ctx context.Context, pageURL *url.URL) (*robotsRules, error) {
+	ae.mu.Lock()
+	rules, ok := ae.robots[pageURL.Host]
+	ae.mu.Unlock()
+	if ok && (ae.robotsTTL <= 0 || ae.now().Sub(rules.fetchedAt) < ae.robotsTTL) {
+		return rules, nil
+	}
+
+	robotsURL := pageURL.Scheme + "://" + pageURL.Host + "/robots.txt"
+	req, err := http.NewRequestWithContext(ctx, "GET", robotsURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create robots.txt request: %w", err)
+	}
+	req.Header.Set("User-Agent", extractorUserAgent)
+
+	resp, err := ae.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch robots.txt: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 400 && resp.StatusCode < 500:
+		// No robots.txt means everything is allowed
+		rules = &robotsRules{}
+	case resp.StatusCode != http.StatusOK:
+		// Server errors are treated as a temporary full disallow
+		return nil, fmt.Errorf("%w: robots.txt returned status %d", ErrDisallowedByRobots, resp.StatusCode)
+	default:
+		rules = parseRobots(io.LimitReader(resp.Body, 512<<10), extractorRobotsName)
+	}
+	rules.fetchedAt = ae.now()
+
+	ae.mu.Lock()
+	ae.robots[pageURL.Host] = rules
+	ae.mu.Unlock()
+
+	return rules, nil
+}
+
+// parseRobots reads the group for agent, falling back to the "*" group
+func parseRobots(r io.Reader, agent string) *robotsRules {
+	groups := make(map[string]*robotsRules)
+	var current []string
+	inRules := false
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			// Consecutive user-agent lines share one group
+			if inRules {
+				current = nil
+				inRules = false
+			}
+			name := strings.ToLower(value)
+			current = append(current, name)
+			if _, ok := groups[name]; !ok {
+				groups[name] = &robotsRules{}
+			}
+		case "allow", "disallow", "crawl-delay":
+			inRules = true
+			for _, name := range current {
+				group := groups[name]
+				switch key {
+				case "allow":
+					if value != "" {
+						group.allow = append(group.allow, value)
+					}
+				case "disallow":
+					if value != "" {
+						group.disallow = append(group.disallow, value)
+					}
+				case "crawl-delay":
+					if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+						group.crawlDelay = time.Duration(seconds * float64(time.Second))
+					}
+				}
+			}
+		}
+	}
+
+	if group, ok := groups[agent]; ok {
+		return group
+	}
+	if group, ok := groups["*"]; ok {
+		return group
+	}
+	return &robotsRules{}
+}
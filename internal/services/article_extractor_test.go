@@ -0,0 +1,217 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go-test/internal/config"
+	"go-test/internal/models"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func loadFixture(t *testing.T, name string) string {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("failed to read fixture %s: %v", name, err)
+	}
+	return string(data)
+}
+
+func TestExtractMainContent(t *testing.T) {
+	tests := []struct {
+		name        string
+		fixture     string
+		maxChars    int
+		mustContain []string
+		mustExclude []string
+	}{
+		{
+			name:        "news article",
+			fixture:     "article_news.html",
+			mustContain: []string{"second season is in production", "Journey’s End", "next leg of their journey"},
+			mustExclude: []string{"One Piece chapter", "waiting for this forever", "Copyright", "analytics", "Trending"},
+		},
+		{
+			name:        "blog post",
+			fixture:     "article_blog.html",
+			mustContain: []string{"Science SARU", "strongest shows of the year"},
+			mustExclude: []string{"Share on", "volume 18"},
+		},
+		{
+			name:        "capped length",
+			fixture:     "article_news.html",
+			maxChars:    80,
+			mustContain: []string{"…"},
+			mustExclude: []string{"Keiichirō Saitō"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := goquery.NewDocumentFromReader(strings.NewReader(loadFixture(t, tt.fixture)))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			text := ExtractMainContent(doc, "https://example.com/news/1", tt.maxChars)
+
+			for _, want := range tt.mustContain {
+				if !strings.Contains(text, want) {
+					t.Errorf("ExtractMainContent() missing %q in:\n%s", want, text)
+				}
+			}
+			for _, unwanted := range tt.mustExclude {
+				if strings.Contains(text, unwanted) {
+					t.Errorf("ExtractMainContent() contains %q in:\n%s", unwanted, text)
+				}
+			}
+			if tt.maxChars > 0 && len([]rune(text)) > tt.maxChars+1 {
+				t.Errorf("ExtractMainContent() length = %d; want at most %d", len([]rune(text)), tt.maxChars+1)
+			}
+		})
+	}
+}
+
+func TestArticleExtractor_Enrich(t *testing.T) {
+	page := loadFixture(t, "article_news.html")
+
+	var pageRequests []time.Time
+	mux := http.NewServeMux()
+	mux.HandleFunc("/robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "User-agent: *\nDisallow: /private/\n\nUser-agent: AnimeNewsAI\nDisallow: /members/\nAllow: /members/free\n")
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, page)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	extractor := NewArticleExtractor(&config.Config{
+		RequestTimeout:   5 * time.Second,
+		ArticleMaxChars:  4000,
+		ArticleHostDelay: 100 * time.Millisecond,
+	})
+
+	// Page requests are timed as they leave the client, since the server
+	// sees a new connection's first request later than one on a kept-alive
+	// connection
+	extractor.SetTransport(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if req.URL.Path != "/robots.txt" {
+			pageRequests = append(pageRequests, time.Now())
+		}
+		return http.DefaultTransport.RoundTrip(req)
+	}))
+
+	news := &models.AnimeNews{Link: server.URL + "/news/frieren"}
+	if err := extractor.Enrich(context.Background(), news); err != nil {
+		t.Fatalf("Enrich() error = %v", err)
+	}
+
+	if !strings.Contains(news.Content, "second season is in production") {
+		t.Errorf("Content = %q; want the article body", news.Content)
+	}
+
+	if len(news.Media) != 1 || news.Media[0].URL != "https://example.com/images/frieren-s2.jpg" || news.Media[0].Width != 1280 {
+		t.Errorf("Media = %+v; want the og:image", news.Media)
+	}
+
+	// The bot-specific group replaces the "*" group
+	if _, err := extractor.Extract(context.Background(), server.URL+"/members/story"); !errors.Is(err, ErrDisallowedByRobots) {
+		t.Errorf("Extract(/members/story) error = %v; want ErrDisallowedByRobots", err)
+	}
+	if _, err := extractor.Extract(context.Background(), server.URL+"/members/free/story"); err != nil {
+		t.Errorf("Extract(/members/free/story) error = %v; want allowed", err)
+	}
+	if _, err := extractor.Extract(context.Background(), server.URL+"/private/story"); err != nil {
+		t.Errorf("Extract(/private/story) error = %v; want allowed for this bot", err)
+	}
+
+	if len(pageRequests) != 3 {
+		t.Fatalf("server saw %d page requests; want 3", len(pageRequests))
+	}
+	for i := 1; i < len(pageRequests); i++ {
+		if gap := pageRequests[i].Sub(pageRequests[i-1]); gap < 90*time.Millisecond {
+			t.Errorf("requests %d and %d were %s apart; want the politeness delay", i-1, i, gap)
+		}
+	}
+}
+
+func TestArticleExtractor_CrawlDelayAndRobotsExpiry(t *testing.T) {
+	robots := "User-agent: *\nCrawl-delay: 3600\n"
+	var robotsRequests, pageRequests int
+	mux := http.NewServeMux()
+	mux.HandleFunc("/robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		robotsRequests++
+		fmt.Fprint(w, robots)
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		pageRequests++
+		fmt.Fprint(w, "<html><body><article><p>The second season of the show is now in production.</p></article></body></html>")
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	now := time.Date(2025, 10, 1, 12, 0, 0, 0, time.UTC)
+	extractor := NewArticleExtractor(&config.Config{
+		RequestTimeout:       5 * time.Second,
+		ArticleMaxCrawlDelay: 30 * time.Second,
+		ArticleRobotsTTL:     time.Hour,
+	})
+	extractor.now = func() time.Time { return now }
+
+	// An hour-long Crawl-delay is over the cap, so the page is skipped at once
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := extractor.Extract(ctx, server.URL+"/news/1"); !errors.Is(err, ErrCrawlDelayTooLong) {
+		t.Fatalf("Extract() error = %v; want ErrCrawlDelayTooLong", err)
+	}
+	if pageRequests != 0 {
+		t.Errorf("server saw %d page requests; want none", pageRequests)
+	}
+
+	// Cached rules are reused until they expire, then fetched again
+	robots = "User-agent: *\nDisallow:\n"
+	if _, err := extractor.Extract(ctx, server.URL+"/news/2"); !errors.Is(err, ErrCrawlDelayTooLong) {
+		t.Errorf("Extract() within the TTL error = %v; want the cached rules", err)
+	}
+	now = now.Add(2 * time.Hour)
+	if _, err := extractor.Extract(ctx, server.URL+"/news/3"); err != nil {
+		t.Errorf("Extract() after the TTL error = %v; want the refreshed rules", err)
+	}
+	if robotsRequests != 2 || pageRequests != 1 {
+		t.Errorf("server saw %d robots.txt and %d page requests; want 2 and 1", robotsRequests, pageRequests)
+	}
+}
+
+func TestRobotsRuleMatches(t *testing.T) {
+	tests := []struct {
+		rule     string
+		path     string
+		expected bool
+	}{
+		{"/news/", "/news/1", true},
+		{"/news/", "/reviews/1", false},
+		{"/*?", "/news/1?utm=x", true},
+		{"/*.pdf$", "/files/a.pdf", true},
+		{"/*.pdf$", "/files/a.pdf?x", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rule+" "+tt.path, func(t *testing.T) {
+			if result := robotsRuleMatches(tt.rule, tt.path); result != tt.expected {
+				t.Errorf("robotsRuleMatches(%q, %q) = %t; want %t", tt.rule, tt.path, result, tt.expected)
+			}
+		})
+	}
+}
@@ -1,170 +1,241 @@
 package services
 
 import (
-	"bufio"
+	"errors"
 	"fmt"
-	"os"
-	"path/filepath"
-	"strings"
 	"time"
 
 	"go-test/internal/models"
 )
 
-// DuplicateChecker manages tracking of published articles
+// ErrNoReservation is returned by ResolveReservation for a link that has no
+// pending reservation
+var ErrNoReservation = errors.New("no pending reservation")
+
+// DuplicateChecker manages tracking of published articles. Links are
+// canonicalized before they are looked up or recorded, so tracking
+// parameters, AMP pages and similar variants count as the same article.
 type DuplicateChecker struct {
-	logFilePath string
+	store PublishedStore
+	now   func() time.Time
 }
 
-// NewDuplicateChecker creates a new duplicate checker instance
-func NewDuplicateChecker() *DuplicateChecker {
-	// Create data directory if it doesn't exist
-	dataDir := "data"
-	if err := os.MkdirAll(dataDir, 0755); err != nil {
-		fmt.Printf("Warning: Could not create data directory: %v\n", err)
-	}
-
+// NewDuplicateChecker creates a new duplicate checker on top of store
+func NewDuplicateChecker(store PublishedStore) *DuplicateChecker {
 	return &DuplicateChecker{
-		logFilePath: filepath.Join(dataDir, "published_articles.txt"),
+		store: store,
+		now:   time.Now,
 	}
 }
 
 // CheckIfPostedBefore checks if an article link has been posted before
 func (dc *DuplicateChecker) CheckIfPostedBefore(articleLink string) (bool, error) {
-	// If log file doesn't exist, article is definitely new
-	if _, err := os.Stat(dc.logFilePath); os.IsNotExist(err) {
-		return true, nil // true means it's NEW (not posted before)
-	}
-
-	file, err := os.Open(dc.logFilePath)
+	found, err := dc.seen(articleLink, models.PublishedArticle{})
 	if err != nil {
-		return false, fmt.Errorf("failed to open log file: %w", err)
+		return false, err
 	}
-	defer file.Close()
+	return !found, nil // true means it's NEW (not posted before)
+}
 
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line == "" {
-			continue
-		}
+// IsNewArticle reports whether neither the article's links nor its GUID or
+// content hash have been published before
+func (dc *DuplicateChecker) IsNewArticle(article models.AnimeNews) (bool, error) {
+	found, err := dc.seen(articleKey(article.Link, article.GUID), models.PublishedArticle{
+		GUID:        canonicalizeURL(article.GUID),
+		ContentHash: contentHash(article),
+	})
+	if err != nil || found {
+		return false, err
+	}
 
-		// Each line format: "YYYY-MM-DD|LINK|TITLE"
-		parts := strings.Split(line, "|")
-		if len(parts) >= 2 && parts[1] == articleLink {
-			return false, nil // false means it's OLD (already posted)
+	for _, link := range article.AlternateLinks {
+		if found, err := dc.seen(link, models.PublishedArticle{}); err != nil || found {
+			return false, err
 		}
 	}
+	return true, nil
+}
 
-	if err := scanner.Err(); err != nil {
-		return false, fmt.Errorf("error reading log file: %w", err)
+// seen looks up link in its canonical form and, for history recorded
+// before links were canonicalized, as given
+func (dc *DuplicateChecker) seen(link string, key models.PublishedArticle) (bool, error) {
+	key.Link = canonicalizeURL(link)
+	if found, err := dc.store.Contains(key); err != nil || found {
+		return found, err
 	}
 
-	return true, nil // true means it's NEW (not found in log)
+	if link == key.Link {
+		return false, nil
+	}
+	return dc.store.Contains(models.PublishedArticle{Link: link})
 }
 
 // LogAsPublished logs an article as published
 func (dc *DuplicateChecker) LogAsPublished(articleLink, title string) error {
-	file, err := os.OpenFile(dc.logFilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
-	if err != nil {
-		return fmt.Errorf("failed to open log file for writing: %w", err)
-	}
-	defer file.Close()
+	return dc.store.Add(models.PublishedArticle{
+		Link:        canonicalizeURL(articleLink),
+		Title:       title,
+		PublishedAt: dc.now(),
+	})
+}
 
-	// Format: "YYYY-MM-DD|LINK|TITLE"
-	timestamp := time.Now().Format("2006-01-02 15:04:05")
-	logEntry := fmt.Sprintf("%s|%s|%s\n", timestamp, articleLink, title)
+// PublishDetails describes where and how an article was posted
+type PublishDetails struct {
+	Channel   string
+	MessageID string
+	Model     string
+	Prompt    string
+	PostText  string
 
-	if _, err := file.WriteString(logEntry); err != nil {
-		return fmt.Errorf("failed to write to log file: %w", err)
-	}
+	// Rejections are the validation failures of drafts written before PostText
+	Rejections []string
+}
 
-	return nil
+// LogArticle logs the publication of article as one history event with its
+// GUID, content hash and alternate links, so another feed's copy of the
+// story is never posted later. An item without a link is logged under its
+// GUID.
+func (dc *DuplicateChecker) LogArticle(article models.AnimeNews, details PublishDetails) error {
+	return dc.store.Add(dc.newRecord(article, details))
 }
 
-// GetPublishedCount returns the number of published articles
-func (dc *DuplicateChecker) GetPublishedCount() (int, error) {
-	if _, err := os.Stat(dc.logFilePath); os.IsNotExist(err) {
-		return 0, nil
-	}
+// Reserve claims article before its post is sent, so neither a crash nor
+// another run can post it twice. It returns the history record to pass to
+// MarkSent, Commit or Release, or ErrAlreadyPublished.
+func (dc *DuplicateChecker) Reserve(article models.AnimeNews, details PublishDetails) (models.PublishedArticle, error) {
+	record := dc.newRecord(article, details)
+	return record, dc.store.Reserve(record)
+}
 
-	file, err := os.Open(dc.logFilePath)
+// MarkSent stores the receipt of a sent post on its reservation and record
+func (dc *DuplicateChecker) MarkSent(record *models.PublishedArticle, receipt *PublishReceipt) error {
+	record.Channel = receipt.Channel
+	record.MessageID = receipt.MessageID
+	return dc.store.MarkSent(record.Link, receipt.Channel, receipt.MessageID)
+}
+
+// Commit moves a reserved record into the history
+func (dc *DuplicateChecker) Commit(record models.PublishedArticle) error {
+	return dc.store.Commit(record)
+}
+
+// Release gives up a reservation whose post was not sent
+func (dc *DuplicateChecker) Release(record models.PublishedArticle) error {
+	return dc.store.Release(record.Link)
+}
+
+// RecoverReservations settles reservations older than staleAfter, which a
+// crashed or failed run left behind. Those marked sent were posted and
+// are committed. The rest cannot be verified: the run may have died after
+// the platform took the post but before MarkSent, or while waiting for an
+// answer. They are held, so the article stays blocked, and returned for an
+// operator to check the channel and settle with ResolveReservation.
+// Younger reservations may belong to a run that is still publishing and are
+// left alone; a caller that holds the cycle lock knows none is and passes 0.
+func (dc *DuplicateChecker) RecoverReservations(staleAfter time.Duration) (committed, held []Reservation, err error) {
+	pending, err := dc.store.Pending()
 	if err != nil {
-		return 0, fmt.Errorf("failed to open log file: %w", err)
+		return nil, nil, err
 	}
-	defer file.Close()
-
-	count := 0
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line != "" {
-			count++
+
+	cutoff := dc.now().Add(-staleAfter)
+	for _, reservation := range pending {
+		if reservation.ReservedAt.After(cutoff) {
+			continue
 		}
-	}
 
-	if err := scanner.Err(); err != nil {
-		return 0, fmt.Errorf("error reading log file: %w", err)
+		// MarkSent always sets the channel; the message ID may be unknown
+		if reservation.Article.Channel != "" || reservation.Article.MessageID != "" {
+			if err := dc.store.Commit(reservation.Article); err != nil {
+				return committed, held, err
+			}
+			committed = append(committed, reservation)
+			continue
+		}
+
+		held = append(held, reservation)
 	}
 
-	return count, nil
+	return committed, held, nil
 }
 
-// GetRecentPublished returns recently published articles
-func (dc *DuplicateChecker) GetRecentPublished(limit int) ([]models.PublishedArticle, error) {
-	if _, err := os.Stat(dc.logFilePath); os.IsNotExist(err) {
-		return []models.PublishedArticle{}, nil
-	}
+// PendingReservations returns the publishes in progress or awaiting an
+// operator, oldest first
+func (dc *DuplicateChecker) PendingReservations() ([]Reservation, error) {
+	return dc.store.Pending()
+}
 
-	file, err := os.Open(dc.logFilePath)
+// ResolveReservation settles a held reservation once an operator has
+// checked the channel: sent commits it to the history, otherwise it is
+// released so the article can be posted again. link may be given in any
+// of its variants.
+func (dc *DuplicateChecker) ResolveReservation(link string, sent bool) (models.PublishedArticle, error) {
+	pending, err := dc.store.Pending()
 	if err != nil {
-		return nil, fmt.Errorf("failed to open log file: %w", err)
+		return models.PublishedArticle{}, err
 	}
-	defer file.Close()
 
-	var articles []models.PublishedArticle
-	scanner := bufio.NewScanner(file)
-
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line == "" {
+	canonical := canonicalizeURL(link)
+	for _, reservation := range pending {
+		if reservation.Article.Link != canonical && reservation.Article.Link != link {
 			continue
 		}
-
-		// Parse line: "YYYY-MM-DD HH:MM:SS|LINK|TITLE"
-		parts := strings.Split(line, "|")
-		if len(parts) >= 3 {
-			publishedAt, err := time.Parse("2006-01-02 15:04:05", parts[0])
-			if err != nil {
-				continue // Skip malformed entries
-			}
-
-			article := models.PublishedArticle{
-				Link:        parts[1],
-				Title:       parts[2],
-				PublishedAt: publishedAt,
-			}
-			articles = append(articles, article)
+		if sent {
+			return reservation.Article, dc.store.Commit(reservation.Article)
 		}
+		return reservation.Article, dc.store.Release(reservation.Article.Link)
 	}
 
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("error reading log file: %w", err)
+	return models.PublishedArticle{}, fmt.Errorf("%w for %s", ErrNoReservation, link)
+}
+
+// CompactHistory moves history entries older than retention into gzip
+// archives in archiveDir. Their links, GUIDs and hashes stop counting as
+// published, so retention must outlast the age at which feed items are
+// ignored. It returns the number of archived entries.
+func (dc *DuplicateChecker) CompactHistory(retention time.Duration, archiveDir string) (int, error) {
+	return dc.store.Compact(dc.now().Add(-retention), PublishedArchiver(archiveDir))
+}
+
+// newRecord builds the history record for article. An item without a link
+// is recorded under its GUID.
+func (dc *DuplicateChecker) newRecord(article models.AnimeNews, details PublishDetails) models.PublishedArticle {
+	link := articleKey(article.Link, article.GUID)
+
+	var alternates []string
+	for _, alternate := range article.AlternateLinks {
+		if canonical := canonicalizeURL(alternate); canonical != link {
+			alternates = appendUnique(alternates, canonical)
+		}
 	}
 
-	// Return most recent articles (reverse order)
-	start := len(articles) - limit
-	if start < 0 {
-		start = 0
+	return models.PublishedArticle{
+		Link:           link,
+		AlternateLinks: alternates,
+		GUID:           canonicalizeURL(article.GUID),
+		ContentHash:    contentHash(article),
+		Title:          article.Title,
+		Channel:        details.Channel,
+		MessageID:      details.MessageID,
+		Model:          details.Model,
+		Prompt:         details.Prompt,
+		PostText:       details.PostText,
+		Rejections:     details.Rejections,
+		PublishedAt:    dc.now(),
 	}
+}
 
-	recent := articles[start:]
+// GetPublishedCount returns the number of published articles
+func (dc *DuplicateChecker) GetPublishedCount() (int, error) {
+	return dc.store.Count()
+}
 
-	// Reverse to get newest first
-	for i := 0; i < len(recent)/2; i++ {
-		j := len(recent) - 1 - i
-		recent[i], recent[j] = recent[j], recent[i]
-	}
+// GetRecentPublished returns recently published articles, newest first
+func (dc *DuplicateChecker) GetRecentPublished(limit int) ([]models.PublishedArticle, error) {
+	return dc.store.Recent(limit)
+}
 
-	return recent, nil
+// Close closes the underlying store
+func (dc *DuplicateChecker) Close() error {
+	return dc.store.Close()
 }
@@ -0,0 +1,82 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go-test/internal/atomicfile"
+)
+
+// FeedCache stores the last response of every feed on disk so the RSS
+// fetcher can issue conditional GET requests
+type FeedCache struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// CachedFeed is the stored response of a single feed
+type CachedFeed struct {
+	URL          string    `json:"url"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	FetchedAt    time.Time `json:"fetched_at"`
+	Body         []byte    `json:"body"`
+}
+
+// NewFeedCache creates a feed cache rooted at dir
+func NewFeedCache(dir string) *FeedCache {
+	return &FeedCache{dir: dir}
+}
+
+// Get returns the cached response for feedURL, or nil if there is none
+func (fc *FeedCache) Get(feedURL string) (*CachedFeed, error) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	data, err := os.ReadFile(fc.path(feedURL))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read feed cache: %w", err)
+	}
+
+	var entry CachedFeed
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, fmt.Errorf("failed to decode feed cache: %w", err)
+	}
+
+	return &entry, nil
+}
+
+// Put stores the response for a feed, replacing any previous entry
+func (fc *FeedCache) Put(entry *CachedFeed) error {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	if err := os.MkdirAll(fc.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create feed cache directory: %w", err)
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode feed cache: %w", err)
+	}
+
+	if err := atomicfile.WriteFile(fc.path(entry.URL), data); err != nil {
+		return fmt.Errorf("failed to write feed cache: %w", err)
+	}
+
+	return nil
+}
+
+func (fc *FeedCache) path(feedURL string) string {
+	sum := sha256.Sum256([]byte(feedURL))
+	return filepath.Join(fc.dir, hex.EncodeToString(sum[:16])+".json")
+}
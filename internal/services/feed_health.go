@@ -0,0 +1,169 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go-test/internal/atomicfile"
+)
+
+// FeedHealth is the persisted health state of a single feed
+type FeedHealth struct {
+	URL                 string    `json:"url"`
+	Source              string    `json:"source"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	LastSuccess         time.Time `json:"last_success"`
+	LastFailure         time.Time `json:"last_failure"`
+	LastError           string    `json:"last_error,omitempty"`
+	OpenUntil           time.Time `json:"open_until"`
+}
+
+// IsOpen reports whether the circuit breaker is currently skipping the feed
+func (h FeedHealth) IsOpen(now time.Time) bool {
+	return now.Before(h.OpenUntil)
+}
+
+// FeedHealthTracker tracks feed failures on disk and acts as a circuit
+// breaker. After failureThreshold consecutive failures a feed is skipped
+// for a back-off window that doubles with every further failure. Once the
+// window has passed the feed is probed again; a success closes the circuit.
+type FeedHealthTracker struct {
+	path             string
+	failureThreshold int
+	baseBackoff      time.Duration
+	maxBackoff       time.Duration
+
+	mu    sync.Mutex
+	feeds map[string]*FeedHealth
+	now   func() time.Time
+}
+
+// NewFeedHealthTracker creates a tracker and loads any state saved at path
+func NewFeedHealthTracker(path string, failureThreshold int, baseBackoff, maxBackoff time.Duration) (*FeedHealthTracker, error) {
+	tracker := &FeedHealthTracker{
+		path:             path,
+		failureThreshold: failureThreshold,
+		baseBackoff:      baseBackoff,
+		maxBackoff:       maxBackoff,
+		feeds:            make(map[string]*FeedHealth),
+		now:              time.Now,
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return tracker, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read feed health file: %w", err)
+	}
+
+	var saved []FeedHealth
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, fmt.Errorf("failed to decode feed health file: %w", err)
+	}
+
+	for i := range saved {
+		tracker.feeds[saved[i].URL] = &saved[i]
+	}
+
+	return tracker, nil
+}
+
+// Allow reports whether the feed should be fetched now
+func (t *FeedHealthTracker) Allow(feedURL string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	health, ok := t.feeds[feedURL]
+	return !ok || !health.IsOpen(t.now())
+}
+
+// RecordSuccess closes the circuit for a feed
+func (t *FeedHealthTracker) RecordSuccess(feedURL, source string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	health := t.get(feedURL, source)
+	health.ConsecutiveFailures = 0
+	health.LastSuccess = t.now()
+	health.LastError = ""
+	health.OpenUntil = time.Time{}
+}
+
+// RecordFailure counts a failure and opens the circuit once the threshold is reached
+func (t *FeedHealthTracker) RecordFailure(feedURL, source string, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	health := t.get(feedURL, source)
+	health.ConsecutiveFailures++
+	health.LastFailure = now
+	health.LastError = err.Error()
+
+	if t.failureThreshold > 0 && health.ConsecutiveFailures >= t.failureThreshold {
+		health.OpenUntil = now.Add(t.backoff(health.ConsecutiveFailures - t.failureThreshold))
+	}
+}
+
+// Get returns the health of a feed; feeds never seen before report a zero state
+func (t *FeedHealthTracker) Get(feedURL string) FeedHealth {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if health, ok := t.feeds[feedURL]; ok {
+		return *health
+	}
+	return FeedHealth{URL: feedURL}
+}
+
+// Save persists the health state of all feeds
+func (t *FeedHealthTracker) Save() error {
+	t.mu.Lock()
+	states := make([]FeedHealth, 0, len(t.feeds))
+	for _, health := range t.feeds {
+		states = append(states, *health)
+	}
+	t.mu.Unlock()
+
+	data, err := json.MarshalIndent(states, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode feed health: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(t.path), 0755); err != nil {
+		return fmt.Errorf("failed to create feed health directory: %w", err)
+	}
+
+	if err := atomicfile.WriteFile(t.path, data); err != nil {
+		return fmt.Errorf("failed to write feed health file: %w", err)
+	}
+
+	return nil
+}
+
+// backoff returns the skip window after the given number of failures past the threshold
+func (t *FeedHealthTracker) backoff(extraFailures int) time.Duration {
+	backoff := t.baseBackoff
+	for i := 0; i < extraFailures && backoff < t.maxBackoff; i++ {
+		backoff *= 2
+	}
+	if t.maxBackoff > 0 && backoff > t.maxBackoff {
+		backoff = t.maxBackoff
+	}
+	return backoff
+}
+
+func (t *FeedHealthTracker) get(feedURL, source string) *FeedHealth {
+	health, ok := t.feeds[feedURL]
+	if !ok {
+		health = &FeedHealth{URL: feedURL}
+		t.feeds[feedURL] = health
+	}
+	health.Source = source
+	return health
+}
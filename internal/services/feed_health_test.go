@@ -0,0 +1,79 @@
+package services
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFeedHealthTracker_CircuitBreaker(t *testing.T) {
+	const feedURL = "https://example.com/rss"
+
+	path := filepath.Join(t.TempDir(), "feed_health.json")
+	tracker, err := NewFeedHealthTracker(path, 2, time.Minute, 5*time.Minute)
+	if err != nil {
+		t.Fatalf("NewFeedHealthTracker() error = %v", err)
+	}
+
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	tracker.now = func() time.Time { return now }
+	failure := errors.New("connection refused")
+
+	steps := []struct {
+		name      string
+		advance   time.Duration
+		fail      bool
+		wantAllow bool
+	}{
+		{"first failure keeps circuit closed", 0, true, true},
+		{"second failure opens circuit", 0, true, false},
+		{"still open inside back-off", 59 * time.Second, false, false},
+		{"probe allowed after back-off", time.Second, false, true},
+		{"failed probe doubles back-off", 0, true, false},
+		{"probe allowed after doubled back-off", 2 * time.Minute, false, true},
+	}
+
+	for _, step := range steps {
+		now = now.Add(step.advance)
+		if step.fail {
+			tracker.RecordFailure(feedURL, "Example", failure)
+		}
+
+		if got := tracker.Allow(feedURL); got != step.wantAllow {
+			t.Fatalf("%s: Allow() = %t; want %t", step.name, got, step.wantAllow)
+		}
+	}
+
+	tracker.RecordSuccess(feedURL, "Example")
+	if health := tracker.Get(feedURL); health.ConsecutiveFailures != 0 || health.LastError != "" || health.IsOpen(now) {
+		t.Errorf("after success health = %+v; want a closed, reset circuit", health)
+	}
+}
+
+func TestFeedHealthTracker_Persistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "feed_health.json")
+	tracker, err := NewFeedHealthTracker(path, 1, time.Hour, time.Hour)
+	if err != nil {
+		t.Fatalf("NewFeedHealthTracker() error = %v", err)
+	}
+
+	tracker.RecordFailure("https://example.com/rss", "Example", errors.New("timeout"))
+	if err := tracker.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	reloaded, err := NewFeedHealthTracker(path, 1, time.Hour, time.Hour)
+	if err != nil {
+		t.Fatalf("reload error = %v", err)
+	}
+
+	health := reloaded.Get("https://example.com/rss")
+	if health.ConsecutiveFailures != 1 || health.LastError != "timeout" || health.Source != "Example" {
+		t.Errorf("reloaded health = %+v; want the saved failure", health)
+	}
+
+	if reloaded.Allow("https://example.com/rss") {
+		t.Error("reloaded tracker allows a feed whose circuit is open")
+	}
+}
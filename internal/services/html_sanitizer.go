@@ -0,0 +1,236 @@
+package services
+
+import (
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+// SanitizedHTML is the plain-text rendering of an HTML fragment together
+// with the links and images it referenced
+type SanitizedHTML struct {
+	Text   string
+	Links  []HTMLLink
+	Images []HTMLImage
+}
+
+// HTMLLink is an outbound link found in an HTML fragment
+type HTMLLink struct {
+	URL  string
+	Text string
+}
+
+// HTMLImage is an image found in an HTML fragment
+type HTMLImage struct {
+	URL    string
+	Alt    string
+	Width  int
+	Height int
+}
+
+// droppedElements never contribute text to the sanitized output
+const droppedElements = "script, style, iframe, noscript, object, embed, form, button, input, select, textarea, svg, template, nav, aside, footer, header"
+
+// boilerplatePattern matches class or id values of share bars, ads and similar clutter
+var boilerplatePattern = regexp.MustCompile(`(?i)(^|[\s_-])(share|sharing|social|related|advert|ads?|sponsor|newsletter|subscribe|comments?)([\s_-]|$)`)
+
+// boilerplateParagraphs matches whole paragraphs added by feed generators
+var boilerplateParagraphs = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)^the post .+ appeared first on .+\.?$`),
+	regexp.MustCompile(`(?i)^(continue reading|read more)\b.*$`),
+}
+
+// blockElements start a new paragraph in the text output
+var blockElements = map[string]bool{
+	"p": true, "div": true, "br": true, "li": true, "ul": true, "ol": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"blockquote": true, "figure": true, "figcaption": true, "section": true,
+	"article": true, "table": true, "tr": true, "pre": true, "hr": true,
+}
+
+// SanitizeHTML converts an HTML fragment to plain text. Scripts, embeds
+// and boilerplate are dropped, entities are decoded and paragraph breaks
+// are kept as blank lines. Relative link and image URLs are resolved
+// against baseURL when it is set.
+func SanitizeHTML(fragment, baseURL string) SanitizedHTML {
+	var result SanitizedHTML
+
+	if strings.TrimSpace(fragment) == "" {
+		return result
+	}
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(fragment))
+	if err != nil {
+		// Fall back to whitespace cleanup when the fragment can't be parsed at all
+		result.Text = strings.Join(strings.Fields(fragment), " ")
+		return result
+	}
+
+	doc.Find(droppedElements).Remove()
+	doc.Find("[class], [id]").Each(func(_ int, s *goquery.Selection) {
+		class, _ := s.Attr("class")
+		id, _ := s.Attr("id")
+		if boilerplatePattern.MatchString(class) || boilerplatePattern.MatchString(id) {
+			s.Remove()
+		}
+	})
+
+	base, _ := url.Parse(baseURL)
+	w := &textWriter{}
+	seenLinks := make(map[string]bool)
+	seenImages := make(map[string]bool)
+
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		switch n.Type {
+		case html.TextNode:
+			w.writeText(n.Data)
+			return
+		case html.ElementNode:
+			switch n.Data {
+			case "img":
+				if image, ok := parseImage(n, base); ok && !seenImages[image.URL] {
+					seenImages[image.URL] = true
+					result.Images = append(result.Images, image)
+				}
+				return
+			case "a":
+				if href := resolveURL(attr(n, "href"), base); href != "" && !seenLinks[href] {
+					seenLinks[href] = true
+					text := strings.Join(strings.Fields(goquery.NewDocumentFromNode(n).Text()), " ")
+					result.Links = append(result.Links, HTMLLink{URL: href, Text: text})
+				}
+			}
+		}
+
+		isBlock := n.Type == html.ElementNode && blockElements[n.Data]
+		if isBlock {
+			w.breakParagraph()
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+		if isBlock {
+			w.breakParagraph()
+		}
+	}
+
+	for _, node := range doc.Nodes {
+		walk(node)
+	}
+
+	result.Text = w.String()
+	return result
+}
+
+// textWriter accumulates text into whitespace-normalized paragraphs
+type textWriter struct {
+	paragraphs   []string
+	current      strings.Builder
+	pendingSpace bool
+}
+
+func (w *textWriter) writeText(text string) {
+	if text == "" {
+		return
+	}
+
+	// Whitespace at the edges of a text node separates it from its
+	// inline neighbours, so remember it instead of dropping it
+	if strings.TrimLeft(text, " \t\r\n") != text {
+		w.pendingSpace = true
+	}
+
+	for i, word := range strings.Fields(text) {
+		if w.current.Len() > 0 && (i > 0 || w.pendingSpace) {
+			w.current.WriteByte(' ')
+		}
+		w.current.WriteString(word)
+		w.pendingSpace = false
+	}
+
+	if strings.TrimRight(text, " \t\r\n") != text {
+		w.pendingSpace = true
+	}
+}
+
+func (w *textWriter) breakParagraph() {
+	paragraph := strings.TrimSpace(w.current.String())
+	w.current.Reset()
+	w.pendingSpace = false
+
+	if paragraph == "" {
+		return
+	}
+
+	for _, pattern := range boilerplateParagraphs {
+		if pattern.MatchString(paragraph) {
+			return
+		}
+	}
+
+	w.paragraphs = append(w.paragraphs, paragraph)
+}
+
+func (w *textWriter) String() string {
+	w.breakParagraph()
+	return strings.Join(w.paragraphs, "\n\n")
+}
+
+func parseImage(n *html.Node, base *url.URL) (HTMLImage, bool) {
+	src := attr(n, "src")
+	if src == "" || strings.HasPrefix(src, "data:") {
+		src = attr(n, "data-src")
+	}
+
+	imageURL := resolveURL(src, base)
+	if imageURL == "" {
+		return HTMLImage{}, false
+	}
+
+	width, _ := strconv.Atoi(attr(n, "width"))
+	height, _ := strconv.Atoi(attr(n, "height"))
+
+	return HTMLImage{
+		URL:    imageURL,
+		Alt:    strings.TrimSpace(attr(n, "alt")),
+		Width:  width,
+		Height: height,
+	}, true
+}
+
+// resolveURL returns an absolute http(s) URL, or "" for anchors, scripts and other schemes
+func resolveURL(raw string, base *url.URL) string {
+	raw = strings.TrimSpace(raw)
+	if raw == "" || strings.HasPrefix(raw, "#") {
+		return ""
+	}
+
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+
+	if base != nil && base.IsAbs() {
+		parsed = base.ResolveReference(parsed)
+	}
+
+	if (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return ""
+	}
+
+	return parsed.String()
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
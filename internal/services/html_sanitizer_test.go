@@ -0,0 +1,80 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSanitizeHTML_Text(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"plain text", "Hello   world", "Hello world"},
+		{"entities", "Frieren&#8217;s sequel &amp; more", "Frieren’s sequel & more"},
+		{"inline tags", "<b>Bold</b> and <em>em</em>phasis", "Bold and emphasis"},
+		{"paragraphs", "<p>First</p><p>Second</p>", "First\n\nSecond"},
+		{"line breaks", "One<br/>Two<br>Three", "One\n\nTwo\n\nThree"},
+		{"scripts and iframes", `Keep<script>alert(1)</script><iframe src="x"></iframe> this`, "Keep this"},
+		{"styles", `<style>p{color:red}</style><p style="color:red">Styled</p>`, "Styled"},
+		{"figure caption", `<figure><img src="https://a.com/x.jpg"><figcaption>Key visual</figcaption></figure>`, "Key visual"},
+		{"share bar", `<p>Story</p><div class="share-buttons">Share on X</div>`, "Story"},
+		{"wordpress footer", `<p>Story</p><p>The post Story appeared first on Anime Hunch.</p>`, "Story"},
+		{"empty", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := SanitizeHTML(tt.input, "")
+			if result.Text != tt.expected {
+				t.Errorf("SanitizeHTML(%q).Text = %q; want %q", tt.input, result.Text, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSanitizeHTML_LinksAndImages(t *testing.T) {
+	input := `<p>Read the <a href="/news/1">announcement</a> or <a href="#top">jump</a>.</p>
+<a href="javascript:void(0)">nope</a>
+<img src="/img/key.jpg" alt=" Key visual " width="640" height="360">
+<img src="data:image/gif;base64,AAAA" data-src="https://cdn.example.com/lazy.png">
+<a href="https://example.com/news/1">duplicate</a>`
+
+	result := SanitizeHTML(input, "https://example.com/feed")
+
+	expectedLinks := []HTMLLink{{URL: "https://example.com/news/1", Text: "announcement"}}
+	if !reflect.DeepEqual(result.Links, expectedLinks) {
+		t.Errorf("Links = %+v; want %+v", result.Links, expectedLinks)
+	}
+
+	expectedImages := []HTMLImage{
+		{URL: "https://example.com/img/key.jpg", Alt: "Key visual", Width: 640, Height: 360},
+		{URL: "https://cdn.example.com/lazy.png"},
+	}
+	if !reflect.DeepEqual(result.Images, expectedImages) {
+		t.Errorf("Images = %+v; want %+v", result.Images, expectedImages)
+	}
+}
+
+func TestCleanTitle(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"plain", "One Piece  Episode 1100", "One Piece Episode 1100"},
+		{"entities", "Dan Da Dan&#8217;s Season 2 &amp; More", "Dan Da Dan’s Season 2 & More"},
+		{"angle brackets kept", "<Oshi no Ko> Season 2", "<Oshi no Ko> Season 2"},
+		{"markup stripped", "<b>Breaking:</b> New Trailer", "Breaking: New Trailer"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := cleanTitle(tt.input)
+			if result != tt.expected {
+				t.Errorf("cleanTitle(%q) = %q; want %q", tt.input, result, tt.expected)
+			}
+		})
+	}
+}
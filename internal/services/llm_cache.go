@@ -0,0 +1,259 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"go-test/internal/atomicfile"
+	"go-test/internal/filelock"
+	"go-test/internal/models"
+)
+
+const (
+	// llmCacheStatsFile holds the hit and miss counts of all runs
+	llmCacheStatsFile = "stats.json"
+	// llmCacheLockTimeout bounds the wait for another process updating the counts
+	llmCacheLockTimeout = 5 * time.Second
+)
+
+// LLMCache stores model responses on disk, addressed by a hash of the
+// provider, model, prompt template version and rendered prompt, so re-runs
+// and retried cycles do not pay for the same answer twice. Entries expire
+// after the TTL. With bypass set, lookups always miss but fresh responses
+// still replace the stored ones. Hits and misses are counted in memory
+// and added to the totals on disk by Flush.
+type LLMCache struct {
+	dir    string
+	ttl    time.Duration
+	bypass bool
+	now    func() time.Time
+
+	mu     sync.Mutex
+	hits   int64 // not yet flushed
+	misses int64 // not yet flushed
+}
+
+// llmCacheEntry is one cached response. Everything but the response is
+// there for whoever inspects the cache directory.
+type llmCacheEntry struct {
+	Provider      string    `json:"provider"`
+	Model         string    `json:"model"`
+	PromptVersion string    `json:"prompt_version"`
+	Response      string    `json:"response"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// NewLLMCache creates a cache in dir whose entries live for ttl
+func NewLLMCache(dir string, ttl time.Duration, bypass bool) (*LLMCache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create LLM cache directory: %w", err)
+	}
+
+	return &LLMCache{
+		dir:    dir,
+		ttl:    ttl,
+		bypass: bypass,
+		now:    time.Now,
+	}, nil
+}
+
+// llmCacheKey hashes everything that decides a model's answer. The parts
+// are separated by NUL bytes, so no two requests share a key.
+func llmCacheKey(provider, model, promptVersion, prompt string) string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{provider, model, promptVersion, prompt}, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
+// path returns where the entry for key lives, spread over subdirectories
+// by the first byte of the key to keep directories small
+func (c *LLMCache) path(key string) string {
+	return filepath.Join(c.dir, key[:2], key+".json")
+}
+
+// Get returns the cached response of llm to prompt, rendered from the
+// template version promptVersion
+func (c *LLMCache) Get(llm LLMProvider, promptVersion, prompt string) (string, bool) {
+	response, ok := c.lookup(llmCacheKey(llm.Name(), llm.Model(), promptVersion, prompt))
+	c.count(ok)
+	return response, ok
+}
+
+func (c *LLMCache) lookup(key string) (string, bool) {
+	if c.bypass {
+		return "", false
+	}
+
+	path := c.path(key)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("⚠️  Failed to read LLM cache entry: %v", err)
+		}
+		return "", false
+	}
+
+	var entry llmCacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		log.Printf("⚠️  Dropping unreadable LLM cache entry %s: %v", path, err)
+		os.Remove(path)
+		return "", false
+	}
+
+	if c.now().Sub(entry.CreatedAt) >= c.ttl {
+		os.Remove(path)
+		return "", false
+	}
+
+	return entry.Response, true
+}
+
+// Put stores the response of llm to prompt
+func (c *LLMCache) Put(llm LLMProvider, promptVersion, prompt, response string) error {
+	data, err := json.MarshalIndent(llmCacheEntry{
+		Provider:      llm.Name(),
+		Model:         llm.Model(),
+		PromptVersion: promptVersion,
+		Response:      response,
+		CreatedAt:     c.now(),
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode LLM cache entry: %w", err)
+	}
+
+	path := c.path(llmCacheKey(llm.Name(), llm.Model(), promptVersion, prompt))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create LLM cache directory: %w", err)
+	}
+
+	if err := atomicfile.WriteFile(path, data); err != nil {
+		return fmt.Errorf("failed to write LLM cache entry: %w", err)
+	}
+
+	return nil
+}
+
+// Stats returns the hit and miss counts of all runs, this one's unflushed
+// counts included, and the number of stored entries, expired ones included
+// until they are next looked up
+func (c *LLMCache) Stats() (models.CacheStats, error) {
+	stats, err := c.readStats()
+	if err != nil {
+		return stats, err
+	}
+
+	c.mu.Lock()
+	stats.Hits += c.hits
+	stats.Misses += c.misses
+	c.mu.Unlock()
+
+	err = filepath.WalkDir(c.dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() && filepath.Dir(path) != c.dir && strings.HasSuffix(path, ".json") {
+			stats.Entries++
+		}
+		return nil
+	})
+	if err != nil {
+		return stats, fmt.Errorf("failed to count LLM cache entries: %w", err)
+	}
+
+	return stats, nil
+}
+
+// count adds a lookup to the counts of this run
+func (c *LLMCache) count(hit bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if hit {
+		c.hits++
+	} else {
+		c.misses++
+	}
+}
+
+// Flush adds the counts of this run to the totals shared with other
+// processes. It is meant to run once per run or cycle; counts that fail to
+// flush are kept for the next call.
+func (c *LLMCache) Flush() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.hits == 0 && c.misses == 0 {
+		return nil
+	}
+
+	if err := c.updateStats(func(stats *models.CacheStats) {
+		stats.Hits += c.hits
+		stats.Misses += c.misses
+	}); err != nil {
+		return fmt.Errorf("failed to flush LLM cache stats: %w", err)
+	}
+
+	c.hits, c.misses = 0, 0
+	return nil
+}
+
+// updateStats applies fn to the counts on disk while holding a lock, as
+// the app and CLI may share the cache
+func (c *LLMCache) updateStats(fn func(stats *models.CacheStats)) error {
+	lock, err := os.OpenFile(filepath.Join(c.dir, llmCacheStatsFile+".lock"), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open stats lock: %w", err)
+	}
+	defer lock.Close()
+
+	if err := filelock.Lock(lock, llmCacheLockTimeout); err != nil {
+		return fmt.Errorf("failed to lock stats: %w", err)
+	}
+	defer filelock.Unlock(lock)
+
+	stats, err := c.readStats()
+	if err != nil {
+		return err
+	}
+	fn(&stats)
+
+	// Entries is counted by Stats, not stored
+	data, err := json.Marshal(struct {
+		Hits   int64 `json:"hits"`
+		Misses int64 `json:"misses"`
+	}{stats.Hits, stats.Misses})
+	if err != nil {
+		return fmt.Errorf("failed to encode stats: %w", err)
+	}
+
+	if err := atomicfile.WriteFile(filepath.Join(c.dir, llmCacheStatsFile), data); err != nil {
+		return fmt.Errorf("failed to write stats: %w", err)
+	}
+
+	return nil
+}
+
+func (c *LLMCache) readStats() (models.CacheStats, error) {
+	var stats models.CacheStats
+
+	data, err := os.ReadFile(filepath.Join(c.dir, llmCacheStatsFile))
+	if os.IsNotExist(err) {
+		return stats, nil
+	}
+	if err != nil {
+		return stats, fmt.Errorf("failed to read LLM cache stats: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &stats); err != nil {
+		return stats, fmt.Errorf("failed to decode LLM cache stats: %w", err)
+	}
+	return stats, nil
+}
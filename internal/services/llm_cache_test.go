@@ -0,0 +1,149 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go-test/internal/config"
+	"go-test/internal/models"
+	"go-test/internal/prompts"
+)
+
+func TestLLMCache(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2026, 1, 10, 12, 0, 0, 0, time.UTC)
+
+	newLLM := func(model string) LLMProvider {
+		llm, err := NewLLMProvider(&config.Config{LLMProvider: "ollama", LLMBaseURL: "http://localhost:11434"}, model)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return llm
+	}
+	llm := newLLM("llama3.1")
+
+	cache, err := NewLLMCache(dir, time.Hour, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache.now = func() time.Time { return now }
+
+	if err := cache.Put(llm, "personas/casual_youth@casual_youth-2", "Write a post", "ආයුබෝවන්"); err != nil {
+		t.Fatal(err)
+	}
+
+	lookups := []struct {
+		name    string
+		llm     LLMProvider
+		version string
+		prompt  string
+		after   time.Duration
+		wantHit bool
+	}{
+		{"same request", llm, "personas/casual_youth@casual_youth-2", "Write a post", 0, true},
+		{"other model", newLLM("qwen2.5"), "personas/casual_youth@casual_youth-2", "Write a post", 0, false},
+		{"other prompt version", llm, "personas/casual_youth@casual_youth-3", "Write a post", 0, false},
+		{"other prompt", llm, "personas/casual_youth@casual_youth-2", "Write a meme", 0, false},
+		{"before expiry", llm, "personas/casual_youth@casual_youth-2", "Write a post", 59 * time.Minute, true},
+		{"expired", llm, "personas/casual_youth@casual_youth-2", "Write a post", time.Hour, false},
+	}
+	for _, tt := range lookups {
+		cache.now = func() time.Time { return now.Add(tt.after) }
+		response, hit := cache.Get(tt.llm, tt.version, tt.prompt)
+		if hit != tt.wantHit || (hit && response != "ආයුබෝවන්") {
+			t.Errorf("%s: Get() = %q, %v; want hit %v", tt.name, response, hit, tt.wantHit)
+		}
+	}
+
+	// Another process using the same directory, with bypass set
+	bypassed, err := NewLLMCache(dir, time.Hour, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bypassed.Put(llm, "analysis@analysis-2", "Analyze", "{}"); err != nil {
+		t.Fatal(err)
+	}
+	if _, hit := bypassed.Get(llm, "analysis@analysis-2", "Analyze"); hit {
+		t.Error("Get() hit with bypass set")
+	}
+
+	// Counts stay in memory until flushed, and flushing twice adds them once
+	steps := []struct {
+		name  string
+		flush []*LLMCache
+		want  models.CacheStats
+	}{
+		{"before flushing", nil, models.CacheStats{Hits: 2, Misses: 4, Entries: 1}},
+		{"after both flush", []*LLMCache{cache, bypassed}, models.CacheStats{Hits: 2, Misses: 5, Entries: 1}},
+		{"after flushing again", []*LLMCache{cache, bypassed}, models.CacheStats{Hits: 2, Misses: 5, Entries: 1}},
+	}
+	for _, step := range steps {
+		for _, c := range step.flush {
+			if err := c.Flush(); err != nil {
+				t.Fatalf("%s: Flush() error = %v", step.name, err)
+			}
+		}
+		stats, err := cache.Stats()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stats != step.want {
+			t.Errorf("%s: Stats() = %+v; want %+v", step.name, stats, step.want)
+		}
+	}
+}
+
+func TestSinhalaWriter_CachesAcceptedPosts(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []interface{}{map[string]interface{}{"message": map[string]string{"content": testGoodPost}}},
+		})
+	}))
+	defer server.Close()
+
+	llm, err := NewLLMProvider(&config.Config{
+		LLMProvider: "openai",
+		LLMBaseURL:  server.URL,
+		LLMAPIKey:   "test-key",
+		LLMTimeout:  5 * time.Second,
+	}, "test-model")
+	if err != nil {
+		t.Fatal(err)
+	}
+	library, err := prompts.Load(filepath.Join("..", "..", "configs", "prompts"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache, err := NewLLMCache(t.TempDir(), time.Hour, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	news := PostPrompt{Title: "Frieren season 2", Link: testPostLink}
+	for _, persona := range []string{"casual_youth", "casual_youth", "news_anchor"} {
+		writer, err := NewSinhalaWriter(llm, library, persona)
+		if err != nil {
+			t.Fatal(err)
+		}
+		writer.SetCache(cache)
+
+		post, err := writer.WritePost(context.Background(), news)
+		if err != nil {
+			t.Fatalf("%s: WritePost() error = %v", persona, err)
+		}
+		if post.Text != testGoodPost {
+			t.Errorf("%s: WritePost() = %q", persona, post.Text)
+		}
+	}
+
+	if requests != 2 {
+		t.Errorf("model was asked %d times; want 2, the repeated casual_youth post coming from the cache", requests)
+	}
+}
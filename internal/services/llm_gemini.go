@@ -0,0 +1,169 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// GeminiRequest represents the request structure for Gemini API
+type GeminiRequest struct {
+	Contents         []GeminiContent         `json:"contents"`
+	GenerationConfig *GeminiGenerationConfig `json:"generationConfig,omitempty"`
+}
+
+type GeminiContent struct {
+	Parts []GeminiPart `json:"parts"`
+}
+
+type GeminiPart struct {
+	Text string `json:"text"`
+}
+
+// GeminiGenerationConfig asks for structured JSON output
+type GeminiGenerationConfig struct {
+	ResponseMimeType string     `json:"responseMimeType,omitempty"`
+	ResponseSchema   JSONSchema `json:"responseSchema,omitempty"`
+}
+
+// GeminiResponse represents the response from Gemini API
+type GeminiResponse struct {
+	Candidates []GeminiCandidate `json:"candidates"`
+}
+
+type GeminiCandidate struct {
+	Content GeminiContent `json:"content"`
+}
+
+// geminiSchemaKeys are the JSON Schema keywords Gemini's OpenAPI-style
+// response schema accepts; it rejects requests with any others
+var geminiSchemaKeys = map[string]bool{
+	"type": true, "format": true, "description": true, "nullable": true, "enum": true,
+	"properties": true, "required": true, "items": true, "minItems": true, "maxItems": true,
+}
+
+// GeminiProvider talks to the Google Gemini API
+type GeminiProvider struct {
+	llmClient
+}
+
+// Name implements LLMProvider
+func (p *GeminiProvider) Name() string {
+	return "gemini"
+}
+
+// Generate implements LLMProvider
+func (p *GeminiProvider) Generate(ctx context.Context, prompt string) (string, error) {
+	return p.generate(ctx, prompt, nil)
+}
+
+// GenerateWithSchema implements LLMProvider
+func (p *GeminiProvider) GenerateWithSchema(ctx context.Context, prompt string, schema JSONSchema) (string, error) {
+	return p.generate(ctx, prompt, &GeminiGenerationConfig{
+		ResponseMimeType: "application/json",
+		ResponseSchema:   geminiSchema(schema),
+	})
+}
+
+func (p *GeminiProvider) generate(ctx context.Context, prompt string, generation *GeminiGenerationConfig) (string, error) {
+	request := GeminiRequest{
+		Contents:         []GeminiContent{{Parts: []GeminiPart{{Text: prompt}}}},
+		GenerationConfig: generation,
+	}
+
+	var response GeminiResponse
+	url := fmt.Sprintf("%s/models/%s:generateContent", p.baseURL, p.model)
+	if err := p.postJSON(ctx, p.Name(), url, p.header(), request, &response); err != nil {
+		return "", err
+	}
+
+	if len(response.Candidates) == 0 || len(response.Candidates[0].Content.Parts) == 0 {
+		return "", fmt.Errorf("no content generated by AI")
+	}
+
+	var text strings.Builder
+	for _, part := range response.Candidates[0].Content.Parts {
+		text.WriteString(part.Text)
+	}
+	return text.String(), nil
+}
+
+// Embed implements LLMProvider
+func (p *GeminiProvider) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	type embedRequest struct {
+		Model   string        `json:"model"`
+		Content GeminiContent `json:"content"`
+	}
+
+	requests := make([]embedRequest, len(texts))
+	for i, text := range texts {
+		requests[i] = embedRequest{Model: "models/" + p.embedModel, Content: GeminiContent{Parts: []GeminiPart{{Text: text}}}}
+	}
+
+	var response struct {
+		Embeddings []struct {
+			Values []float32 `json:"values"`
+		} `json:"embeddings"`
+	}
+	url := fmt.Sprintf("%s/models/%s:batchEmbedContents", p.baseURL, p.embedModel)
+	if err := p.postJSON(ctx, p.Name(), url, p.header(), map[string]interface{}{"requests": requests}, &response); err != nil {
+		return nil, err
+	}
+
+	if len(response.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("gemini returned %d embeddings for %d texts", len(response.Embeddings), len(texts))
+	}
+
+	vectors := make([][]float32, len(texts))
+	for i, embedding := range response.Embeddings {
+		vectors[i] = embedding.Values
+	}
+	return vectors, nil
+}
+
+// header authenticates requests. The key goes in a header rather than the
+// "key" query parameter so it never shows up in *url.Error messages.
+func (p *GeminiProvider) header() http.Header {
+	return http.Header{"X-Goog-Api-Key": {p.apiKey}}
+}
+
+// geminiSchema converts a JSON Schema to Gemini's dialect: types are upper
+// case and unsupported keywords, such as additionalProperties, are dropped
+func geminiSchema(schema JSONSchema) JSONSchema {
+	converted := make(JSONSchema, len(schema))
+	for key, value := range schema {
+		if !geminiSchemaKeys[key] {
+			continue
+		}
+
+		switch key {
+		case "type":
+			if name, ok := value.(string); ok {
+				value = strings.ToUpper(name)
+			}
+		case "items":
+			value = geminiSchema(asSchema(value))
+		case "properties":
+			properties := make(JSONSchema)
+			for name, property := range asSchema(value) {
+				properties[name] = geminiSchema(asSchema(property))
+			}
+			value = properties
+		}
+		converted[key] = value
+	}
+	return converted
+}
+
+// asSchema reads a nested schema built from JSONSchema or a plain map
+func asSchema(value interface{}) JSONSchema {
+	switch typed := value.(type) {
+	case JSONSchema:
+		return typed
+	case map[string]interface{}:
+		return typed
+	default:
+		return JSONSchema{}
+	}
+}
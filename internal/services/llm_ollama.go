@@ -0,0 +1,70 @@
+package services
+
+import (
+	"context"
+	"fmt"
+)
+
+// OllamaProvider runs local models through Ollama's native API
+type OllamaProvider struct {
+	llmClient
+}
+
+type ollamaChatRequest struct {
+	Model    string          `json:"model"`
+	Messages []openAIMessage `json:"messages"`
+	Stream   bool            `json:"stream"`
+	Format   JSONSchema      `json:"format,omitempty"`
+}
+
+// Name implements LLMProvider
+func (p *OllamaProvider) Name() string {
+	return "ollama"
+}
+
+// Generate implements LLMProvider
+func (p *OllamaProvider) Generate(ctx context.Context, prompt string) (string, error) {
+	return p.chat(ctx, prompt, nil)
+}
+
+// GenerateWithSchema implements LLMProvider. Ollama constrains the output
+// to the schema through its format parameter.
+func (p *OllamaProvider) GenerateWithSchema(ctx context.Context, prompt string, schema JSONSchema) (string, error) {
+	return p.chat(ctx, prompt, schema)
+}
+
+func (p *OllamaProvider) chat(ctx context.Context, prompt string, format JSONSchema) (string, error) {
+	request := ollamaChatRequest{
+		Model:    p.model,
+		Messages: []openAIMessage{{Role: "user", Content: prompt}},
+		Format:   format,
+	}
+
+	var response struct {
+		Message openAIMessage `json:"message"`
+	}
+	if err := p.postJSON(ctx, p.Name(), p.baseURL+"/api/chat", nil, request, &response); err != nil {
+		return "", err
+	}
+
+	if response.Message.Content == "" {
+		return "", fmt.Errorf("no content generated by AI")
+	}
+	return response.Message.Content, nil
+}
+
+// Embed implements LLMProvider
+func (p *OllamaProvider) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	var response struct {
+		Embeddings [][]float32 `json:"embeddings"`
+	}
+	request := map[string]interface{}{"model": p.embedModel, "input": texts}
+	if err := p.postJSON(ctx, p.Name(), p.baseURL+"/api/embed", nil, request, &response); err != nil {
+		return nil, err
+	}
+
+	if len(response.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("ollama returned %d embeddings for %d texts", len(response.Embeddings), len(texts))
+	}
+	return response.Embeddings, nil
+}
@@ -0,0 +1,120 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// OpenAIProvider talks to the OpenAI API or any server that implements its
+// chat completions and embeddings endpoints, such as llama.cpp's
+// llama-server, vLLM or LM Studio
+type OpenAIProvider struct {
+	llmClient
+}
+
+type openAIMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type openAIChatRequest struct {
+	Model          string          `json:"model"`
+	Messages       []openAIMessage `json:"messages"`
+	ResponseFormat interface{}     `json:"response_format,omitempty"`
+}
+
+type openAIChatResponse struct {
+	Choices []struct {
+		Message openAIMessage `json:"message"`
+	} `json:"choices"`
+}
+
+// Name implements LLMProvider
+func (p *OpenAIProvider) Name() string {
+	if p.name != "" {
+		return p.name
+	}
+	return "openai"
+}
+
+// Generate implements LLMProvider
+func (p *OpenAIProvider) Generate(ctx context.Context, prompt string) (string, error) {
+	return p.chat(ctx, prompt, nil)
+}
+
+// GenerateWithSchema implements LLMProvider. Servers that reject the
+// response_format parameter, as older llama.cpp builds and many other
+// compatible servers do, yield ErrSchemaUnsupported.
+func (p *OpenAIProvider) GenerateWithSchema(ctx context.Context, prompt string, schema JSONSchema) (string, error) {
+	text, err := p.chat(ctx, prompt, map[string]interface{}{
+		"type": "json_schema",
+		"json_schema": map[string]interface{}{
+			"name":   "response",
+			"schema": schema,
+		},
+	})
+
+	var statusErr *LLMStatusError
+	if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusBadRequest &&
+		(strings.Contains(statusErr.Body, "response_format") || strings.Contains(statusErr.Body, "json_schema")) {
+		return "", fmt.Errorf("%w by %s: %v", ErrSchemaUnsupported, p.Name(), err)
+	}
+	return text, err
+}
+
+func (p *OpenAIProvider) chat(ctx context.Context, prompt string, responseFormat interface{}) (string, error) {
+	request := openAIChatRequest{
+		Model:          p.model,
+		Messages:       []openAIMessage{{Role: "user", Content: prompt}},
+		ResponseFormat: responseFormat,
+	}
+
+	var response openAIChatResponse
+	if err := p.postJSON(ctx, p.Name(), p.baseURL+"/chat/completions", p.header(), request, &response); err != nil {
+		return "", err
+	}
+
+	if len(response.Choices) == 0 || response.Choices[0].Message.Content == "" {
+		return "", fmt.Errorf("no content generated by AI")
+	}
+	return response.Choices[0].Message.Content, nil
+}
+
+// Embed implements LLMProvider
+func (p *OpenAIProvider) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	var response struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	request := map[string]interface{}{"model": p.embedModel, "input": texts}
+	if err := p.postJSON(ctx, p.Name(), p.baseURL+"/embeddings", p.header(), request, &response); err != nil {
+		return nil, err
+	}
+
+	vectors := make([][]float32, len(texts))
+	for _, item := range response.Data {
+		if item.Index < 0 || item.Index >= len(texts) {
+			return nil, fmt.Errorf("%s returned an embedding for unknown input %d", p.Name(), item.Index)
+		}
+		vectors[item.Index] = item.Embedding
+	}
+	for i, vector := range vectors {
+		if vector == nil {
+			return nil, fmt.Errorf("%s returned no embedding for input %d", p.Name(), i)
+		}
+	}
+	return vectors, nil
+}
+
+// header authenticates requests; local servers usually need no key
+func (p *OpenAIProvider) header() http.Header {
+	if p.apiKey == "" {
+		return nil
+	}
+	return http.Header{"Authorization": {"Bearer " + p.apiKey}}
+}
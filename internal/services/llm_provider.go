@@ -0,0 +1,144 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"go-test/internal/config"
+)
+
+// LLMProvider is a large language model backend. The writer and the
+// analyzer only talk to models through it, so the model and where it runs
+// are a matter of configuration.
+type LLMProvider interface {
+	// Every provider is an HTTP API, so its traffic can be recorded and replayed
+	HTTPService
+
+	// Name identifies the provider, e.g. "gemini"
+	Name() string
+	// Model returns the model that generates text
+	Model() string
+
+	// Generate returns the model's answer to prompt
+	Generate(ctx context.Context, prompt string) (string, error)
+	// GenerateWithSchema asks for an answer that is a JSON document matching
+	// schema and returns it undecoded. Providers pass the schema to the
+	// model's structured output mode, which constrains but does not always
+	// guarantee the shape, so callers still validate what they decode.
+	// Servers without structured output fail with ErrSchemaUnsupported.
+	GenerateWithSchema(ctx context.Context, prompt string, schema JSONSchema) (string, error)
+	// Embed returns one embedding vector per text, using the embedding model
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// JSONSchema is a JSON Schema for structured output. Stick to type,
+// properties, required, items, enum and description, which every provider
+// understands.
+type JSONSchema map[string]interface{}
+
+// ErrSchemaUnsupported is returned by GenerateWithSchema when the server
+// or model cannot produce structured output, so callers can fall back to
+// parsing free text
+var ErrSchemaUnsupported = errors.New("structured output is not supported")
+
+// LLMStatusError is returned when a provider answers with an error status
+type LLMStatusError struct {
+	Provider   string
+	StatusCode int
+	Body       string
+}
+
+func (e *LLMStatusError) Error() string {
+	return fmt.Sprintf("%s API error (status %d): %s", e.Provider, e.StatusCode, e.Body)
+}
+
+// NewLLMProvider creates the provider selected by LLM_PROVIDER for model.
+// The writer and analyzer each get their own, as they may use different models.
+func NewLLMProvider(cfg *config.Config, model string) (LLMProvider, error) {
+	client := llmClient{
+		baseURL:    strings.TrimSuffix(cfg.LLMBaseURL, "/"),
+		apiKey:     cfg.LLMAPIKey,
+		model:      model,
+		embedModel: cfg.LLMEmbedModel,
+		httpClient: &http.Client{Timeout: cfg.LLMTimeout},
+	}
+
+	switch cfg.LLMProvider {
+	case "gemini":
+		client.apiKey = cfg.GeminiAPIKey
+		return &GeminiProvider{llmClient: client}, nil
+	case "openai", "llamacpp":
+		// llama.cpp's server speaks the OpenAI API
+		client.name = cfg.LLMProvider
+		return &OpenAIProvider{llmClient: client}, nil
+	case "ollama":
+		return &OllamaProvider{llmClient: client}, nil
+	default:
+		return nil, fmt.Errorf("unknown LLM provider %q", cfg.LLMProvider)
+	}
+}
+
+// llmClient holds what all providers share: an endpoint, credentials,
+// models and an HTTP client
+type llmClient struct {
+	name       string
+	baseURL    string
+	apiKey     string
+	model      string
+	embedModel string
+	httpClient *http.Client
+}
+
+// SetTransport replaces the transport used for model requests
+func (c *llmClient) SetTransport(rt http.RoundTripper) {
+	c.httpClient.Transport = rt
+}
+
+// Model implements LLMProvider
+func (c *llmClient) Model() string {
+	return c.model
+}
+
+// postJSON sends payload to url and decodes the JSON answer into result.
+// Error statuses become an *LLMStatusError.
+func (c *llmClient) postJSON(ctx context.Context, provider, url string, header http.Header, payload, result interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &LLMStatusError{Provider: provider, StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(data))}
+	}
+
+	if err := json.Unmarshal(data, result); err != nil {
+		return fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"go-test/internal/config"
+)
+
+// llmRoute is the canned answer of a fake provider endpoint
+type llmRoute struct {
+	path     string
+	response string
+}
+
+func TestLLMProviders(t *testing.T) {
+	schema := JSONSchema{
+		"type":                 "object",
+		"properties":           map[string]interface{}{"title": map[string]interface{}{"type": "string"}},
+		"required":             []string{"title"},
+		"additionalProperties": false,
+	}
+
+	tests := []struct {
+		provider string
+		generate llmRoute
+		embed    llmRoute
+		// schemaField is where the request carries the schema
+		schemaField func(request map[string]interface{}) interface{}
+	}{
+		{
+			provider: "gemini",
+			generate: llmRoute{"/models/test-model:generateContent", `{"candidates":[{"content":{"parts":[{"text":"{\"title\":"},{"text":"\"Hi\"}"}]}}]}`},
+			embed:    llmRoute{"/models/test-embed:batchEmbedContents", `{"embeddings":[{"values":[0.1,0.2]},{"values":[0.3,0.4]}]}`},
+			schemaField: func(request map[string]interface{}) interface{} {
+				config, _ := request["generationConfig"].(map[string]interface{})
+				return config["responseSchema"]
+			},
+		},
+		{
+			provider: "openai",
+			generate: llmRoute{"/chat/completions", `{"choices":[{"message":{"role":"assistant","content":"{\"title\":\"Hi\"}"}}]}`},
+			embed:    llmRoute{"/embeddings", `{"data":[{"index":1,"embedding":[0.3,0.4]},{"index":0,"embedding":[0.1,0.2]}]}`},
+			schemaField: func(request map[string]interface{}) interface{} {
+				format, _ := request["response_format"].(map[string]interface{})
+				jsonSchema, _ := format["json_schema"].(map[string]interface{})
+				return jsonSchema["schema"]
+			},
+		},
+		{
+			provider: "ollama",
+			generate: llmRoute{"/api/chat", `{"message":{"role":"assistant","content":"{\"title\":\"Hi\"}"},"done":true}`},
+			embed:    llmRoute{"/api/embed", `{"embeddings":[[0.1,0.2],[0.3,0.4]]}`},
+			schemaField: func(request map[string]interface{}) interface{} {
+				return request["format"]
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.provider, func(t *testing.T) {
+			var lastRequest map[string]interface{}
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				lastRequest = nil
+				json.Unmarshal(body, &lastRequest)
+
+				switch r.URL.Path {
+				case tt.generate.path:
+					io.WriteString(w, tt.generate.response)
+				case tt.embed.path:
+					io.WriteString(w, tt.embed.response)
+				default:
+					http.Error(w, "unexpected path "+r.URL.Path, http.StatusNotFound)
+				}
+			}))
+			defer server.Close()
+
+			llm, err := NewLLMProvider(&config.Config{
+				LLMProvider:   tt.provider,
+				LLMBaseURL:    server.URL + "/",
+				LLMAPIKey:     "test-key",
+				GeminiAPIKey:  "test-key",
+				LLMEmbedModel: "test-embed",
+				LLMTimeout:    5 * time.Second,
+			}, "test-model")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if llm.Name() != tt.provider || llm.Model() != "test-model" {
+				t.Errorf("provider = %s/%s; want %s/test-model", llm.Name(), llm.Model(), tt.provider)
+			}
+
+			ctx := context.Background()
+			text, err := llm.Generate(ctx, "Say hi")
+			if err != nil {
+				t.Fatalf("Generate() error = %v", err)
+			}
+			if text != `{"title":"Hi"}` {
+				t.Errorf("Generate() = %q", text)
+			}
+			if tt.schemaField(lastRequest) != nil {
+				t.Error("Generate() sent a response schema")
+			}
+
+			if _, err := llm.GenerateWithSchema(ctx, "Say hi", schema); err != nil {
+				t.Fatalf("GenerateWithSchema() error = %v", err)
+			}
+			if tt.schemaField(lastRequest) == nil {
+				t.Error("GenerateWithSchema() sent no response schema")
+			}
+
+			vectors, err := llm.Embed(ctx, []string{"a", "b"})
+			if err != nil {
+				t.Fatalf("Embed() error = %v", err)
+			}
+			want := [][]float32{{0.1, 0.2}, {0.3, 0.4}}
+			if !reflect.DeepEqual(vectors, want) {
+				t.Errorf("Embed() = %v; want %v", vectors, want)
+			}
+		})
+	}
+}
+
+func TestLLMProvider_StatusError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			t.Errorf("Authorization = %q", r.Header.Get("Authorization"))
+		}
+		http.Error(w, `{"error":"slow down"}`, http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	llm, err := NewLLMProvider(&config.Config{LLMProvider: "llamacpp", LLMBaseURL: server.URL, LLMAPIKey: "secret"}, "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = llm.Generate(context.Background(), "hi")
+	var statusErr *LLMStatusError
+	if !errors.As(err, &statusErr) {
+		t.Fatalf("Generate() error = %v; want *LLMStatusError", err)
+	}
+	if statusErr.Provider != "llamacpp" || statusErr.StatusCode != http.StatusTooManyRequests || statusErr.Body != `{"error":"slow down"}` {
+		t.Errorf("status error = %+v", statusErr)
+	}
+}
+
+func TestGeminiProvider_KeyInHeader(t *testing.T) {
+	var gotKey, gotQuery string
+	llm, err := NewLLMProvider(&config.Config{LLMProvider: "gemini", LLMBaseURL: "https://gemini.example.com", GeminiAPIKey: "secret-key"}, "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	llm.(HTTPService).SetTransport(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		gotKey, gotQuery = req.Header.Get("X-Goog-Api-Key"), req.URL.RawQuery
+		return nil, errors.New("connection refused")
+	}))
+
+	_, err = llm.Generate(context.Background(), "hi")
+	if err == nil {
+		t.Fatal("Generate() error = nil; want the transport failure")
+	}
+	if gotKey != "secret-key" || gotQuery != "" {
+		t.Errorf("request key header = %q, query = %q; want the key only in the header", gotKey, gotQuery)
+	}
+	if strings.Contains(err.Error(), "secret-key") {
+		t.Errorf("Generate() error = %v; leaks the API key", err)
+	}
+}
+
+func TestGeminiSchema(t *testing.T) {
+	schema := JSONSchema{
+		"type":                 "object",
+		"additionalProperties": false,
+		"properties": map[string]interface{}{
+			"tags": map[string]interface{}{
+				"type":  "array",
+				"items": JSONSchema{"type": "string", "pattern": "^#"},
+			},
+		},
+		"required": []string{"tags"},
+	}
+
+	want := JSONSchema{
+		"type": "OBJECT",
+		"properties": JSONSchema{
+			"tags": JSONSchema{
+				"type":  "ARRAY",
+				"items": JSONSchema{"type": "STRING"},
+			},
+		},
+		"required": []string{"tags"},
+	}
+
+	if got := geminiSchema(schema); !reflect.DeepEqual(got, want) {
+		t.Errorf("geminiSchema() = %#v\nwant %#v", got, want)
+	}
+}
@@ -0,0 +1,280 @@
+package services
+
+import (
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go-test/internal/models"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/mmcdole/gofeed"
+	ext "github.com/mmcdole/gofeed/extensions"
+)
+
+// Base ranks for each place media can come from. Publishers mark up
+// media:content and enclosures on purpose, while inline images are often
+// icons or tracking pixels.
+const (
+	mediaRankContent    = 50
+	mediaRankEnclosure  = 40
+	mediaRankOpenGraph  = 35
+	mediaRankItemImage  = 30
+	mediaRankThumbnail  = 20
+	mediaRankInline     = 10
+	mediaSmallPenalty   = 30
+	mediaMinUsefulWidth = 200
+)
+
+var videoExtensions = map[string]bool{".mp4": true, ".webm": true, ".mov": true, ".m4v": true, ".m3u8": true}
+var imageExtensions = map[string]bool{".jpg": true, ".jpeg": true, ".png": true, ".gif": true, ".webp": true, ".avif": true}
+
+// rankedMedia is a media candidate with its ranking score
+type rankedMedia struct {
+	item  models.MediaItem
+	score int
+}
+
+// mediaCollector deduplicates media candidates by URL, keeping the best score
+type mediaCollector struct {
+	base  *url.URL
+	items map[string]*rankedMedia
+	order []string
+}
+
+func newMediaCollector(baseURL string) *mediaCollector {
+	base, _ := url.Parse(baseURL)
+	return &mediaCollector{base: base, items: make(map[string]*rankedMedia)}
+}
+
+// extractMedia collects the images and videos of a feed item, ranked best
+// first. Inline images come from the sanitized item description.
+func extractMedia(item *gofeed.Item, inline []HTMLImage) []models.MediaItem {
+	c := newMediaCollector(item.Link)
+
+	for _, group := range mediaExtensions(item.Extensions, "group") {
+		c.addMediaRSS(group.Children)
+	}
+	if media, ok := item.Extensions["media"]; ok {
+		c.addMediaRSS(media)
+	}
+
+	for _, enclosure := range item.Enclosures {
+		if enclosure == nil {
+			continue
+		}
+		c.add(models.MediaItem{URL: enclosure.URL, MIMEType: enclosure.Type}, mediaRankEnclosure)
+	}
+
+	if item.Image != nil {
+		c.add(models.MediaItem{URL: item.Image.URL, Type: models.MediaTypeImage}, mediaRankItemImage)
+	}
+
+	for _, image := range openGraphImages(item.Content) {
+		c.add(image, mediaRankOpenGraph)
+	}
+
+	for _, image := range inline {
+		c.add(models.MediaItem{
+			URL:    image.URL,
+			Type:   models.MediaTypeImage,
+			Width:  image.Width,
+			Height: image.Height,
+		}, mediaRankInline)
+	}
+
+	if item.Content != "" {
+		for _, image := range SanitizeHTML(item.Content, item.Link).Images {
+			c.add(models.MediaItem{
+				URL:    image.URL,
+				Type:   models.MediaTypeImage,
+				Width:  image.Width,
+				Height: image.Height,
+			}, mediaRankInline)
+		}
+	}
+
+	return c.ranked()
+}
+
+// addMediaRSS adds media:content and media:thumbnail elements
+func (c *mediaCollector) addMediaRSS(elements map[string][]ext.Extension) {
+	for _, content := range elements["content"] {
+		item := models.MediaItem{
+			URL:      content.Attrs["url"],
+			Type:     content.Attrs["medium"],
+			MIMEType: content.Attrs["type"],
+			Width:    atoi(content.Attrs["width"]),
+			Height:   atoi(content.Attrs["height"]),
+		}
+		c.add(item, mediaRankContent)
+
+		// Thumbnails can also be nested inside media:content
+		for _, thumbnail := range content.Children["thumbnail"] {
+			c.addThumbnail(thumbnail)
+		}
+	}
+
+	for _, thumbnail := range elements["thumbnail"] {
+		c.addThumbnail(thumbnail)
+	}
+}
+
+func (c *mediaCollector) addThumbnail(thumbnail ext.Extension) {
+	c.add(models.MediaItem{
+		URL:    thumbnail.Attrs["url"],
+		Type:   models.MediaTypeImage,
+		Width:  atoi(thumbnail.Attrs["width"]),
+		Height: atoi(thumbnail.Attrs["height"]),
+	}, mediaRankThumbnail)
+}
+
+// add normalizes a candidate and records it if it is a usable image or video
+func (c *mediaCollector) add(item models.MediaItem, rank int) {
+	item.URL = resolveURL(item.URL, c.base)
+	if item.URL == "" {
+		return
+	}
+
+	item.Type = mediaType(item)
+	if item.Type == "" {
+		return
+	}
+
+	score := rank
+	if item.Width > 0 && item.Width < mediaMinUsefulWidth {
+		score -= mediaSmallPenalty
+	}
+	if area := item.Width * item.Height; area > 0 {
+		// Prefer larger artwork, up to a 20 point bonus at 2MP
+		bonus := area / 100000
+		if bonus > 20 {
+			bonus = 20
+		}
+		score += bonus
+	}
+
+	existing, ok := c.items[item.URL]
+	if !ok {
+		c.items[item.URL] = &rankedMedia{item: item, score: score}
+		c.order = append(c.order, item.URL)
+		return
+	}
+
+	// Merge what each source knew about the same URL
+	if score > existing.score {
+		existing.score = score
+	}
+	if existing.item.MIMEType == "" {
+		existing.item.MIMEType = item.MIMEType
+	}
+	if existing.item.Width == 0 && existing.item.Height == 0 {
+		existing.item.Width, existing.item.Height = item.Width, item.Height
+	}
+}
+
+// ranked returns the collected media, best score first; ties keep discovery order
+func (c *mediaCollector) ranked() []models.MediaItem {
+	candidates := make([]*rankedMedia, 0, len(c.order))
+	for _, key := range c.order {
+		candidates = append(candidates, c.items[key])
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].score > candidates[j].score
+	})
+
+	var media []models.MediaItem
+	for _, candidate := range candidates {
+		media = append(media, candidate.item)
+	}
+	return media
+}
+
+// mediaType works out whether a candidate is an image or a video from its
+// declared medium, its MIME type or, failing both, its file extension
+func mediaType(item models.MediaItem) string {
+	switch strings.ToLower(item.Type) {
+	case models.MediaTypeImage:
+		return models.MediaTypeImage
+	case models.MediaTypeVideo:
+		return models.MediaTypeVideo
+	}
+
+	mimeType := strings.ToLower(item.MIMEType)
+	switch {
+	case strings.HasPrefix(mimeType, "image/"):
+		return models.MediaTypeImage
+	case strings.HasPrefix(mimeType, "video/"):
+		return models.MediaTypeVideo
+	}
+
+	if parsed, err := url.Parse(item.URL); err == nil {
+		extension := strings.ToLower(path.Ext(parsed.Path))
+		switch {
+		case imageExtensions[extension]:
+			return models.MediaTypeImage
+		case videoExtensions[extension]:
+			return models.MediaTypeVideo
+		}
+	}
+
+	return ""
+}
+
+// openGraphImages reads og:image meta tags that some feeds embed in content:encoded
+func openGraphImages(content string) []models.MediaItem {
+	if !strings.Contains(content, "og:image") {
+		return nil
+	}
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(content))
+	if err != nil {
+		return nil
+	}
+
+	return openGraphFromDocument(doc)
+}
+
+// openGraphFromDocument reads og:image and og:image:width/height from a parsed page
+func openGraphFromDocument(doc *goquery.Document) []models.MediaItem {
+	var images []models.MediaItem
+	doc.Find("meta[property]").Each(func(_ int, s *goquery.Selection) {
+		property, _ := s.Attr("property")
+		value, _ := s.Attr("content")
+
+		switch property {
+		case "og:image", "og:image:url":
+			images = append(images, models.MediaItem{URL: value, Type: models.MediaTypeImage})
+		case "og:image:type", "og:image:width", "og:image:height":
+			if len(images) == 0 {
+				return
+			}
+			// Structured properties describe the og:image that precedes them
+			last := &images[len(images)-1]
+			switch property {
+			case "og:image:type":
+				last.MIMEType = value
+			case "og:image:width":
+				last.Width = atoi(value)
+			case "og:image:height":
+				last.Height = atoi(value)
+			}
+		}
+	})
+	return images
+}
+
+func mediaExtensions(extensions ext.Extensions, name string) []ext.Extension {
+	if media, ok := extensions["media"]; ok {
+		return media[name]
+	}
+	return nil
+}
+
+func atoi(value string) int {
+	n, _ := strconv.Atoi(strings.TrimSpace(value))
+	return n
+}
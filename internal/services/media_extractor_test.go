@@ -0,0 +1,71 @@
+package services
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"go-test/internal/models"
+
+	"github.com/mmcdole/gofeed"
+)
+
+const mediaFeed = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:media="http://search.yahoo.com/mrss/" xmlns:content="http://purl.org/rss/1.0/modules/content/">
+<channel>
+<title>Media Feed</title>
+<item>
+<title>Trailer drops</title>
+<link>https://example.com/news/trailer</link>
+<description><![CDATA[<p>Watch it <img src="/pixel.gif" width="1" height="1"></p>]]></description>
+<content:encoded><![CDATA[<meta property="og:image" content="https://example.com/og.jpg"><meta property="og:image:width" content="1200"><meta property="og:image:height" content="630">]]></content:encoded>
+<enclosure url="https://cdn.example.com/trailer.mp4" type="video/mp4" length="1000"/>
+<media:content url="https://cdn.example.com/key-visual.jpg" medium="image" width="1920" height="1080"/>
+<media:thumbnail url="https://cdn.example.com/thumb.jpg" width="150" height="84"/>
+</item>
+</channel>
+</rss>`
+
+func TestExtractMedia(t *testing.T) {
+	feed, err := gofeed.NewParser().Parse(strings.NewReader(mediaFeed))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	item := feed.Items[0]
+	description := SanitizeHTML(item.Description, item.Link)
+	media := extractMedia(item, description.Images)
+
+	expected := []models.MediaItem{
+		{URL: "https://cdn.example.com/key-visual.jpg", Type: models.MediaTypeImage, Width: 1920, Height: 1080},
+		{URL: "https://example.com/og.jpg", Type: models.MediaTypeImage, Width: 1200, Height: 630},
+		{URL: "https://cdn.example.com/trailer.mp4", Type: models.MediaTypeVideo, MIMEType: "video/mp4"},
+		{URL: "https://cdn.example.com/thumb.jpg", Type: models.MediaTypeImage, Width: 150, Height: 84},
+		{URL: "https://example.com/pixel.gif", Type: models.MediaTypeImage, Width: 1, Height: 1},
+	}
+
+	if !reflect.DeepEqual(media, expected) {
+		t.Errorf("extractMedia() =\n%+v\nwant\n%+v", media, expected)
+	}
+}
+
+func TestMediaType(t *testing.T) {
+	tests := []struct {
+		name     string
+		item     models.MediaItem
+		expected string
+	}{
+		{"declared medium", models.MediaItem{URL: "https://a.com/x", Type: "video"}, models.MediaTypeVideo},
+		{"mime type", models.MediaItem{URL: "https://a.com/x", MIMEType: "image/webp"}, models.MediaTypeImage},
+		{"extension", models.MediaItem{URL: "https://a.com/clip.webm?x=1"}, models.MediaTypeVideo},
+		{"audio is ignored", models.MediaItem{URL: "https://a.com/ep.mp3", MIMEType: "audio/mpeg"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := mediaType(tt.item); result != tt.expected {
+				t.Errorf("mediaType(%+v) = %q; want %q", tt.item, result, tt.expected)
+			}
+		})
+	}
+}
@@ -31,6 +31,11 @@ func NewNewsService(cfg *config.Config) *NewsService {
 	}
 }
 
+// SetTransport replaces the transport used for News API requests
+func (s *NewsService) SetTransport(rt http.RoundTripper) {
+	s.client.Transport = rt
+}
+
 // FetchAnimeNews fetches anime-related news articles
 func (s *NewsService) FetchAnimeNews(ctx context.Context) ([]models.Article, error) {
 	// Build URL with parameters
@@ -55,15 +60,16 @@ func (s *NewsService) FetchAnimeNews(ctx context.Context) ([]models.Article, err
 	req.Header.Set("User-Agent", "AnimeNewsAI/1.0")
 	req.Header.Set("Accept", "application/json")
 
-	// Execute request with retry logic
+	// Execute request with retry logic, making at least one attempt
+	attempts := max(s.config.RetryAttempts, 1)
 	var resp *http.Response
-	for attempt := 0; attempt < s.config.RetryAttempts; attempt++ {
+	for attempt := 0; attempt < attempts; attempt++ {
 		resp, err = s.client.Do(req)
 		if err == nil && resp.StatusCode < 500 {
 			break
 		}
 
-		if attempt < s.config.RetryAttempts-1 {
+		if attempt < attempts-1 {
 			time.Sleep(s.config.RateLimitDelay * time.Duration(attempt+1))
 		}
 	}
@@ -114,6 +120,11 @@ func (s *NewsService) FetchAnimeNews(ctx context.Context) ([]models.Article, err
 	for i, article := range newsResp.Articles {
 		publishedAt, _ := time.Parse(time.RFC3339, article.PublishedAt)
 
+		var media []models.MediaItem
+		if imageURL := resolveURL(article.URLToImage, nil); imageURL != "" {
+			media = append(media, models.MediaItem{URL: imageURL, Type: models.MediaTypeImage})
+		}
+
 		articles = append(articles, models.Article{
 			ID:          fmt.Sprintf("news_%d_%d", time.Now().Unix(), i),
 			Title:       article.Title,
@@ -127,6 +138,7 @@ func (s *NewsService) FetchAnimeNews(ctx context.Context) ([]models.Article, err
 				Name: article.Source.Name,
 			},
 			Author: article.Author,
+			Media:  media,
 		})
 	}
 
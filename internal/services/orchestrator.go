@@ -2,28 +2,62 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
+	"path/filepath"
+	"sync"
 	"time"
 
+	"go-test/internal/filelock"
 	"go-test/internal/models"
 )
 
+// cycleLockFile is the instance lock inside DATA_DIR that keeps publishing
+// to one process at a time
+const cycleLockFile = "cycle.lock"
+
 // AnimeApiOrchestrator is the main orchestrator that acts as the autonomous Gemini agent
 type AnimeApiOrchestrator struct {
 	rssFetcher           *RSSFetcher
 	duplicateChecker     *DuplicateChecker
 	sinhalaWriter        *SinhalaWriter
 	socialMediaPublisher *SocialMediaPublisher
+	articleExtractor     *ArticleExtractor
 	logger               *log.Logger
+
+	// articleAnalyzer, when set, rates new articles; those below minRelevance are passed over
+	articleAnalyzer *ArticleAnalyzer
+	minRelevance    float64
+
+	// extraSources run after the RSS feeds every cycle, e.g. the AniList airing schedule
+	extraSources []NewsSource
+
+	// cycleMu keeps polled cycles and pushed articles from publishing at the same time
+	cycleMu sync.Mutex
+
+	// cycleLockPath and cycleLockWait extend cycleMu to other processes,
+	// such as a manual CLI run while cron starts the app
+	cycleLockPath string
+	cycleLockWait time.Duration
+
+	// historyRetention enables archiving history older than it before each cycle
+	historyRetention  time.Duration
+	historyArchiveDir string
+
+	// llmCache, when set, has its counts flushed after each cycle and reported by GetStatus
+	llmCache *LLMCache
 }
 
-// NewAnimeApiOrchestrator creates a new orchestrator instance
+// NewAnimeApiOrchestrator creates a new orchestrator instance.
+// articleExtractor is optional; pass nil to write posts from feed summaries only.
 func NewAnimeApiOrchestrator(
 	rssFetcher *RSSFetcher,
 	duplicateChecker *DuplicateChecker,
 	sinhalaWriter *SinhalaWriter,
 	socialMediaPublisher *SocialMediaPublisher,
+	articleExtractor *ArticleExtractor,
 	logger *log.Logger,
 ) *AnimeApiOrchestrator {
 	return &AnimeApiOrchestrator{
@@ -31,20 +65,111 @@ func NewAnimeApiOrchestrator(
 		duplicateChecker:     duplicateChecker,
 		sinhalaWriter:        sinhalaWriter,
 		socialMediaPublisher: socialMediaPublisher,
+		articleExtractor:     articleExtractor,
 		logger:               logger,
 	}
 }
 
+// HTTPService is implemented by services that call external HTTP APIs, so
+// their transport can be swapped for recording or replaying traffic
+type HTTPService interface {
+	SetTransport(rt http.RoundTripper)
+}
+
+// NewsSource is a source of news items besides the RSS feeds
+type NewsSource interface {
+	Name() string
+	FetchAnimeNews(ctx context.Context) ([]models.AnimeNews, error)
+}
+
+// AddSource registers an additional news source. Its items are considered
+// after the RSS feed items, so they fill cycles without fresh news.
+func (aao *AnimeApiOrchestrator) AddSource(source NewsSource) {
+	aao.extraSources = append(aao.extraSources, source)
+}
+
+// CycleLockPath returns the location of the cycle lock in dataDir
+func CycleLockPath(dataDir string) string {
+	return filepath.Join(dataDir, cycleLockFile)
+}
+
+// SetCycleLock makes cycles and recovery hold the instance lock at path
+// while they run. A run that finds the lock held waits up to
+// wait, then fails with an error matching filelock.ErrLocked.
+func (aao *AnimeApiOrchestrator) SetCycleLock(path string, wait time.Duration) {
+	aao.cycleLockPath = path
+	aao.cycleLockWait = wait
+}
+
+// SetArticleAnalyzer makes cycles ask analyzer how relevant each new
+// article is before writing about it. Articles rated below minRelevance are
+// passed over for the next one. When the analysis fails the article is
+// kept, as the feed's relevance filter already let it through.
+func (aao *AnimeApiOrchestrator) SetArticleAnalyzer(analyzer *ArticleAnalyzer, minRelevance float64) {
+	aao.articleAnalyzer = analyzer
+	aao.minRelevance = minRelevance
+}
+
+// SetHistoryRetention makes every cycle start by archiving history entries
+// older than retention to archiveDir. A retention of 0 keeps everything.
+func (aao *AnimeApiOrchestrator) SetHistoryRetention(retention time.Duration, archiveDir string) {
+	aao.historyRetention = retention
+	aao.historyArchiveDir = archiveDir
+}
+
+// lockCycle takes cycleMu and, if configured, the cycle lock. The returned
+// function releases both.
+func (aao *AnimeApiOrchestrator) lockCycle() (func(), error) {
+	aao.cycleMu.Lock()
+	if aao.cycleLockPath == "" {
+		return aao.cycleMu.Unlock, nil
+	}
+
+	lock, err := filelock.AcquireInstance(aao.cycleLockPath, aao.cycleLockWait)
+	if err != nil {
+		aao.cycleMu.Unlock()
+		return nil, fmt.Errorf("another run is in progress: %w", err)
+	}
+	if lock.Stale != nil {
+		aao.logger.Printf("🧹 Taking over the cycle lock from a run that did not finish (%s)", lock.Stale)
+	}
+
+	return func() {
+		if err := lock.Release(); err != nil {
+			aao.logger.Printf("⚠️  Failed to release cycle lock: %v", err)
+		}
+		aao.cycleMu.Unlock()
+	}, nil
+}
+
 // ExecuteCycle runs one complete cycle of the autonomous agent
 func (aao *AnimeApiOrchestrator) ExecuteCycle(ctx context.Context) error {
+	unlock, err := aao.lockCycle()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	defer aao.flushLLMCache()
+
 	aao.logger.Println("🚀 Anime Api awakening! Time to check for exciting anime news...")
 
+	// With the cycle lock held no other run is publishing, so a reservation
+	// left over is from a cycle that failed or crashed and is settled now
+	if aao.cycleLockPath != "" {
+		if err := aao.recoverPendingLocked(0); err != nil {
+			return err
+		}
+	}
+	aao.maintainHistory()
+
 	// Tool 1: Fetch anime news
 	aao.logger.Println("📡 Tool 1: Fetching latest anime news from RSS feeds...")
-	articles, err := aao.rssFetcher.FetchAnimeNews(ctx)
+	articles, report, err := aao.rssFetcher.FetchAnimeNews(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to fetch anime news: %w", err)
 	}
+	aao.logFetchReport(report)
+	articles = append(articles, aao.fetchExtraSources(ctx)...)
 
 	if len(articles) == 0 {
 		aao.logger.Println("❌ No anime articles found. Sleeping until next cycle...")
@@ -53,17 +178,26 @@ func (aao *AnimeApiOrchestrator) ExecuteCycle(ctx context.Context) error {
 
 	aao.logger.Printf("✅ Found %d potential articles. Now checking for new content...", len(articles))
 
+	return aao.publishFirstNew(ctx, articles)
+}
+
+// publishFirstNew writes and publishes the first article that has not been posted before
+func (aao *AnimeApiOrchestrator) publishFirstNew(ctx context.Context, articles []models.AnimeNews) error {
 	// Tool 2: Find first new article
 	var selectedArticle *models.AnimeNews
 	for i, article := range articles {
 		aao.logger.Printf("🔍 Checking article %d: %s", i+1, article.Title)
 
-		isNew, err := aao.duplicateChecker.CheckIfPostedBefore(article.Link)
+		isNew, err := aao.duplicateChecker.IsNewArticle(article)
 		if err != nil {
 			aao.logger.Printf("⚠️  Error checking duplicate for article %d: %v", i+1, err)
 			continue
 		}
 
+		if isNew && !aao.passesAnalysis(ctx, article) {
+			continue
+		}
+
 		if isNew {
 			selectedArticle = &article
 			aao.logger.Printf("🎉 Found NEW article: %s", article.Title)
@@ -78,34 +212,88 @@ func (aao *AnimeApiOrchestrator) ExecuteCycle(ctx context.Context) error {
 		return nil
 	}
 
+	// Optional: pull the full article so the AI has more than a one-line summary
+	if aao.articleExtractor != nil {
+		aao.logger.Println("📰 Extracting full article content...")
+		if err := aao.articleExtractor.Enrich(ctx, selectedArticle); err != nil {
+			aao.logger.Printf("⚠️  Article extraction failed, using feed summary: %v", err)
+		} else {
+			aao.logger.Printf("✅ Extracted %d characters of article content", len([]rune(selectedArticle.Content)))
+		}
+	}
+
+	articleText := selectedArticle.Summary
+	if selectedArticle.Content != "" {
+		articleText = selectedArticle.Content
+	}
+
 	// Tool 3: Write Sinhala post
 	aao.logger.Println("✍️  Tool 3: Writing exciting Sinhala post using AI...")
-	sinhalaText, err := aao.sinhalaWriter.WriteAnimePostInMyStyle(
-		ctx,
-		selectedArticle.Title,
-		selectedArticle.Summary,
-		selectedArticle.Link,
-	)
+	post, err := aao.sinhalaWriter.WritePost(ctx, PostPrompt{
+		Title:   selectedArticle.Title,
+		Summary: articleText,
+		Link:    selectedArticle.Link,
+		Source:  selectedArticle.Source,
+	})
+	var rejected *PostRejectedError
+	if errors.As(err, &rejected) {
+		aao.logger.Printf("🚫 No acceptable post after %d drafts:", rejected.Attempts)
+		for _, rejection := range rejected.Rejections {
+			aao.logger.Printf("   • %s", rejection)
+		}
+	}
 	if err != nil {
 		return fmt.Errorf("failed to write Sinhala post: %w", err)
 	}
+	if len(post.Rejections) > 0 {
+		aao.logger.Printf("♻️  Accepted draft %d after %d rejected", post.Attempts, len(post.Rejections))
+	}
+	sinhalaText := post.Text
 
 	aao.logger.Println("📝 AI has crafted the perfect post! Here's what it wrote:")
 	aao.logger.Printf("---\n%s\n---", sinhalaText)
 
+	// Reserve the article first, so a crash after publishing cannot lead to a second post
+	record, err := aao.duplicateChecker.Reserve(*selectedArticle, PublishDetails{
+		Model:      aao.sinhalaWriter.Model(),
+		Prompt:     post.Prompt,
+		PostText:   sinhalaText,
+		Rejections: post.Rejections,
+	})
+	if errors.Is(err, ErrAlreadyPublished) {
+		aao.logger.Printf("⏭️  Another run has already claimed: %s", selectedArticle.Title)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to reserve article: %w", err)
+	}
+
 	// Tool 4: Publish post
 	aao.logger.Println("📢 Tool 4: Publishing to social media...")
-	publishResult, err := aao.socialMediaPublisher.PublishPost(ctx, sinhalaText)
+	receipt, err := aao.socialMediaPublisher.PublishPost(ctx, sinhalaText)
 	if err != nil {
+		// Only a post known not to have gone out may be retried; otherwise
+		// the reservation is held until an operator has checked the channel
+		if !errors.Is(err, ErrPostNotSent) {
+			aao.logger.Printf("⏸️  It is unknown whether %s was posted; holding it until resolved with \"history resolve\"", selectedArticle.Title)
+			return fmt.Errorf("failed to publish post: %w", err)
+		}
+		if releaseErr := aao.duplicateChecker.Release(record); releaseErr != nil {
+			aao.logger.Printf("⚠️  Failed to release reservation: %v", releaseErr)
+		}
 		return fmt.Errorf("failed to publish post: %w", err)
 	}
 
-	aao.logger.Printf("🎊 %s", publishResult)
+	aao.logger.Printf("🎊 Successfully posted to %s (message %s)!", receipt.Channel, receipt.MessageID)
+
+	// The message ID tells recovery the post went out, should the commit below not happen
+	if err := aao.duplicateChecker.MarkSent(&record, receipt); err != nil {
+		aao.logger.Printf("⚠️  Failed to record message ID on reservation: %v", err)
+	}
 
 	// Tool 5: Log as published
 	aao.logger.Println("📋 Tool 5: Logging article as published...")
-	err = aao.duplicateChecker.LogAsPublished(selectedArticle.Link, selectedArticle.Title)
-	if err != nil {
+	if err := aao.duplicateChecker.Commit(record); err != nil {
 		return fmt.Errorf("failed to log published article: %w", err)
 	}
 
@@ -122,6 +310,105 @@ func (aao *AnimeApiOrchestrator) ExecuteCycle(ctx context.Context) error {
 	return nil
 }
 
+// passesAnalysis reports whether the article analyzer, if any, rates
+// article relevant enough to post
+func (aao *AnimeApiOrchestrator) passesAnalysis(ctx context.Context, article models.AnimeNews) bool {
+	if aao.articleAnalyzer == nil {
+		return true
+	}
+
+	analysis, err := aao.articleAnalyzer.AnalyzeArticle(ctx, models.Article{
+		ID:          article.Link,
+		Title:       article.Title,
+		Description: article.Summary,
+		URL:         article.Link,
+		PublishedAt: article.PublishedAt,
+		Source:      models.Source{Name: article.Source},
+	})
+	if err != nil {
+		aao.logger.Printf("⚠️  Article analysis failed, keeping the article: %v", err)
+		return true
+	}
+
+	if analysis.Relevance < aao.minRelevance {
+		aao.logger.Printf("🧠 Passing over %s: analysis rates its relevance %.2f, below %.2f", article.Title, analysis.Relevance, aao.minRelevance)
+		return false
+	}
+
+	aao.logger.Printf("🧠 Analysis rates relevance %.2f (%s): %s", analysis.Relevance, analysis.Sentiment, analysis.Summary)
+	return true
+}
+
+// RecoverPendingPublishes settles publishes that a previous run reserved
+// but never committed, e.g. because it crashed. It should run once at
+// startup, before the first cycle. Every reservation is looked at when a
+// cycle lock is set, as holding it rules out a run still publishing;
+// without one, reservations younger than staleAfter are left alone.
+// Publishes that were never confirmed are held and logged for an operator.
+func (aao *AnimeApiOrchestrator) RecoverPendingPublishes(staleAfter time.Duration) error {
+	unlock, err := aao.lockCycle()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if aao.cycleLockPath != "" {
+		staleAfter = 0
+	}
+	return aao.recoverPendingLocked(staleAfter)
+}
+
+// recoverPendingLocked settles reservations older than staleAfter; the
+// caller holds the cycle lock
+func (aao *AnimeApiOrchestrator) recoverPendingLocked(staleAfter time.Duration) error {
+	committed, held, err := aao.duplicateChecker.RecoverReservations(staleAfter)
+	for _, reservation := range committed {
+		aao.logger.Printf("🩹 Recovered publish of %s (message %s was sent, now logged)", reservation.Article.Title, reservation.Article.MessageID)
+	}
+	for _, reservation := range held {
+		aao.logger.Printf("⏸️  Publish of %s from %s was never confirmed; check the channel, then run \"history resolve -sent|-retry %s\"",
+			reservation.Article.Title, reservation.ReservedAt.Format("2006-01-02 15:04"), reservation.Article.Link)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to recover pending publishes: %w", err)
+	}
+	return nil
+}
+
+// maintainHistory archives expired history entries. Failures only cost
+// disk space, so they are logged and the cycle goes on.
+func (aao *AnimeApiOrchestrator) maintainHistory() {
+	if aao.historyRetention <= 0 {
+		return
+	}
+
+	archived, err := aao.duplicateChecker.CompactHistory(aao.historyRetention, aao.historyArchiveDir)
+	if err != nil {
+		aao.logger.Printf("⚠️  History maintenance failed: %v", err)
+		return
+	}
+	if archived > 0 {
+		aao.logger.Printf("🗜️  Archived %d history entries older than %.0f days to %s", archived, aao.historyRetention.Hours()/24, aao.historyArchiveDir)
+	}
+}
+
+// SetLLMCache flushes the counts of the model response cache after each
+// cycle and reports them in the status
+func (aao *AnimeApiOrchestrator) SetLLMCache(cache *LLMCache) {
+	aao.llmCache = cache
+}
+
+// flushLLMCache saves the cache counts of the cycle. Counting is best
+// effort, so a failure is only logged.
+func (aao *AnimeApiOrchestrator) flushLLMCache() {
+	if aao.llmCache == nil {
+		return
+	}
+	if err := aao.llmCache.Flush(); err != nil {
+		aao.logger.Printf("⚠️  %v", err)
+	}
+}
+
 // GetStatus returns the current status of the orchestrator
 func (aao *AnimeApiOrchestrator) GetStatus(ctx context.Context) (*models.OrchestratorStatus, error) {
 	publishedCount, err := aao.duplicateChecker.GetPublishedCount()
@@ -145,14 +432,100 @@ func (aao *AnimeApiOrchestrator) GetStatus(ctx context.Context) (*models.Orchest
 		Error:  aao.getErrorString(socialMediaErr),
 	})
 
+	// Report feed health from the circuit breaker
+	now := time.Now()
+	for _, health := range aao.rssFetcher.FeedHealth() {
+		connectionStatus = append(connectionStatus, models.ServiceStatus{
+			Name:   "Feed: " + health.Source,
+			Status: aao.getFeedStatusString(health, now),
+			Error:  health.LastError,
+		})
+	}
+
+	var cacheStats *models.CacheStats
+	if aao.llmCache != nil {
+		stats, err := aao.llmCache.Stats()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get LLM cache stats: %w", err)
+		}
+		cacheStats = &stats
+	}
+
 	return &models.OrchestratorStatus{
 		LastRun:         time.Now(),
 		PublishedCount:  publishedCount,
 		RecentArticles:  recentArticles,
 		ServiceStatuses: connectionStatus,
+		LLMCache:        cacheStats,
 	}, nil
 }
 
+// fetchExtraSources collects items from the additional sources; a failing
+// source is logged and skipped like a failing feed
+func (aao *AnimeApiOrchestrator) fetchExtraSources(ctx context.Context) []models.AnimeNews {
+	var articles []models.AnimeNews
+	for _, source := range aao.extraSources {
+		items, err := source.FetchAnimeNews(ctx)
+		if err != nil {
+			aao.logger.Printf("⚠️  %s failed: %v", source.Name(), err)
+			continue
+		}
+		aao.logger.Printf("📺 %s: %d items", source.Name(), len(items))
+		articles = append(articles, items...)
+	}
+	return articles
+}
+
+// logFetchReport logs the per-feed outcome of a fetch run
+func (aao *AnimeApiOrchestrator) logFetchReport(report *FetchReport) {
+	if report == nil {
+		return
+	}
+
+	for _, result := range report.Results {
+		if result.Pushed {
+			aao.logger.Printf("📬 %s: delivered by WebSub, not polled", result.Source)
+		} else if result.Skipped {
+			aao.logger.Printf("⏸️  %s skipped: circuit breaker is open", result.Source)
+		} else if result.Err != nil {
+			aao.logger.Printf("⚠️  %s failed after %s: %v", result.Source, result.Latency.Round(time.Millisecond), result.Err)
+		} else if result.NotModified {
+			aao.logger.Printf("📰 %s: not modified, reused %d cached items", result.Source, result.Items)
+		} else {
+			aao.logger.Printf("📰 %s: %d items in %s (%d filtered out)", result.Source, result.Items, result.Latency.Round(time.Millisecond), len(result.Rejected()))
+		}
+	}
+
+	if report.Pushed > 0 {
+		aao.logger.Printf("📬 Added %d items delivered by WebSub", report.Pushed)
+	}
+
+	if report.TooOld > 0 {
+		aao.logger.Printf("🗓️  Dropped %d items older than the recency window", report.TooOld)
+	}
+
+	if report.Clustered > 0 {
+		aao.logger.Printf("🧩 Merged %d duplicate items from other feeds into their stories", report.Clustered)
+	}
+
+	if failed := report.Failed(); len(failed) > 0 {
+		aao.logger.Printf("⚠️  %d of %d feeds failed this cycle", len(failed), len(report.Results))
+	}
+}
+
+// logRelevanceDecisions explains why each item passed or failed the relevance filter
+func (aao *AnimeApiOrchestrator) logRelevanceDecisions(report *FetchReport) {
+	if report == nil {
+		return
+	}
+
+	for _, result := range report.Results {
+		for _, decision := range result.Decisions {
+			aao.logger.Printf("⚖️  [%s] %s — %s", result.Source, decision.Title, decision.Result.Explain())
+		}
+	}
+}
+
 func (aao *AnimeApiOrchestrator) getStatusString(isHealthy bool) string {
 	if isHealthy {
 		return "✅ Healthy"
@@ -160,6 +533,19 @@ func (aao *AnimeApiOrchestrator) getStatusString(isHealthy bool) string {
 	return "❌ Error"
 }
 
+func (aao *AnimeApiOrchestrator) getFeedStatusString(health FeedHealth, now time.Time) string {
+	switch {
+	case health.IsOpen(now):
+		return fmt.Sprintf("⏸️  Paused until %s (%d failures)", health.OpenUntil.Format("2006-01-02 15:04:05"), health.ConsecutiveFailures)
+	case health.ConsecutiveFailures > 0:
+		return fmt.Sprintf("⚠️  Failing (%d in a row)", health.ConsecutiveFailures)
+	case health.LastSuccess.IsZero():
+		return "❔ Not fetched yet"
+	default:
+		return aao.getStatusString(true)
+	}
+}
+
 func (aao *AnimeApiOrchestrator) getErrorString(err error) string {
 	if err != nil {
 		return err.Error()
@@ -173,12 +559,27 @@ func (aao *AnimeApiOrchestrator) TestAllTools(ctx context.Context) error {
 
 	// Test RSS Fetcher
 	aao.logger.Println("Testing RSS Fetcher...")
-	articles, err := aao.rssFetcher.FetchAnimeNews(ctx)
+	articles, report, err := aao.rssFetcher.FetchAnimeNews(ctx)
 	if err != nil {
 		return fmt.Errorf("RSS Fetcher test failed: %w", err)
 	}
+	aao.logFetchReport(report)
+	aao.logRelevanceDecisions(report)
 	aao.logger.Printf("✅ RSS Fetcher: Found %d articles", len(articles))
 
+	// Test additional sources
+	for _, source := range aao.extraSources {
+		aao.logger.Printf("Testing %s...", source.Name())
+		items, err := source.FetchAnimeNews(ctx)
+		if err != nil {
+			return fmt.Errorf("%s test failed: %w", source.Name(), err)
+		}
+		for _, item := range items {
+			aao.logger.Printf("   • %s", item.Title)
+		}
+		aao.logger.Printf("✅ %s: Found %d items", source.Name(), len(items))
+	}
+
 	// Test Duplicate Checker
 	aao.logger.Println("Testing Duplicate Checker...")
 	count, err := aao.duplicateChecker.GetPublishedCount()
@@ -0,0 +1,258 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go-test/internal/config"
+	"go-test/internal/filelock"
+	"go-test/internal/httpreplay"
+	"go-test/internal/models"
+	"go-test/internal/prompts"
+)
+
+// TestOrchestrator_ReplayedCycle runs a full fetch, write and publish cycle
+// against recorded feed, Gemini and Telegram responses
+func TestOrchestrator_ReplayedCycle(t *testing.T) {
+	transport, err := httpreplay.New(httpreplay.ModeReplay, filepath.Join("testdata", "cassettes", "pipeline.json"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fetcher := &RSSFetcher{
+		feeds:         []config.FeedConfig{{URL: "https://feeds.example.com/anime.xml", Name: "Example Anime"}},
+		maxConcurrent: 1,
+		httpClient:    &http.Client{},
+	}
+	llm, err := NewLLMProvider(&config.Config{
+		LLMProvider:  "gemini",
+		LLMBaseURL:   "https://generativelanguage.googleapis.com/v1beta",
+		GeminiAPIKey: "test-api-key",
+	}, "gemini-2.5-pro")
+	if err != nil {
+		t.Fatal(err)
+	}
+	library, err := prompts.Load(filepath.Join("..", "..", "configs", "prompts"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer, err := NewSinhalaWriter(llm, library, "casual_youth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	publisher := NewSocialMediaPublisher("123456:test-bot-token", "@anime")
+	for _, service := range []HTTPService{fetcher, writer, publisher} {
+		service.SetTransport(transport)
+	}
+
+	store, err := NewFilePublishedStore(filepath.Join(t.TempDir(), "published_articles.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checker := NewDuplicateChecker(store)
+	orchestrator := NewAnimeApiOrchestrator(fetcher, checker, writer, publisher, nil, log.New(io.Discard, "", 0))
+
+	if err := orchestrator.ExecuteCycle(context.Background()); err != nil {
+		t.Fatalf("ExecuteCycle() error = %v", err)
+	}
+
+	if unused := transport.Unused(); len(unused) != 0 {
+		t.Errorf("cycle skipped %d recorded requests, first %s %s", len(unused), unused[0].Request.Method, unused[0].Request.URL)
+	}
+
+	isNew, err := checker.CheckIfPostedBefore("https://news.example.com/frieren-season-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if isNew {
+		t.Error("published article was not logged")
+	}
+
+	recent, err := checker.GetRecentPublished(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recent) != 1 {
+		t.Fatalf("history has %d entries; want 1", len(recent))
+	}
+	entry := recent[0]
+	if entry.Channel != "telegram:@anime" || entry.MessageID != "42" || entry.Model != "gemini-2.5-pro" || !strings.Contains(entry.PostText, "Frieren season 2") {
+		t.Errorf("history entry = %+v; want channel, message ID, model and post text recorded", entry)
+	}
+	if entry.Prompt != "personas/casual_youth@casual_youth-2" {
+		t.Errorf("history entry prompt = %q; want the persona template version", entry.Prompt)
+	}
+}
+
+// TestOrchestrator_HoldsUnconfirmedPublish checks that a post whose outcome
+// is unknown keeps its reservation instead of being released for a retry
+func TestOrchestrator_HoldsUnconfirmedPublish(t *testing.T) {
+	transport, err := httpreplay.New(httpreplay.ModeReplay, filepath.Join("testdata", "cassettes", "pipeline.json"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fetcher := &RSSFetcher{
+		feeds:         []config.FeedConfig{{URL: "https://feeds.example.com/anime.xml", Name: "Example Anime"}},
+		maxConcurrent: 1,
+		httpClient:    &http.Client{},
+	}
+	llm, err := NewLLMProvider(&config.Config{
+		LLMProvider:  "gemini",
+		LLMBaseURL:   "https://generativelanguage.googleapis.com/v1beta",
+		GeminiAPIKey: "test-api-key",
+	}, "gemini-2.5-pro")
+	if err != nil {
+		t.Fatal(err)
+	}
+	library, err := prompts.Load(filepath.Join("..", "..", "configs", "prompts"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer, err := NewSinhalaWriter(llm, library, "casual_youth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fetcher.SetTransport(transport)
+	writer.SetTransport(transport)
+
+	// Telegram never answers, so the post may or may not have gone out
+	publisher := NewSocialMediaPublisher("123456:test-bot-token", "@anime")
+	publisher.SetTransport(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return nil, context.DeadlineExceeded
+	}))
+
+	store, err := NewFilePublishedStore(filepath.Join(t.TempDir(), "published_articles.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checker := NewDuplicateChecker(store)
+	orchestrator := NewAnimeApiOrchestrator(fetcher, checker, writer, publisher, nil, log.New(io.Discard, "", 0))
+
+	if err := orchestrator.ExecuteCycle(context.Background()); err == nil {
+		t.Fatal("ExecuteCycle() error = nil; want the publish failure")
+	}
+
+	pending, err := store.Pending()
+	if err != nil || len(pending) != 1 || pending[0].Article.Link != "https://news.example.com/frieren-season-2" {
+		t.Fatalf("Pending() = %+v, %v; want the unconfirmed publish held", pending, err)
+	}
+	if isNew, _ := checker.CheckIfPostedBefore("https://news.example.com/frieren-season-2"); isNew {
+		t.Error("unconfirmed article can be posted again")
+	}
+
+	// Recovery keeps holding it until an operator resolves it
+	if err := orchestrator.RecoverPendingPublishes(0); err != nil {
+		t.Fatal(err)
+	}
+	if pending, _ := store.Pending(); len(pending) != 1 {
+		t.Errorf("Pending() after recovery = %+v; want the publish still held", pending)
+	}
+}
+
+func TestOrchestrator_CycleLockExcludesOtherRuns(t *testing.T) {
+	path := CycleLockPath(t.TempDir())
+
+	// The held lock stands in for a second process that is mid-cycle
+	held, err := filelock.AcquireInstance(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	orchestrator := &AnimeApiOrchestrator{logger: log.New(io.Discard, "", 0)}
+	orchestrator.SetCycleLock(path, 0)
+
+	if err := orchestrator.ExecuteCycle(context.Background()); !errors.Is(err, filelock.ErrLocked) {
+		t.Fatalf("ExecuteCycle() with the lock held = %v; want filelock.ErrLocked", err)
+	}
+	if err := orchestrator.RecoverPendingPublishes(time.Minute); !errors.Is(err, filelock.ErrLocked) {
+		t.Fatalf("RecoverPendingPublishes() with the lock held = %v; want filelock.ErrLocked", err)
+	}
+
+	held.Release()
+
+	store, err := NewFilePublishedStore(filepath.Join(t.TempDir(), publishedLogFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	orchestrator.duplicateChecker = NewDuplicateChecker(store)
+
+	// Holding the lock proves no run is mid-publish, so even a fresh
+	// reservation is settled
+	record, err := orchestrator.duplicateChecker.Reserve(models.AnimeNews{Title: "Crashed", Link: "https://example.com/crashed"}, PublishDetails{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := orchestrator.duplicateChecker.MarkSent(&record, &PublishReceipt{Channel: "telegram:@anime", MessageID: "7"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := orchestrator.RecoverPendingPublishes(time.Hour); err != nil {
+		t.Fatalf("RecoverPendingPublishes() after release = %v", err)
+	}
+	if pending, err := store.Pending(); err != nil || len(pending) != 0 {
+		t.Errorf("Pending() = %+v, %v; want the fresh reservation committed", pending, err)
+	}
+}
+
+func TestOrchestrator_PassesAnalysis(t *testing.T) {
+	// The model rates articles by the word in their title
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request openAIChatRequest
+		json.NewDecoder(r.Body).Decode(&request)
+		prompt := request.Messages[0].Content
+
+		if strings.Contains(prompt, "Broken") {
+			http.Error(w, "overloaded", http.StatusServiceUnavailable)
+			return
+		}
+		relevance := 0.9
+		if strings.Contains(prompt, "Gossip") {
+			relevance = 0.2
+		}
+		content := fmt.Sprintf(`{"summary":"An article.","key_points":["One"],"sentiment":"NEUTRAL","relevance":%g}`, relevance)
+		json.NewEncoder(w).Encode(openAIChatResponse{Choices: []struct {
+			Message openAIMessage `json:"message"`
+		}{{Message: openAIMessage{Role: "assistant", Content: content}}}})
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{LLMProvider: "llamacpp", LLMBaseURL: server.URL, LLMTimeout: 5 * time.Second, RetryAttempts: 1}
+	llm, err := NewLLMProvider(cfg, "test-model")
+	if err != nil {
+		t.Fatal(err)
+	}
+	library, err := prompts.Load(filepath.Join("..", "..", "configs", "prompts"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	orchestrator := &AnimeApiOrchestrator{logger: log.New(io.Discard, "", 0)}
+	if !orchestrator.passesAnalysis(context.Background(), models.AnimeNews{Title: "Gossip"}) {
+		t.Error("passesAnalysis() without an analyzer = false; want every article kept")
+	}
+	orchestrator.SetArticleAnalyzer(NewArticleAnalyzer(cfg, llm, library), 0.5)
+
+	tests := []struct {
+		title string
+		want  bool
+	}{
+		{"Frieren season 2 announced", true},
+		{"Voice actor Gossip roundup", false},
+		{"Broken analysis keeps the article", true},
+	}
+	for _, tt := range tests {
+		if got := orchestrator.passesAnalysis(context.Background(), models.AnimeNews{Title: tt.title, Link: "https://example.com/a"}); got != tt.want {
+			t.Errorf("passesAnalysis(%q) = %v; want %v", tt.title, got, tt.want)
+		}
+	}
+}
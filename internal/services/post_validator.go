@@ -0,0 +1,150 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"go-test/internal/config"
+)
+
+// defaultBannedPhrases are the preambles and disclaimers models like to put
+// around a post. POST_BANNED_PHRASES adds to them.
+var defaultBannedPhrases = []string{
+	"here is your post",
+	"here's your post",
+	"here is the post",
+	"here's the post",
+	"here is a casual post",
+	"here's a casual post",
+	"as an ai",
+	"i hope this helps",
+}
+
+// markdownHeadingPattern matches a line that starts with a markdown heading
+var markdownHeadingPattern = regexp.MustCompile(`(?m)^\s*#{1,6}\s`)
+
+// PostValidator checks generated posts before they are published, so drafts
+// in the wrong language or format can be sent back to the model
+type PostValidator struct {
+	minSinhalaRatio float64
+	minLength       int
+	maxLength       int
+	requireLink     bool
+	requireQuestion bool
+	bannedPhrases   []string
+}
+
+// NewPostValidator creates a validator with the POST_* settings
+func NewPostValidator(cfg *config.Config) *PostValidator {
+	banned := make([]string, 0, len(defaultBannedPhrases)+len(cfg.PostBannedPhrases))
+	for _, phrases := range [][]string{defaultBannedPhrases, cfg.PostBannedPhrases} {
+		for _, phrase := range phrases {
+			if phrase = strings.ToLower(strings.TrimSpace(phrase)); phrase != "" {
+				banned = append(banned, phrase)
+			}
+		}
+	}
+
+	return &PostValidator{
+		minSinhalaRatio: cfg.PostMinSinhalaRatio,
+		minLength:       cfg.PostMinLength,
+		maxLength:       cfg.PostMaxLength,
+		requireLink:     cfg.PostRequireLink,
+		requireQuestion: cfg.PostRequireQuestion,
+		bannedPhrases:   banned,
+	}
+}
+
+// Validate returns the problems with text as a post about the article at
+// link, phrased so they can be handed back to the model. An empty result
+// means the post is fine.
+func (pv *PostValidator) Validate(text, link string) []string {
+	var problems []string
+
+	length := len([]rune(text))
+	if length < pv.minLength {
+		problems = append(problems, fmt.Sprintf("the post is too short (%d characters, at least %d needed)", length, pv.minLength))
+	}
+	if pv.maxLength > 0 && length > pv.maxLength {
+		problems = append(problems, fmt.Sprintf("the post is too long (%d characters, at most %d allowed)", length, pv.maxLength))
+	}
+
+	if ratio := sinhalaRatio(text); ratio < pv.minSinhalaRatio {
+		problems = append(problems, fmt.Sprintf("only %.0f%% of the letters are Sinhala, at least %.0f%% needed", ratio*100, pv.minSinhalaRatio*100))
+	}
+
+	if markdownHeadingPattern.MatchString(text) || strings.Contains(text, "**") {
+		problems = append(problems, "the post uses markdown formatting, but Telegram shows it as plain text")
+	}
+
+	lower := strings.ToLower(text)
+	for _, phrase := range pv.bannedPhrases {
+		if strings.Contains(lower, phrase) {
+			problems = append(problems, fmt.Sprintf("the post contains the phrase %q; write only the post itself", phrase))
+		}
+	}
+
+	if pv.requireLink && link != "" && !strings.Contains(text, link) {
+		problems = append(problems, fmt.Sprintf("the post does not include the source link %s", link))
+	}
+
+	if pv.requireQuestion && !endsWithQuestion(text) {
+		problems = append(problems, "the post does not end with a question to the readers")
+	}
+
+	return problems
+}
+
+// sinhalaRatio returns the share of letters in text that are Sinhala
+// script. Links are left out, as they are never Sinhala.
+func sinhalaRatio(text string) float64 {
+	var letters, sinhala int
+	for _, word := range strings.Fields(text) {
+		if strings.Contains(word, "://") {
+			continue
+		}
+		for _, r := range word {
+			if !unicode.IsLetter(r) && !unicode.Is(unicode.Mn, r) && !unicode.Is(unicode.Mc, r) {
+				continue
+			}
+			letters++
+			if unicode.Is(unicode.Sinhala, r) {
+				sinhala++
+			}
+		}
+	}
+	if letters == 0 {
+		return 0
+	}
+	return float64(sinhala) / float64(letters)
+}
+
+// endsWithQuestion reports whether the last line with words ends with a
+// question mark. Links, hashtags, trailing emojis and link labels such as
+// "Read more:" are ignored.
+func endsWithQuestion(text string) bool {
+	lines := strings.Split(text, "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		var words []string
+		hasLink := false
+		for _, word := range strings.Fields(lines[i]) {
+			switch {
+			case strings.Contains(word, "://"):
+				hasLink = true
+			case !strings.HasPrefix(word, "#"):
+				words = append(words, word)
+			}
+		}
+
+		line := strings.TrimRightFunc(strings.Join(words, " "), func(r rune) bool {
+			return r != '?' && r != ':' && !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.Is(unicode.Sinhala, r)
+		})
+		if line == "" || (hasLink && strings.HasSuffix(line, ":")) {
+			continue
+		}
+		return strings.HasSuffix(line, "?")
+	}
+	return false
+}
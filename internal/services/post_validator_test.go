@@ -0,0 +1,155 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go-test/internal/config"
+	"go-test/internal/prompts"
+)
+
+const (
+	testPostLink = "https://news.example.com/frieren-season-2"
+	testGoodPost = "අයියේ Frieren season 2 එක January එනවා 🔥 trailer එකත් ආවා, animation එක නම් පට්ට ලස්සනයි!\n" +
+		"ඔයාලා බලන්න ready නේද? 😍\n" + testPostLink
+)
+
+func testPostValidator() *PostValidator {
+	return NewPostValidator(&config.Config{
+		PostMinSinhalaRatio: 0.3,
+		PostMinLength:       60,
+		PostMaxLength:       300,
+		PostRequireLink:     true,
+		PostRequireQuestion: true,
+		PostBannedPhrases:   []string{"Click here"},
+	})
+}
+
+func TestPostValidator_Validate(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		// want is a substring of the only expected problem; empty means valid
+		want string
+	}{
+		{"valid", testGoodPost, ""},
+		{"hashtags after the link", testGoodPost + "\n#anime #Frieren", ""},
+		{"question before emoji", strings.Replace(testGoodPost, "නේද? 😍", "නේද?! 😍🔥", 1), ""},
+		{"english", "Frieren season 2 is coming in January and the trailer looks amazing. Are you ready?\n" + testPostLink, "Sinhala"},
+		{"too short", "බලමුද? " + testPostLink, "too short"},
+		{"too long", strings.Repeat("අයියේ ", 60) + testGoodPost, "too long"},
+		{"markdown heading", "## Frieren\n" + testGoodPost, "markdown"},
+		{"preamble", "Here is your post:\n" + testGoodPost, "here is your post"},
+		{"configured phrase", strings.Replace(testGoodPost, "trailer", "click here trailer", 1), "click here"},
+		{"link label", strings.Replace(testGoodPost, testPostLink, "🔗 Read more: "+testPostLink, 1), ""},
+		{"missing link", strings.TrimSuffix(testGoodPost, testPostLink), "source link"},
+		{"no question", strings.Replace(testGoodPost, "නේද? 😍", "නේද 😍", 1), "question"},
+	}
+
+	validator := testPostValidator()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problems := validator.Validate(tt.text, testPostLink)
+			if tt.want == "" {
+				if len(problems) != 0 {
+					t.Errorf("Validate() = %q; want no problems", problems)
+				}
+				return
+			}
+			if len(problems) != 1 || !strings.Contains(problems[0], tt.want) {
+				t.Errorf("Validate() = %q; want one problem about %q", problems, tt.want)
+			}
+		})
+	}
+}
+
+func TestSinhalaWriter_RegeneratesRejectedDrafts(t *testing.T) {
+	tests := []struct {
+		name        string
+		drafts      []string
+		maxAttempts int
+		wantErr     bool
+	}{
+		{"first draft passes", []string{testGoodPost}, 3, false},
+		{"second draft passes", []string{"Here is your post:\n" + testGoodPost, testGoodPost}, 3, false},
+		{"attempts run out", []string{"Frieren season 2 is coming!", "Frieren season 2 is coming!"}, 2, true},
+	}
+
+	library, err := prompts.Load(filepath.Join("..", "..", "configs", "prompts"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var request struct {
+					Messages []struct {
+						Content string `json:"content"`
+					} `json:"messages"`
+				}
+				json.NewDecoder(r.Body).Decode(&request)
+				requests = append(requests, request.Messages[0].Content)
+				if len(requests) > len(tt.drafts) {
+					http.Error(w, "too many requests", http.StatusTooManyRequests)
+					return
+				}
+
+				draft := tt.drafts[len(requests)-1]
+				json.NewEncoder(w).Encode(map[string]interface{}{
+					"choices": []interface{}{map[string]interface{}{"message": map[string]string{"content": draft}}},
+				})
+			}))
+			defer server.Close()
+
+			llm, err := NewLLMProvider(&config.Config{
+				LLMProvider: "openai",
+				LLMBaseURL:  server.URL,
+				LLMAPIKey:   "test-key",
+				LLMTimeout:  5 * time.Second,
+			}, "test-model")
+			if err != nil {
+				t.Fatal(err)
+			}
+			writer, err := NewSinhalaWriter(llm, library, "casual_youth")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := writer.SetValidator(testPostValidator(), tt.maxAttempts); err != nil {
+				t.Fatal(err)
+			}
+
+			post, err := writer.WritePost(context.Background(), PostPrompt{Title: "Frieren season 2", Link: testPostLink})
+			if len(requests) != len(tt.drafts) {
+				t.Errorf("model was asked %d times; want %d", len(requests), len(tt.drafts))
+			}
+			for i, request := range requests[1:] {
+				if !strings.Contains(request, tt.drafts[i]) || !strings.Contains(request, "rejected") {
+					t.Errorf("request %d does not send back the rejected draft:\n%s", i+2, request)
+				}
+			}
+
+			if tt.wantErr {
+				var rejected *PostRejectedError
+				if !errors.As(err, &rejected) || rejected.Attempts != tt.maxAttempts || len(rejected.Rejections) != tt.maxAttempts {
+					t.Fatalf("WritePost() error = %v; want a PostRejectedError after %d attempts", err, tt.maxAttempts)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("WritePost() error = %v", err)
+			}
+			if post.Text != testGoodPost || post.Attempts != len(tt.drafts) || len(post.Rejections) != len(tt.drafts)-1 {
+				t.Errorf("WritePost() = %+v", post)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package services
+
+import (
+	"compress/gzip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"go-test/internal/models"
+)
+
+// publishedArchiveDir is the directory inside DATA_DIR that holds history
+// entries removed from the live history by compaction
+const publishedArchiveDir = "archive"
+
+// PublishedArchiveDir returns the history archive directory in dataDir
+func PublishedArchiveDir(dataDir string) string {
+	return filepath.Join(dataDir, publishedArchiveDir)
+}
+
+// PublishedArchiver returns an archive function for PublishedStore.Compact
+// that writes articles to gzip-compressed JSON Lines files in dir, one per
+// month of publication, e.g. published-2025-09.jsonl.gz. Each call appends
+// a new gzip member, which gzip readers (including zcat) read as one stream.
+func PublishedArchiver(dir string) func([]models.PublishedArticle) error {
+	return func(articles []models.PublishedArticle) error {
+		byMonth := make(map[string][]models.PublishedArticle)
+		for _, article := range articles {
+			month := article.PublishedAt.UTC().Format("2006-01")
+			byMonth[month] = append(byMonth[month], article)
+		}
+
+		months := make([]string, 0, len(byMonth))
+		for month := range byMonth {
+			months = append(months, month)
+		}
+		sort.Strings(months)
+
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create history archive directory: %w", err)
+		}
+
+		for _, month := range months {
+			path := filepath.Join(dir, "published-"+month+".jsonl.gz")
+			if err := appendPublishedArchive(path, byMonth[month]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func appendPublishedArchive(path string, articles []models.PublishedArticle) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open history archive: %w", err)
+	}
+	defer file.Close()
+
+	zw := gzip.NewWriter(file)
+	for _, article := range articles {
+		line, err := encodePublishedEntry(article)
+		if err != nil {
+			return err
+		}
+		if _, err := zw.Write(line); err != nil {
+			return fmt.Errorf("failed to write history archive %s: %w", path, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to write history archive %s: %w", path, err)
+	}
+
+	// The entries leave the live history next, so they must be on disk first
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("failed to write history archive %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,223 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"go-test/internal/atomicfile"
+	"go-test/internal/filelock"
+	"go-test/internal/models"
+)
+
+// publishedLogVersion is the version of the JSON Lines history format.
+// Readers reject entries from newer versions instead of misreading them.
+const publishedLogVersion = 1
+
+// historyLockTimeout is how long a process waits for another one to finish
+// with the history before giving up
+const historyLockTimeout = 30 * time.Second
+
+// legacyTimeLayout is the timestamp format of the pipe-delimited log
+const legacyTimeLayout = "2006-01-02 15:04:05"
+
+// publishedEntry is one line of the JSON Lines history
+type publishedEntry struct {
+	Version int `json:"v"`
+	models.PublishedArticle
+}
+
+// encodePublishedEntry returns article as a newline-terminated JSON line
+func encodePublishedEntry(article models.PublishedArticle) ([]byte, error) {
+	line, err := json.Marshal(publishedEntry{Version: publishedLogVersion, PublishedArticle: article})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode history entry: %w", err)
+	}
+	return append(line, '\n'), nil
+}
+
+// decodePublishedEntry parses one history line in either format. ok is
+// false for blank and unusable lines; legacy reports a pipe-delimited line.
+func decodePublishedEntry(line string) (article models.PublishedArticle, legacy, ok bool, err error) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return article, false, false, nil
+	}
+
+	if !strings.HasPrefix(line, "{") {
+		article, ok = parseLegacyPublishedLine(line)
+		return article, true, ok, nil
+	}
+
+	var entry publishedEntry
+	if err := json.Unmarshal([]byte(line), &entry); err != nil {
+		return article, false, false, fmt.Errorf("invalid history entry: %w", err)
+	}
+	if entry.Version < 1 || entry.Version > publishedLogVersion {
+		return article, false, false, fmt.Errorf("history entry has unsupported format version %d", entry.Version)
+	}
+	if entry.Link == "" {
+		return article, false, false, nil
+	}
+
+	return entry.PublishedArticle, false, true, nil
+}
+
+// readPublishedLog reads a history log, which may mix JSON and legacy
+// lines. A missing file is an empty history. legacy counts the
+// pipe-delimited entries. Unreadable lines, e.g. the torn tail of a crashed
+// write, are logged and skipped like FilePublishedStore does; damaged
+// counts them.
+func readPublishedLog(path string) (articles []models.PublishedArticle, legacy, damaged int, err error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, 0, 0, nil
+	}
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("failed to open log file: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024) // post texts make for long lines
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		article, isLegacy, ok, err := decodePublishedEntry(scanner.Text())
+		if err != nil {
+			log.Printf("⚠️  Skipping unreadable history entry at %s line %d: %v", path, lineNo, err)
+			damaged++
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if isLegacy {
+			legacy++
+		}
+		articles = append(articles, article)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, 0, 0, fmt.Errorf("error reading log file: %w", err)
+	}
+
+	return articles, legacy, damaged, nil
+}
+
+// parseLegacyPublishedLine parses "timestamp|link|title", optionally
+// followed by "|guid|hash". Entries with a malformed timestamp keep a zero
+// time but are still returned, so their link keeps blocking reposts.
+func parseLegacyPublishedLine(line string) (models.PublishedArticle, bool) {
+	parts := strings.Split(line, "|")
+	if len(parts) < 2 || parts[1] == "" {
+		return models.PublishedArticle{}, false
+	}
+
+	article := models.PublishedArticle{Link: parts[1]}
+	article.PublishedAt, _ = time.ParseInLocation(legacyTimeLayout, parts[0], time.Local)
+
+	rest := parts[2:]
+	if n := len(rest); n >= 3 && (isContentHash(rest[n-1]) || isUntitledGUIDLine(rest)) {
+		article.GUID = rest[n-2]
+		article.ContentHash = rest[n-1]
+		rest = rest[:n-2]
+	}
+	article.Title = strings.Join(rest, "|")
+
+	return article, true
+}
+
+// isContentHash tells the optional trailing hash field apart from a title
+// fragment in legacy entries whose titles contain "|"
+func isContentHash(s string) bool {
+	if len(s) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+// isUntitledGUIDLine reports the one "title|guid|hash" shape with an empty
+// hash the old writer produced: the hash is only empty when the title and
+// summary are, so a title ending in "|" is never mistaken for a GUID
+func isUntitledGUIDLine(rest []string) bool {
+	return len(rest) == 3 && rest[0] == "" && rest[2] == ""
+}
+
+// ConvertPublishedLog rewrites the history log at path as JSON Lines. The
+// original is kept next to it with a ".bak" suffix (or a timestamped one if
+// that exists). It returns the number of converted legacy entries, the
+// number of damaged lines that only the backup still holds, and the backup
+// path; a log without legacy entries is left untouched.
+func ConvertPublishedLog(path string) (converted, damaged int, backupPath string, err error) {
+	unlock, err := lockPublishedLog(path + ".lock")
+	if err != nil {
+		return 0, 0, "", err
+	}
+	defer unlock()
+
+	original, err := os.ReadFile(path)
+	if err != nil {
+		return 0, 0, "", fmt.Errorf("failed to read history log: %w", err)
+	}
+
+	articles, legacy, damaged, err := readPublishedLog(path)
+	if err != nil {
+		return 0, 0, "", err
+	}
+	if legacy == 0 {
+		return 0, 0, "", nil
+	}
+
+	var output bytes.Buffer
+	for _, article := range articles {
+		line, err := encodePublishedEntry(article)
+		if err != nil {
+			return 0, 0, "", err
+		}
+		output.Write(line)
+	}
+
+	backupPath = path + ".bak"
+	if _, err := os.Stat(backupPath); err == nil {
+		backupPath = fmt.Sprintf("%s.%s.bak", path, time.Now().Format("20060102-150405"))
+	}
+	if err := atomicfile.WriteFile(backupPath, original); err != nil {
+		return 0, 0, "", fmt.Errorf("failed to write history backup: %w", err)
+	}
+
+	if err := atomicfile.WriteFile(path, output.Bytes()); err != nil {
+		return 0, 0, "", fmt.Errorf("failed to write converted history: %w", err)
+	}
+
+	return legacy, damaged, backupPath, nil
+}
+
+// lockPublishedLog takes the advisory lock that serialises access to the
+// history log between processes. The returned function releases it.
+func lockPublishedLog(lockPath string) (func(), error) {
+	file, err := os.OpenFile(lockPath, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history lock: %w", err)
+	}
+
+	if err := filelock.Lock(file, historyLockTimeout); err != nil {
+		file.Close()
+		if errors.Is(err, filelock.ErrLocked) {
+			return nil, fmt.Errorf("history is still locked by another process after %s: %w", historyLockTimeout, err)
+		}
+		return nil, fmt.Errorf("failed to lock history: %w", err)
+	}
+
+	return func() {
+		filelock.Unlock(file)
+		file.Close()
+	}, nil
+}
@@ -0,0 +1,155 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go-test/internal/models"
+)
+
+func TestDecodePublishedEntry(t *testing.T) {
+	tests := []struct {
+		name       string
+		line       string
+		wantLink   string
+		wantTitle  string
+		wantLegacy bool
+		wantOK     bool
+		wantErr    bool
+	}{
+		{"JSON entry", `{"v":1,"link":"https://example.com/a","title":"A | B","message_id":"7","published_at":"2025-10-01T12:00:00Z"}`, "https://example.com/a", "A | B", false, true, false},
+		{"legacy entry", "2025-10-01 12:00:00|https://example.com/b|Plain title", "https://example.com/b", "Plain title", true, true, false},
+		{"legacy title with a pipe", "2025-10-01 12:00:00|https://example.com/c|Part 1 | Part 2", "https://example.com/c", "Part 1 | Part 2", true, true, false},
+		{"legacy title ending in a pipe", "2025-10-01 12:00:00|https://example.com/e|Dr. Stone | Part 2|", "https://example.com/e", "Dr. Stone | Part 2|", true, true, false},
+		{"legacy entry with GUID and hash", "2025-10-01 12:00:00|https://example.com/f|A | B|guid-1|" + strings.Repeat("ab", 32), "https://example.com/f", "A | B", true, true, false},
+		{"legacy untitled entry with GUID", "2025-10-01 12:00:00|https://example.com/g||guid-2|", "https://example.com/g", "", true, true, false},
+		{"blank line", "   ", "", "", false, false, false},
+		{"legacy line without link", "2025-10-01 12:00:00", "", "", true, false, false},
+		{"future version", `{"v":2,"link":"https://example.com/d"}`, "", "", false, false, true},
+		{"broken JSON", `{"v":1,"link":`, "", "", false, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			article, legacy, ok, err := decodePublishedEntry(tt.line)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v; wantErr %t", err, tt.wantErr)
+			}
+			if ok != tt.wantOK || legacy != tt.wantLegacy {
+				t.Fatalf("ok, legacy = %t, %t; want %t, %t", ok, legacy, tt.wantOK, tt.wantLegacy)
+			}
+			if article.Link != tt.wantLink || article.Title != tt.wantTitle {
+				t.Errorf("article = %+v; want link %q and title %q", article, tt.wantLink, tt.wantTitle)
+			}
+		})
+	}
+}
+
+func TestEncodePublishedEntry_RoundTrip(t *testing.T) {
+	article := models.PublishedArticle{
+		Link:           "https://example.com/a",
+		AlternateLinks: []string{"https://mirror.example.com/a"},
+		Title:          "Title | with pipe",
+		Channel:        "telegram:@anime",
+		MessageID:      "42",
+		Model:          "gemini-2.5-pro",
+		PostText:       "පළමු පේළිය\nදෙවන පේළිය",
+		PublishedAt:    time.Date(2025, 10, 1, 12, 0, 0, 0, time.UTC),
+	}
+
+	line, err := encodePublishedEntry(article)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(string(line), "\n") != 1 || !strings.HasPrefix(string(line), `{"v":1,`) {
+		t.Fatalf("encoded entry %q is not a single versioned JSON line", line)
+	}
+
+	decoded, _, ok, err := decodePublishedEntry(string(line))
+	if err != nil || !ok {
+		t.Fatalf("decode failed: ok=%t err=%v", ok, err)
+	}
+	if decoded.PostText != article.PostText || decoded.Title != article.Title || !decoded.PublishedAt.Equal(article.PublishedAt) || len(decoded.AlternateLinks) != 1 {
+		t.Errorf("round trip = %+v; want %+v", decoded, article)
+	}
+}
+
+func TestConvertPublishedLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), publishedLogFile)
+	legacy := "2025-09-01 08:00:00|https://example.com/old|Old | post\n" +
+		`{"v":1,"link":"https://example.com/new","title":"New","published_at":"2025-10-01T12:00:00Z"}` + "\n"
+	if err := os.WriteFile(path, []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	converted, damaged, backupPath, err := ConvertPublishedLog(path)
+	if err != nil {
+		t.Fatalf("ConvertPublishedLog() error = %v", err)
+	}
+	if converted != 1 || damaged != 0 {
+		t.Errorf("converted, damaged = %d, %d; want 1, 0", converted, damaged)
+	}
+
+	backup, err := os.ReadFile(backupPath)
+	if err != nil || string(backup) != legacy {
+		t.Errorf("backup %s = %q, %v; want the original log", backupPath, backup, err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		if !strings.HasPrefix(line, "{") {
+			t.Errorf("converted log still has legacy line %q", line)
+		}
+	}
+
+	articles, legacyCount, _, err := readPublishedLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if legacyCount != 0 || len(articles) != 2 || articles[0].Title != "Old | post" {
+		t.Errorf("converted log = %+v (%d legacy); want both entries as JSON", articles, legacyCount)
+	}
+
+	// Converting again is a no-op and makes no second backup
+	converted, _, backupPath, err = ConvertPublishedLog(path)
+	if err != nil || converted != 0 || backupPath != "" {
+		t.Errorf("second conversion = %d, %q, %v; want nothing to do", converted, backupPath, err)
+	}
+}
+
+func TestConvertPublishedLog_SkipsTornLastLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), publishedLogFile)
+	original := "2025-09-01 08:00:00|https://example.com/old|Old post\n" +
+		`{"v":1,"link":"https://example.com/new","title":"New","published_at":"2025-10-01T12:00:00Z"}` + "\n" +
+		`{"v":1,"link":"https://example.com/torn","ti`
+	if err := os.WriteFile(path, []byte(original), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	converted, damaged, backupPath, err := ConvertPublishedLog(path)
+	if err != nil {
+		t.Fatalf("ConvertPublishedLog() error = %v", err)
+	}
+	if converted != 1 || damaged != 1 {
+		t.Errorf("converted, damaged = %d, %d; want 1 converted and the torn line reported", converted, damaged)
+	}
+
+	backup, err := os.ReadFile(backupPath)
+	if err != nil || string(backup) != original {
+		t.Errorf("backup %s = %q, %v; want the original log with its torn line", backupPath, backup, err)
+	}
+
+	articles, legacyCount, _, err := readPublishedLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if legacyCount != 0 || len(articles) != 2 || articles[0].Link != "https://example.com/old" || articles[1].Link != "https://example.com/new" {
+		t.Errorf("converted log = %+v (%d legacy); want the two readable entries as JSON", articles, legacyCount)
+	}
+}
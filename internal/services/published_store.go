@@ -0,0 +1,535 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"go-test/internal/atomicfile"
+	"go-test/internal/config"
+	"go-test/internal/models"
+)
+
+const (
+	// publishedLogFile keeps its name from the pipe-delimited format, so
+	// existing installs pick up their history without moving files
+	publishedLogFile = "published_articles.txt"
+	boltPublishedDB  = "published.db"
+)
+
+// PublishedStore keeps the history of published articles. Lookups go
+// through an index, so checking a candidate does not scan the history.
+type PublishedStore interface {
+	// Contains reports whether the article's link, GUID or content hash
+	// matches a published article, whose alternate links count as links
+	// too. Empty fields are ignored.
+	Contains(article models.PublishedArticle) (bool, error)
+	// Add records a published article
+	Add(article models.PublishedArticle) error
+	// Count returns the number of recorded articles
+	Count() (int, error)
+	// Recent returns up to limit articles, newest first
+	Recent(limit int) ([]models.PublishedArticle, error)
+
+	// Reserve claims article before it is published. Reserved articles
+	// count as published for Contains, so nothing else posts them
+	// meanwhile. It returns ErrAlreadyPublished if Contains would match.
+	Reserve(article models.PublishedArticle) error
+	// MarkSent records where the reserved article with link was posted, so
+	// recovery knows the post went out even if Commit never runs
+	MarkSent(link, channel, messageID string) error
+	// Commit adds article to the history and drops its reservation
+	Commit(article models.PublishedArticle) error
+	// Release drops the reservation for link without recording anything
+	Release(link string) error
+	// Pending returns the reservations that were neither committed nor released
+	Pending() ([]Reservation, error)
+
+	// Compact removes the articles published before cutoff, handing them to
+	// archive first; if archive fails nothing is removed. Entries without a
+	// publish date are kept, as their age is unknown. It returns the number
+	// of articles removed.
+	Compact(cutoff time.Time, archive func([]models.PublishedArticle) error) (int, error)
+
+	Close() error
+}
+
+// ErrAlreadyPublished is returned by Reserve for an article that has been
+// published or is being published by another run
+var ErrAlreadyPublished = errors.New("article already published or reserved")
+
+// Reservation is a publish in progress. Article carries the channel and
+// message ID once the post has been sent.
+type Reservation struct {
+	Article    models.PublishedArticle `json:"article"`
+	ReservedAt time.Time               `json:"reserved_at"`
+}
+
+// matchesArticle reports whether key shares a link, GUID or content hash with record
+func matchesArticle(record, key models.PublishedArticle) bool {
+	if key.Link != "" {
+		for _, link := range append([]string{record.Link}, record.AlternateLinks...) {
+			if link == key.Link {
+				return true
+			}
+		}
+	}
+	return (key.GUID != "" && key.GUID == record.GUID) ||
+		(key.ContentHash != "" && key.ContentHash == record.ContentHash)
+}
+
+// PublishedLogPath returns the location of the text history log in dataDir
+func PublishedLogPath(dataDir string) string {
+	return filepath.Join(dataDir, publishedLogFile)
+}
+
+// OpenPublishedStore opens the history backend selected by HISTORY_BACKEND
+// inside DATA_DIR
+func OpenPublishedStore(cfg *config.Config) (PublishedStore, error) {
+	if err := os.MkdirAll(cfg.DataDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	logPath := PublishedLogPath(cfg.DataDir)
+
+	switch cfg.HistoryBackend {
+	case "bolt":
+		return NewBoltPublishedStore(filepath.Join(cfg.DataDir, boltPublishedDB), logPath)
+	default:
+		return NewFilePublishedStore(logPath)
+	}
+}
+
+// contentHash identifies a story by its normalized title and summary, so a
+// repost under a new URL is still recognised
+func contentHash(news models.AnimeNews) string {
+	normalize := func(text string) string {
+		return strings.Join(strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+		}), " ")
+	}
+
+	title, summary := normalize(news.Title), normalize(news.Summary)
+	if title == "" && summary == "" {
+		return ""
+	}
+
+	sum := sha256.Sum256([]byte(title + "\n" + summary))
+	return hex.EncodeToString(sum[:])
+}
+
+// FilePublishedStore keeps the history in a JSON Lines log, one event per
+// line, and indexes it in memory. Logs written by earlier versions in the
+// pipe-delimited format are read as well; new events are always appended
+// as JSON. Reservations live in a small JSON file next to the log.
+//
+// Several processes can share the log: every operation holds an advisory
+// lock on a ".lock" file next to it and first reads whatever other
+// processes appended since the last operation.
+type FilePublishedStore struct {
+	path        string
+	pendingPath string
+	lockPath    string
+
+	mu       sync.Mutex
+	articles []models.PublishedArticle
+	links    map[string]bool
+	guids    map[string]bool
+	hashes   map[string]bool
+	pending  map[string]*Reservation // keyed by link
+	now      func() time.Time
+
+	// logInfo and offset track how much of the log is indexed, so a refresh
+	// only reads new lines unless the log was replaced or truncated
+	logInfo os.FileInfo
+	offset  int64
+	lineNo  int
+}
+
+// NewFilePublishedStore loads the log at path, which need not exist yet
+func NewFilePublishedStore(path string) (*FilePublishedStore, error) {
+	store := &FilePublishedStore{
+		path:        path,
+		pendingPath: path + ".pending.json",
+		lockPath:    path + ".lock",
+		pending:     make(map[string]*Reservation),
+		now:         time.Now,
+	}
+	store.reset()
+
+	if err := store.withLock(func() error { return nil }); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// Contains implements PublishedStore
+func (s *FilePublishedStore) Contains(article models.PublishedArticle) (bool, error) {
+	found := false
+	err := s.withLock(func() error {
+		found = s.containsLocked(article)
+		return nil
+	})
+	return found, err
+}
+
+func (s *FilePublishedStore) containsLocked(article models.PublishedArticle) bool {
+	if (article.Link != "" && s.links[article.Link]) ||
+		(article.GUID != "" && s.guids[article.GUID]) ||
+		(article.ContentHash != "" && s.hashes[article.ContentHash]) {
+		return true
+	}
+
+	for _, reservation := range s.pending {
+		if matchesArticle(reservation.Article, article) {
+			return true
+		}
+	}
+	return false
+}
+
+// Add implements PublishedStore
+func (s *FilePublishedStore) Add(article models.PublishedArticle) error {
+	return s.withLock(func() error {
+		return s.appendLocked(article)
+	})
+}
+
+func (s *FilePublishedStore) appendLocked(article models.PublishedArticle) error {
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file for writing: %w", err)
+	}
+	defer file.Close()
+
+	line, err := encodePublishedEntry(article)
+	if err != nil {
+		return err
+	}
+
+	// Everything up to the offset ends in a newline. Anything past it was
+	// left by a write that crashed, as writers hold the lock, so the new
+	// entry starts on a line of its own.
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat log file: %w", err)
+	}
+	if info.Size() > s.offset {
+		line = append([]byte("\n"), line...)
+	}
+
+	if _, err := file.Write(line); err != nil {
+		return fmt.Errorf("failed to write to log file: %w", err)
+	}
+
+	// Read the line back like any other, which also tracks the new offset
+	return s.refreshLogLocked()
+}
+
+// Count implements PublishedStore
+func (s *FilePublishedStore) Count() (int, error) {
+	count := 0
+	err := s.withLock(func() error {
+		count = len(s.articles)
+		return nil
+	})
+	return count, err
+}
+
+// Recent implements PublishedStore
+func (s *FilePublishedStore) Recent(limit int) ([]models.PublishedArticle, error) {
+	recent := []models.PublishedArticle{}
+	err := s.withLock(func() error {
+		for i := len(s.articles) - 1; i >= 0 && len(recent) < limit; i-- {
+			recent = append(recent, s.articles[i])
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return recent, nil
+}
+
+// Reserve implements PublishedStore
+func (s *FilePublishedStore) Reserve(article models.PublishedArticle) error {
+	return s.withLock(func() error {
+		if s.containsLocked(article) {
+			return ErrAlreadyPublished
+		}
+
+		s.pending[article.Link] = &Reservation{Article: article, ReservedAt: s.now()}
+		if err := s.savePendingLocked(); err != nil {
+			delete(s.pending, article.Link)
+			return err
+		}
+		return nil
+	})
+}
+
+// MarkSent implements PublishedStore
+func (s *FilePublishedStore) MarkSent(link, channel, messageID string) error {
+	return s.withLock(func() error {
+		reservation, ok := s.pending[link]
+		if !ok {
+			return fmt.Errorf("no reservation for %s", link)
+		}
+		reservation.Article.Channel = channel
+		reservation.Article.MessageID = messageID
+		return s.savePendingLocked()
+	})
+}
+
+// Commit implements PublishedStore
+func (s *FilePublishedStore) Commit(article models.PublishedArticle) error {
+	return s.withLock(func() error {
+		if err := s.appendLocked(article); err != nil {
+			return err
+		}
+		delete(s.pending, article.Link)
+		return s.savePendingLocked()
+	})
+}
+
+// Release implements PublishedStore
+func (s *FilePublishedStore) Release(link string) error {
+	return s.withLock(func() error {
+		if _, ok := s.pending[link]; !ok {
+			return nil
+		}
+		delete(s.pending, link)
+		return s.savePendingLocked()
+	})
+}
+
+// Pending implements PublishedStore
+func (s *FilePublishedStore) Pending() ([]Reservation, error) {
+	var reservations []Reservation
+	err := s.withLock(func() error {
+		reservations = make([]Reservation, 0, len(s.pending))
+		for _, reservation := range s.pending {
+			reservations = append(reservations, *reservation)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(reservations, func(i, j int) bool {
+		return reservations[i].ReservedAt.Before(reservations[j].ReservedAt)
+	})
+	return reservations, nil
+}
+
+// Compact implements PublishedStore. The remaining entries are rewritten
+// as JSON Lines, so legacy lines are converted on the way.
+func (s *FilePublishedStore) Compact(cutoff time.Time, archive func([]models.PublishedArticle) error) (int, error) {
+	removed := 0
+	err := s.withLock(func() error {
+		var old, kept []models.PublishedArticle
+		for _, article := range s.articles {
+			if !article.PublishedAt.IsZero() && article.PublishedAt.Before(cutoff) {
+				old = append(old, article)
+			} else {
+				kept = append(kept, article)
+			}
+		}
+		if len(old) == 0 {
+			return nil
+		}
+
+		if err := archive(old); err != nil {
+			return fmt.Errorf("failed to archive history: %w", err)
+		}
+
+		var output bytes.Buffer
+		for _, article := range kept {
+			line, err := encodePublishedEntry(article)
+			if err != nil {
+				return err
+			}
+			output.Write(line)
+		}
+
+		if err := atomicfile.WriteFile(s.path, output.Bytes()); err != nil {
+			return fmt.Errorf("failed to write compacted history: %w", err)
+		}
+
+		removed = len(old)
+		s.reset()
+		return s.refreshLogLocked()
+	})
+	return removed, err
+}
+
+// Close implements PublishedStore
+func (s *FilePublishedStore) Close() error {
+	return nil
+}
+
+// withLock runs fn while holding the store's mutex and the history file
+// lock, after catching up with changes made by other processes
+func (s *FilePublishedStore) withLock(fn func() error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	unlock, err := lockPublishedLog(s.lockPath)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := s.refreshLogLocked(); err != nil {
+		return err
+	}
+	if err := s.loadPendingLocked(); err != nil {
+		return err
+	}
+	return fn()
+}
+
+// refreshLogLocked indexes the lines appended to the log since the last
+// refresh. A log that was replaced, e.g. by history convert, or that shrank
+// is indexed again from the start.
+func (s *FilePublishedStore) refreshLogLocked() error {
+	info, err := os.Stat(s.path)
+	if os.IsNotExist(err) {
+		if s.logInfo != nil {
+			s.reset()
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to stat log file: %w", err)
+	}
+
+	if s.logInfo != nil && (!os.SameFile(s.logInfo, info) || info.Size() < s.offset) {
+		s.reset()
+	}
+	s.logInfo = info
+	if info.Size() == s.offset {
+		return nil
+	}
+
+	file, err := os.Open(s.path)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Seek(s.offset, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to read log file: %w", err)
+	}
+
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadString('\n')
+		if err == io.EOF {
+			// A line without its newline is still being written; it is read next time
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("error reading log file: %w", err)
+		}
+
+		// A bad line, e.g. the torn tail of a crashed write, is skipped so the
+		// lines after it are still indexed
+		article, _, ok, err := decodePublishedEntry(line)
+		s.offset += int64(len(line))
+		s.lineNo++
+		if err != nil {
+			log.Printf("⚠️  Skipping unreadable history entry at %s line %d: %v", s.path, s.lineNo, err)
+			continue
+		}
+		if ok {
+			s.index(article)
+		}
+	}
+}
+
+// loadPendingLocked reads the reservations, which another process may have changed
+func (s *FilePublishedStore) loadPendingLocked() error {
+	data, err := os.ReadFile(s.pendingPath)
+	if os.IsNotExist(err) {
+		s.pending = make(map[string]*Reservation)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read reservations: %w", err)
+	}
+
+	var reservations []Reservation
+	if err := json.Unmarshal(data, &reservations); err != nil {
+		return fmt.Errorf("failed to decode reservations: %w", err)
+	}
+
+	s.pending = make(map[string]*Reservation, len(reservations))
+	for i := range reservations {
+		s.pending[reservations[i].Article.Link] = &reservations[i]
+	}
+	return nil
+}
+
+// savePendingLocked writes the reservations, removing the file when there are none
+func (s *FilePublishedStore) savePendingLocked() error {
+	if len(s.pending) == 0 {
+		if err := os.Remove(s.pendingPath); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove reservations file: %w", err)
+		}
+		return nil
+	}
+
+	reservations := make([]Reservation, 0, len(s.pending))
+	for _, reservation := range s.pending {
+		reservations = append(reservations, *reservation)
+	}
+
+	data, err := json.MarshalIndent(reservations, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode reservations: %w", err)
+	}
+
+	if err := atomicfile.WriteFile(s.pendingPath, data); err != nil {
+		return fmt.Errorf("failed to write reservations file: %w", err)
+	}
+	return nil
+}
+
+// reset forgets the indexed log
+func (s *FilePublishedStore) reset() {
+	s.articles = nil
+	s.links = make(map[string]bool)
+	s.guids = make(map[string]bool)
+	s.hashes = make(map[string]bool)
+	s.logInfo = nil
+	s.offset = 0
+	s.lineNo = 0
+}
+
+func (s *FilePublishedStore) index(article models.PublishedArticle) {
+	s.articles = append(s.articles, article)
+	s.links[article.Link] = true
+	for _, link := range article.AlternateLinks {
+		s.links[link] = true
+	}
+	if article.GUID != "" {
+		s.guids[article.GUID] = true
+	}
+	if article.ContentHash != "" {
+		s.hashes[article.ContentHash] = true
+	}
+}
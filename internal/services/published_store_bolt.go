@@ -0,0 +1,403 @@
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"go-test/internal/models"
+)
+
+var (
+	boltArticlesBucket = []byte("articles")
+	boltLinksBucket    = []byte("links")
+	boltGUIDsBucket    = []byte("guids")
+	boltHashesBucket   = []byte("hashes")
+	boltMetaBucket     = []byte("meta")
+	boltPendingBucket  = []byte("pending")
+
+	boltLegacyImportKey = []byte("legacy_import")
+)
+
+// BoltPublishedStore keeps the history in an embedded bbolt database.
+// Articles are stored as versioned JSON entries by sequence number, with one
+// index bucket each for links, GUIDs and content hashes.
+//
+// bbolt locks the database file for as long as it is open, so the store
+// opens it for each operation only. Other processes wait for the lock for
+// up to historyLockTimeout instead of being shut out for a whole run.
+type BoltPublishedStore struct {
+	path string
+	now  func() time.Time
+}
+
+// NewBoltPublishedStore opens the database at path. The first time, it
+// imports the text history log at logPath if there is one; the log is left
+// in place so it can still be inspected or used by the file backend.
+func NewBoltPublishedStore(path, logPath string) (*BoltPublishedStore, error) {
+	store := &BoltPublishedStore{path: path, now: time.Now}
+
+	err := store.update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{boltArticlesBucket, boltLinksBucket, boltGUIDsBucket, boltHashesBucket, boltMetaBucket, boltPendingBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialise history database: %w", err)
+	}
+
+	if err := store.importLegacyLog(logPath); err != nil {
+		return nil, err
+	}
+
+	return store, nil
+}
+
+// importLegacyLog copies the text history log into the database once. The
+// check and the import share a transaction, so two processes starting at
+// the same time cannot both import it. Damaged lines are logged and left
+// out, as the file backend does, so they cannot keep the store from opening.
+func (s *BoltPublishedStore) importLegacyLog(logPath string) error {
+	var articles []models.PublishedArticle
+	err := s.update(func(tx *bolt.Tx) error {
+		if tx.Bucket(boltMetaBucket).Get(boltLegacyImportKey) != nil {
+			return nil
+		}
+
+		var err error
+		articles, _, _, err = readPublishedLog(logPath)
+		if err != nil {
+			return fmt.Errorf("failed to read legacy history: %w", err)
+		}
+
+		for _, article := range articles {
+			if err := putArticle(tx, article); err != nil {
+				return err
+			}
+		}
+		return tx.Bucket(boltMetaBucket).Put(boltLegacyImportKey, []byte(time.Now().UTC().Format(time.RFC3339)))
+	})
+	if err != nil {
+		return fmt.Errorf("failed to import legacy history: %w", err)
+	}
+
+	if len(articles) > 0 {
+		log.Printf("Imported %d published articles from %s", len(articles), logPath)
+	}
+	return nil
+}
+
+// Contains implements PublishedStore
+func (s *BoltPublishedStore) Contains(article models.PublishedArticle) (bool, error) {
+	found := false
+	err := s.view(func(tx *bolt.Tx) error {
+		var err error
+		found, err = boltContains(tx, article)
+		return err
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to look up history: %w", err)
+	}
+	return found, nil
+}
+
+// boltContains checks the indexes and the pending reservations
+func boltContains(tx *bolt.Tx, article models.PublishedArticle) (bool, error) {
+	for _, lookup := range boltIndexKeys(article) {
+		if tx.Bucket(lookup.bucket).Get([]byte(lookup.key)) != nil {
+			return true, nil
+		}
+	}
+
+	reservations, err := boltReservations(tx)
+	if err != nil {
+		return false, err
+	}
+	for _, reservation := range reservations {
+		if matchesArticle(reservation.Article, article) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Add implements PublishedStore
+func (s *BoltPublishedStore) Add(article models.PublishedArticle) error {
+	if err := s.update(func(tx *bolt.Tx) error { return putArticle(tx, article) }); err != nil {
+		return fmt.Errorf("failed to record published article: %w", err)
+	}
+	return nil
+}
+
+// Count implements PublishedStore
+func (s *BoltPublishedStore) Count() (int, error) {
+	count := 0
+	err := s.view(func(tx *bolt.Tx) error {
+		count = tx.Bucket(boltArticlesBucket).Stats().KeyN
+		return nil
+	})
+	return count, err
+}
+
+// Recent implements PublishedStore
+func (s *BoltPublishedStore) Recent(limit int) ([]models.PublishedArticle, error) {
+	recent := []models.PublishedArticle{}
+	err := s.view(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(boltArticlesBucket).Cursor()
+		for key, value := cursor.Last(); key != nil && len(recent) < limit; key, value = cursor.Prev() {
+			article, _, _, err := decodePublishedEntry(string(value))
+			if err != nil {
+				return err
+			}
+			recent = append(recent, article)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return recent, nil
+}
+
+// Reserve implements PublishedStore
+func (s *BoltPublishedStore) Reserve(article models.PublishedArticle) error {
+	return s.update(func(tx *bolt.Tx) error {
+		found, err := boltContains(tx, article)
+		if err != nil {
+			return err
+		}
+		if found {
+			return ErrAlreadyPublished
+		}
+		return putReservation(tx, Reservation{Article: article, ReservedAt: s.now()})
+	})
+}
+
+// MarkSent implements PublishedStore
+func (s *BoltPublishedStore) MarkSent(link, channel, messageID string) error {
+	return s.update(func(tx *bolt.Tx) error {
+		value := tx.Bucket(boltPendingBucket).Get([]byte(link))
+		if value == nil {
+			return fmt.Errorf("no reservation for %s", link)
+		}
+
+		var reservation Reservation
+		if err := json.Unmarshal(value, &reservation); err != nil {
+			return fmt.Errorf("failed to decode reservation: %w", err)
+		}
+		reservation.Article.Channel = channel
+		reservation.Article.MessageID = messageID
+		return putReservation(tx, reservation)
+	})
+}
+
+// Commit implements PublishedStore
+func (s *BoltPublishedStore) Commit(article models.PublishedArticle) error {
+	err := s.update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(boltPendingBucket).Delete([]byte(article.Link)); err != nil {
+			return err
+		}
+		return putArticle(tx, article)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to commit published article: %w", err)
+	}
+	return nil
+}
+
+// Release implements PublishedStore
+func (s *BoltPublishedStore) Release(link string) error {
+	return s.update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltPendingBucket).Delete([]byte(link))
+	})
+}
+
+// Pending implements PublishedStore
+func (s *BoltPublishedStore) Pending() ([]Reservation, error) {
+	var reservations []Reservation
+	err := s.view(func(tx *bolt.Tx) error {
+		var err error
+		reservations, err = boltReservations(tx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(reservations, func(i, j int) bool {
+		return reservations[i].ReservedAt.Before(reservations[j].ReservedAt)
+	})
+	return reservations, nil
+}
+
+// Compact implements PublishedStore. bbolt reuses the freed pages for new
+// entries rather than shrinking the file.
+func (s *BoltPublishedStore) Compact(cutoff time.Time, archive func([]models.PublishedArticle) error) (int, error) {
+	removed := 0
+	err := s.update(func(tx *bolt.Tx) error {
+		var ids [][]byte
+		var old []models.PublishedArticle
+		err := tx.Bucket(boltArticlesBucket).ForEach(func(id, value []byte) error {
+			article, _, _, err := decodePublishedEntry(string(value))
+			if err != nil {
+				return err
+			}
+			if !article.PublishedAt.IsZero() && article.PublishedAt.Before(cutoff) {
+				ids = append(ids, append([]byte(nil), id...))
+				old = append(old, article)
+			}
+			return nil
+		})
+		if err != nil || len(old) == 0 {
+			return err
+		}
+
+		if err := archive(old); err != nil {
+			return fmt.Errorf("failed to archive history: %w", err)
+		}
+
+		for i, article := range old {
+			if err := tx.Bucket(boltArticlesBucket).Delete(ids[i]); err != nil {
+				return err
+			}
+			// A key may have been indexed again by a later article, which keeps it
+			for _, index := range boltIndexKeys(article) {
+				bucket := tx.Bucket(index.bucket)
+				if bytes.Equal(bucket.Get([]byte(index.key)), ids[i]) {
+					if err := bucket.Delete([]byte(index.key)); err != nil {
+						return err
+					}
+				}
+			}
+		}
+
+		removed = len(old)
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to compact history: %w", err)
+	}
+	return removed, nil
+}
+
+// Close implements PublishedStore
+func (s *BoltPublishedStore) Close() error {
+	return nil
+}
+
+// view runs fn in a read-only transaction
+func (s *BoltPublishedStore) view(fn func(tx *bolt.Tx) error) error {
+	db, err := s.open()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return db.View(fn)
+}
+
+// update runs fn in a read-write transaction
+func (s *BoltPublishedStore) update(fn func(tx *bolt.Tx) error) error {
+	db, err := s.open()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return db.Update(fn)
+}
+
+func (s *BoltPublishedStore) open() (*bolt.DB, error) {
+	db, err := bolt.Open(s.path, 0644, &bolt.Options{Timeout: historyLockTimeout})
+	if errors.Is(err, bolt.ErrTimeout) {
+		return nil, fmt.Errorf("history database is still locked by another process after %s: %w", historyLockTimeout, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history database: %w", err)
+	}
+	return db, nil
+}
+
+// putArticle stores article under the next sequence number and indexes its keys
+func putArticle(tx *bolt.Tx, article models.PublishedArticle) error {
+	articles := tx.Bucket(boltArticlesBucket)
+
+	seq, err := articles.NextSequence()
+	if err != nil {
+		return err
+	}
+	id := make([]byte, 8)
+	binary.BigEndian.PutUint64(id, seq)
+
+	value, err := encodePublishedEntry(article)
+	if err != nil {
+		return err
+	}
+	if err := articles.Put(id, bytes.TrimSpace(value)); err != nil {
+		return err
+	}
+
+	for _, index := range boltIndexKeys(article) {
+		if err := tx.Bucket(index.bucket).Put([]byte(index.key), id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func putReservation(tx *bolt.Tx, reservation Reservation) error {
+	value, err := json.Marshal(reservation)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(boltPendingBucket).Put([]byte(reservation.Article.Link), value)
+}
+
+func boltReservations(tx *bolt.Tx) ([]Reservation, error) {
+	var reservations []Reservation
+	err := tx.Bucket(boltPendingBucket).ForEach(func(_, value []byte) error {
+		var reservation Reservation
+		if err := json.Unmarshal(value, &reservation); err != nil {
+			return fmt.Errorf("failed to decode reservation: %w", err)
+		}
+		reservations = append(reservations, reservation)
+		return nil
+	})
+	return reservations, err
+}
+
+type boltIndexKey struct {
+	bucket []byte
+	key    string
+}
+
+// boltIndexKeys returns the non-empty index entries of article
+func boltIndexKeys(article models.PublishedArticle) []boltIndexKey {
+	keys := []boltIndexKey{
+		{boltLinksBucket, article.Link},
+		{boltGUIDsBucket, article.GUID},
+		{boltHashesBucket, article.ContentHash},
+	}
+	for _, link := range article.AlternateLinks {
+		keys = append(keys, boltIndexKey{boltLinksBucket, link})
+	}
+
+	nonEmpty := keys[:0]
+	for _, index := range keys {
+		if index.key != "" {
+			nonEmpty = append(nonEmpty, index)
+		}
+	}
+	return nonEmpty
+}
@@ -0,0 +1,615 @@
+package services
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"go-test/internal/models"
+)
+
+// publishedStoreBackends opens each PublishedStore implementation in dir
+var publishedStoreBackends = []struct {
+	name string
+	open func(dir string) (PublishedStore, error)
+}{
+	{"file", func(dir string) (PublishedStore, error) {
+		return NewFilePublishedStore(filepath.Join(dir, publishedLogFile))
+	}},
+	{"bolt", func(dir string) (PublishedStore, error) {
+		return NewBoltPublishedStore(filepath.Join(dir, boltPublishedDB), filepath.Join(dir, publishedLogFile))
+	}},
+}
+
+func TestPublishedStores(t *testing.T) {
+	published := time.Date(2025, 10, 1, 12, 0, 0, 0, time.Local)
+	hash := contentHash(models.AnimeNews{Title: "Frieren season 2 announced", Summary: "Coming in January."})
+
+	for _, backend := range publishedStoreBackends {
+		t.Run(backend.name, func(t *testing.T) {
+			dir := t.TempDir()
+			store, err := backend.open(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			articles := []models.PublishedArticle{
+				{Link: "https://example.com/a", Title: "First | with a pipe", PublishedAt: published},
+				{Link: "https://example.com/b", GUID: "tag:example.com,2025:b", ContentHash: hash, Title: "Second", PublishedAt: published.Add(time.Hour)},
+			}
+			for _, article := range articles {
+				if err := store.Add(article); err != nil {
+					t.Fatalf("Add() error = %v", err)
+				}
+			}
+
+			// Reopening must find everything through the persisted data
+			if err := store.Close(); err != nil {
+				t.Fatal(err)
+			}
+			store, err = backend.open(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer store.Close()
+
+			lookups := []struct {
+				name  string
+				key   models.PublishedArticle
+				found bool
+			}{
+				{"known link", models.PublishedArticle{Link: "https://example.com/a"}, true},
+				{"known GUID under a new link", models.PublishedArticle{Link: "https://example.com/new", GUID: "tag:example.com,2025:b"}, true},
+				{"known content hash", models.PublishedArticle{Link: "https://other.example.com/b", ContentHash: hash}, true},
+				{"unknown article", models.PublishedArticle{Link: "https://example.com/c", GUID: "c"}, false},
+				{"empty key", models.PublishedArticle{}, false},
+			}
+			for _, lookup := range lookups {
+				found, err := store.Contains(lookup.key)
+				if err != nil {
+					t.Fatalf("Contains() error = %v", err)
+				}
+				if found != lookup.found {
+					t.Errorf("%s: Contains() = %t; want %t", lookup.name, found, lookup.found)
+				}
+			}
+
+			if count, _ := store.Count(); count != 2 {
+				t.Errorf("Count() = %d; want 2", count)
+			}
+
+			recent, err := store.Recent(5)
+			if err != nil {
+				t.Fatalf("Recent() error = %v", err)
+			}
+			if len(recent) != 2 || recent[0].Link != "https://example.com/b" || recent[1].Title != "First | with a pipe" {
+				t.Errorf("Recent() = %+v; want newest first with titles intact", recent)
+			}
+			if !recent[0].PublishedAt.Equal(published.Add(time.Hour)) {
+				t.Errorf("Recent()[0].PublishedAt = %s; want %s", recent[0].PublishedAt, published.Add(time.Hour))
+			}
+		})
+	}
+}
+
+func TestPublishedStores_Reservations(t *testing.T) {
+	for _, backend := range publishedStoreBackends {
+		t.Run(backend.name, func(t *testing.T) {
+			dir := t.TempDir()
+			store, err := backend.open(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			sent := models.PublishedArticle{Link: "https://example.com/sent", GUID: "sent-1", Title: "Sent"}
+			unsent := models.PublishedArticle{Link: "https://example.com/unsent", Title: "Unsent"}
+			for _, article := range []models.PublishedArticle{sent, unsent} {
+				if err := store.Reserve(article); err != nil {
+					t.Fatalf("Reserve(%s) error = %v", article.Link, err)
+				}
+			}
+
+			if err := store.Reserve(models.PublishedArticle{Link: "https://example.com/other", GUID: "sent-1"}); err != ErrAlreadyPublished {
+				t.Errorf("Reserve() of a reserved GUID error = %v; want ErrAlreadyPublished", err)
+			}
+			if found, _ := store.Contains(models.PublishedArticle{Link: unsent.Link}); !found {
+				t.Error("Contains() = false for a reserved link")
+			}
+			if err := store.MarkSent(sent.Link, "telegram:@anime", "42"); err != nil {
+				t.Fatalf("MarkSent() error = %v", err)
+			}
+
+			// Reservations survive a crash
+			store.Close()
+			store, err = backend.open(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer store.Close()
+
+			pending, err := store.Pending()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(pending) != 2 {
+				t.Fatalf("Pending() = %d reservations; want 2", len(pending))
+			}
+			for _, reservation := range pending {
+				if reservation.Article.Link == sent.Link && reservation.Article.MessageID != "42" {
+					t.Errorf("reservation of sent article lost its message ID: %+v", reservation)
+				}
+			}
+
+			if err := store.Release(unsent.Link); err != nil {
+				t.Fatal(err)
+			}
+			if found, _ := store.Contains(models.PublishedArticle{Link: unsent.Link}); found {
+				t.Error("Contains() = true after Release()")
+			}
+
+			if err := store.Commit(pending[0].Article); err != nil {
+				t.Fatal(err)
+			}
+			if pending, _ := store.Pending(); len(pending) != 0 {
+				t.Errorf("Pending() after commit and release = %+v; want none", pending)
+			}
+			if count, _ := store.Count(); count != 1 {
+				t.Errorf("Count() = %d; want the committed article only", count)
+			}
+		})
+	}
+}
+
+func TestPublishedStores_SharedBetweenProcesses(t *testing.T) {
+	for _, backend := range publishedStoreBackends {
+		t.Run(backend.name, func(t *testing.T) {
+			// Two stores on the same files stand in for cron and a manual run
+			dir := t.TempDir()
+			first, err := backend.open(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer first.Close()
+			second, err := backend.open(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer second.Close()
+
+			article := models.PublishedArticle{Link: "https://example.com/a", Title: "A"}
+			if err := first.Reserve(article); err != nil {
+				t.Fatal(err)
+			}
+			if err := second.Reserve(article); !errors.Is(err, ErrAlreadyPublished) {
+				t.Errorf("Reserve() of an article reserved elsewhere = %v; want ErrAlreadyPublished", err)
+			}
+
+			if err := first.Commit(article); err != nil {
+				t.Fatal(err)
+			}
+			if found, err := second.Contains(models.PublishedArticle{Link: article.Link}); err != nil || !found {
+				t.Errorf("Contains() after commit elsewhere = %t, %v; want true", found, err)
+			}
+			if pending, _ := second.Pending(); len(pending) != 0 {
+				t.Errorf("Pending() = %+v; want the reservation gone", pending)
+			}
+
+			if err := second.Add(models.PublishedArticle{Link: "https://example.com/b", Title: "B"}); err != nil {
+				t.Fatal(err)
+			}
+			if count, _ := first.Count(); count != 2 {
+				t.Errorf("Count() = %d; want both processes' articles", count)
+			}
+		})
+	}
+}
+
+func TestFilePublishedStore_ReloadsReplacedLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), publishedLogFile)
+	legacy := "2025-09-01 08:00:00|https://example.com/old|Old post\n"
+	if err := os.WriteFile(path, []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := NewFilePublishedStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, err := ConvertPublishedLog(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Add(models.PublishedArticle{Link: "https://example.com/new", Title: "New post"}); err != nil {
+		t.Fatal(err)
+	}
+
+	recent, err := store.Recent(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recent) != 2 || recent[0].Link != "https://example.com/new" || recent[1].Link != "https://example.com/old" {
+		t.Errorf("Recent() = %+v; want the converted entry once plus the new one", recent)
+	}
+}
+
+func TestFilePublishedStore_SkipsDamagedLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), publishedLogFile)
+	first, err := encodePublishedEntry(models.PublishedArticle{Link: "https://example.com/first", Title: "First post"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A garbled line, then the torn tail of a write that crashed
+	damaged := string(first) + "{\"v\":1,\"link\":\n" + `{"v":1,"link":"https://example.com/to`
+	if err := os.WriteFile(path, []byte(damaged), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := NewFilePublishedStore(path)
+	if err != nil {
+		t.Fatalf("NewFilePublishedStore() error = %v", err)
+	}
+	if err := store.Add(models.PublishedArticle{Link: "https://example.com/second", Title: "Second post"}); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+
+	// A fresh reader sees both entries, the new one not glued to the torn tail
+	reopened, err := NewFilePublishedStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, store := range []*FilePublishedStore{store, reopened} {
+		for _, link := range []string{"https://example.com/first", "https://example.com/second"} {
+			if found, err := store.Contains(models.PublishedArticle{Link: link}); err != nil || !found {
+				t.Errorf("Contains(%s) = %v, %v; want true", link, found, err)
+			}
+		}
+	}
+}
+
+func TestPublishedStores_Compact(t *testing.T) {
+	now := time.Date(2025, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	for _, backend := range publishedStoreBackends {
+		t.Run(backend.name, func(t *testing.T) {
+			dir := t.TempDir()
+			store, err := backend.open(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer store.Close()
+
+			articles := []models.PublishedArticle{
+				{Link: "https://example.com/july", GUID: "july", Title: "July", PublishedAt: now.AddDate(0, -3, 0)},
+				{Link: "https://example.com/august", Title: "August", PublishedAt: now.AddDate(0, -2, 0)},
+				{Link: "https://example.com/undated", Title: "Undated"},
+				{Link: "https://example.com/recent", Title: "Recent", PublishedAt: now.AddDate(0, 0, -1)},
+			}
+			for _, article := range articles {
+				if err := store.Add(article); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			archiveDir := filepath.Join(dir, publishedArchiveDir)
+			cutoff := now.AddDate(0, 0, -30)
+
+			failing := func([]models.PublishedArticle) error { return errors.New("disk full") }
+			if _, err := store.Compact(cutoff, failing); err == nil {
+				t.Fatal("Compact() with a failing archive returned no error")
+			}
+			if count, _ := store.Count(); count != 4 {
+				t.Fatalf("Count() after failed compaction = %d; want nothing removed", count)
+			}
+
+			removed, err := store.Compact(cutoff, PublishedArchiver(archiveDir))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if removed != 2 {
+				t.Errorf("Compact() removed %d; want 2", removed)
+			}
+
+			for _, article := range articles {
+				found, err := store.Contains(models.PublishedArticle{Link: article.Link})
+				if err != nil {
+					t.Fatal(err)
+				}
+				wantKept := article.PublishedAt.IsZero() || !article.PublishedAt.Before(cutoff)
+				if found != wantKept {
+					t.Errorf("Contains(%s) after compaction = %t; want %t", article.Title, found, wantKept)
+				}
+			}
+			if found, _ := store.Contains(models.PublishedArticle{GUID: "july"}); found {
+				t.Error("archived GUID still counts as published")
+			}
+
+			july := readArchive(t, filepath.Join(archiveDir, "published-2025-07.jsonl.gz"))
+			if len(july) != 1 || july[0].Title != "July" || july[0].GUID != "july" {
+				t.Errorf("July archive = %+v; want the full July entry", july)
+			}
+
+			// A second run has nothing to do and appends nothing
+			if removed, err := store.Compact(cutoff, PublishedArchiver(archiveDir)); err != nil || removed != 0 {
+				t.Errorf("second Compact() = %d, %v; want 0, nil", removed, err)
+			}
+			if august := readArchive(t, filepath.Join(archiveDir, "published-2025-08.jsonl.gz")); len(august) != 1 {
+				t.Errorf("August archive has %d entries; want 1", len(august))
+			}
+		})
+	}
+}
+
+// readArchive decodes every gzip member of a history archive
+func readArchive(t *testing.T, path string) []models.PublishedArticle {
+	t.Helper()
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	zr, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var articles []models.PublishedArticle
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		article, _, ok, err := decodePublishedEntry(line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok {
+			articles = append(articles, article)
+		}
+	}
+	return articles
+}
+
+func TestDuplicateChecker_RecoverReservations(t *testing.T) {
+	store, err := NewFilePublishedStore(filepath.Join(t.TempDir(), publishedLogFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2025, 10, 1, 12, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return start }
+	checker := NewDuplicateChecker(store)
+	checker.now = func() time.Time { return start }
+
+	sent, err := checker.Reserve(models.AnimeNews{Title: "Sent", Link: "https://example.com/sent"}, PublishDetails{PostText: "post"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := checker.MarkSent(&sent, &PublishReceipt{Channel: "telegram:@anime", MessageID: "42"}); err != nil {
+		t.Fatal(err)
+	}
+	// Telegram took this post, but its message ID could not be read
+	unknownID, err := checker.Reserve(models.AnimeNews{Title: "Sent without ID", Link: "https://example.com/sent-without-id"}, PublishDetails{PostText: "post"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := checker.MarkSent(&unknownID, &PublishReceipt{Channel: "telegram:@anime"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := checker.Reserve(models.AnimeNews{Title: "Crashed", Link: "https://example.com/crashed"}, PublishDetails{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A run that is still publishing keeps its reservations
+	checker.now = func() time.Time { return start.Add(time.Minute) }
+	committed, held, err := checker.RecoverReservations(10 * time.Minute)
+	if err != nil || len(committed) != 0 || len(held) != 0 {
+		t.Fatalf("RecoverReservations() of fresh reservations = %v, %v, %v; want nothing settled", committed, held, err)
+	}
+
+	checker.now = func() time.Time { return start.Add(time.Hour) }
+	committed, held, err = checker.RecoverReservations(10 * time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	messageIDs := make(map[string]string)
+	for _, reservation := range committed {
+		messageIDs[reservation.Article.Title] = reservation.Article.MessageID
+	}
+	if want := map[string]string{"Sent": "42", "Sent without ID": ""}; !reflect.DeepEqual(messageIDs, want) {
+		t.Errorf("committed = %+v; want both sent articles", committed)
+	}
+
+	// Whether the crashed run's post went out is unknown, so it stays blocked
+	if len(held) != 1 || held[0].Article.Title != "Crashed" {
+		t.Errorf("held = %+v; want the unconfirmed article", held)
+	}
+	if isNew, _ := checker.CheckIfPostedBefore("https://example.com/crashed"); isNew {
+		t.Error("unconfirmed article can be posted again before an operator resolved it")
+	}
+	if _, held, _ = checker.RecoverReservations(0); len(held) != 1 {
+		t.Errorf("held on the next recovery = %+v; want the article still held", held)
+	}
+
+	if isNew, _ := checker.CheckIfPostedBefore("https://example.com/sent"); isNew {
+		t.Error("sent article can be posted again after recovery")
+	}
+
+	// The operator found the post missing from the channel and lets it be retried
+	if _, err := checker.ResolveReservation("https://www.example.com/crashed?utm_source=x", false); err != nil {
+		t.Fatalf("ResolveReservation() error = %v", err)
+	}
+	if isNew, _ := checker.CheckIfPostedBefore("https://example.com/crashed"); !isNew {
+		t.Error("released article stays blocked after it was resolved")
+	}
+	if _, err := checker.ResolveReservation("https://example.com/crashed", true); !errors.Is(err, ErrNoReservation) {
+		t.Errorf("ResolveReservation() of a settled link error = %v; want ErrNoReservation", err)
+	}
+}
+
+func TestDuplicateChecker_ResolveReservationAsSent(t *testing.T) {
+	store, err := NewFilePublishedStore(filepath.Join(t.TempDir(), publishedLogFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checker := NewDuplicateChecker(store)
+
+	if _, err := checker.Reserve(models.AnimeNews{Title: "Timed out", Link: "https://example.com/timed-out"}, PublishDetails{}); err != nil {
+		t.Fatal(err)
+	}
+
+	record, err := checker.ResolveReservation("https://example.com/timed-out", true)
+	if err != nil || record.Title != "Timed out" {
+		t.Fatalf("ResolveReservation() = %+v, %v; want the reserved record", record, err)
+	}
+	if pending, _ := checker.PendingReservations(); len(pending) != 0 {
+		t.Errorf("pending after resolving = %+v; want none", pending)
+	}
+	if count, _ := store.Count(); count != 1 {
+		t.Errorf("Count() = %d; want the article in the history", count)
+	}
+}
+
+func TestBoltPublishedStore_ImportsLegacyLog(t *testing.T) {
+	dir := t.TempDir()
+	legacyPath := filepath.Join(dir, publishedLogFile)
+	legacy := strings.Join([]string{
+		"2025-09-01 08:00:00|https://example.com/old|Old post",
+		"not a date|https://example.com/undated|Still blocks reposts",
+		"",
+	}, "\n")
+	if err := os.WriteFile(legacyPath, []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	dbPath := filepath.Join(dir, boltPublishedDB)
+	store, err := NewBoltPublishedStore(dbPath, legacyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count, _ := store.Count(); count != 2 {
+		t.Errorf("Count() after import = %d; want 2", count)
+	}
+	if found, _ := store.Contains(models.PublishedArticle{Link: "https://example.com/undated"}); !found {
+		t.Error("entry with a malformed date was not imported")
+	}
+	store.Close()
+
+	// The import runs once; reopening must not duplicate the entries
+	store, err = NewBoltPublishedStore(dbPath, legacyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	if count, _ := store.Count(); count != 2 {
+		t.Errorf("Count() after reopening = %d; want 2", count)
+	}
+}
+
+func TestBoltPublishedStore_ImportSkipsDamagedLines(t *testing.T) {
+	dir := t.TempDir()
+	legacyPath := filepath.Join(dir, publishedLogFile)
+	legacy := "2025-09-01 08:00:00|https://example.com/old|Old post\n" +
+		`{"v":1,"link":"https://example.com/broken",` + "\n" +
+		`{"v":1,"link":"https://example.com/new","title":"New","published_at":"2025-10-01T12:00:00Z"}` + "\n" +
+		`{"v":1,"link":"https://example.com/torn","ti`
+	if err := os.WriteFile(legacyPath, []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := NewBoltPublishedStore(filepath.Join(dir, boltPublishedDB), legacyPath)
+	if err != nil {
+		t.Fatalf("NewBoltPublishedStore() error = %v; want damaged lines skipped", err)
+	}
+	defer store.Close()
+
+	if count, _ := store.Count(); count != 2 {
+		t.Errorf("Count() after import = %d; want 2", count)
+	}
+	for _, link := range []string{"https://example.com/old", "https://example.com/new"} {
+		if found, _ := store.Contains(models.PublishedArticle{Link: link}); !found {
+			t.Errorf("readable entry %s was not imported", link)
+		}
+	}
+}
+
+func TestDuplicateChecker_IsNewArticle(t *testing.T) {
+	store, err := NewFilePublishedStore(filepath.Join(t.TempDir(), publishedLogFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checker := NewDuplicateChecker(store)
+
+	posted := models.AnimeNews{
+		Title:          "Frieren season 2 announced",
+		Summary:        "Coming in January.",
+		Link:           "https://example.com/frieren",
+		GUID:           "frieren-2",
+		AlternateLinks: []string{"https://mirror.example.com/frieren"},
+	}
+	if err := checker.LogArticle(posted, PublishDetails{}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		article models.AnimeNews
+		isNew   bool
+	}{
+		{"same link", models.AnimeNews{Title: "Other", Link: posted.Link}, false},
+		{"alternate link", models.AnimeNews{Title: "Other", Link: "https://mirror.example.com/frieren"}, false},
+		{"tracking variant of the link", models.AnimeNews{Title: "Other", Link: "http://www.example.com/frieren/?utm_source=twitter"}, false},
+		{"same GUID", models.AnimeNews{Title: "Other", Link: "https://example.com/new", GUID: "frieren-2"}, false},
+		{"same content, new URL", models.AnimeNews{Title: "Frieren Season 2 announced!", Summary: "Coming in  January", Link: "https://example.com/new"}, false},
+		{"different story", models.AnimeNews{Title: "Dandadan season 2 announced", Link: "https://example.com/dandadan"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			isNew, err := checker.IsNewArticle(tt.article)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if isNew != tt.isNew {
+				t.Errorf("IsNewArticle() = %t; want %t", isNew, tt.isNew)
+			}
+		})
+	}
+}
+
+func TestDuplicateChecker_MatchesLegacyLinks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), publishedLogFile)
+	legacy := "2025-09-01 08:00:00|http://www.example.com/old/?utm_source=rss|Old post\n"
+	if err := os.WriteFile(path, []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := NewFilePublishedStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checker := NewDuplicateChecker(store)
+
+	// History written before canonicalization still blocks the exact link
+	isNew, err := checker.CheckIfPostedBefore("http://www.example.com/old/?utm_source=rss")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if isNew {
+		t.Error("CheckIfPostedBefore() = true for a link in the legacy log")
+	}
+
+	if err := checker.LogAsPublished("https://example.com/new?utm_medium=feed", "New post"); err != nil {
+		t.Fatal(err)
+	}
+	recent, _ := checker.GetRecentPublished(1)
+	if len(recent) != 1 || recent[0].Link != "https://example.com/new" {
+		t.Errorf("LogAsPublished recorded %+v; want the canonical link", recent)
+	}
+}
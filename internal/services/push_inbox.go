@@ -0,0 +1,155 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"go-test/internal/atomicfile"
+	"go-test/internal/filelock"
+	"go-test/internal/models"
+)
+
+const (
+	// pushInboxRetention is how long pushed items are kept when no recency window is set
+	pushInboxRetention = 7 * 24 * time.Hour
+	// pushInboxLockTimeout bounds the wait for another process using the inbox
+	pushInboxLockTimeout = 5 * time.Second
+)
+
+// PushInbox keeps the items delivered by push notifications on disk until
+// they fall out of the recency window. Every fetch reads them back and runs
+// them through the same recency, clustering and ordering steps as polled
+// items, so a push that arrives during a cycle, or a cycle that fails, loses
+// nothing; items that were already published are skipped by the duplicate
+// check like any repeated feed item.
+type PushInbox struct {
+	path     string
+	lockPath string
+
+	mu  sync.Mutex
+	now func() time.Time
+}
+
+// NewPushInbox creates an inbox stored at path
+func NewPushInbox(path string) *PushInbox {
+	return &PushInbox{
+		path:     path,
+		lockPath: path + ".lock",
+		now:      time.Now,
+	}
+}
+
+// Add stores items, replacing earlier copies of the same item
+func (p *PushInbox) Add(items []models.AnimeNews) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	return p.update(func(stored []models.AnimeNews) ([]models.AnimeNews, bool) {
+		index := make(map[string]int, len(stored))
+		for i, item := range stored {
+			index[pushInboxKey(item)] = i
+		}
+
+		for _, item := range items {
+			key := pushInboxKey(item)
+			if i, ok := index[key]; ok {
+				stored[i] = item
+				continue
+			}
+			index[key] = len(stored)
+			stored = append(stored, item)
+		}
+		return stored, true
+	})
+}
+
+// Items returns the stored items published at or after cutoff and forgets
+// the older ones. A zero cutoff keeps items for pushInboxRetention.
+func (p *PushInbox) Items(cutoff time.Time) ([]models.AnimeNews, error) {
+	if _, err := os.Stat(p.path); os.IsNotExist(err) {
+		return nil, nil
+	}
+	if cutoff.IsZero() {
+		cutoff = p.now().Add(-pushInboxRetention)
+	}
+
+	var recent []models.AnimeNews
+	err := p.update(func(stored []models.AnimeNews) ([]models.AnimeNews, bool) {
+		for _, item := range stored {
+			if !item.PublishedAt.Before(cutoff) {
+				recent = append(recent, item)
+			}
+		}
+		return recent, len(recent) != len(stored)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return recent, nil
+}
+
+// update applies fn to the stored items while holding the inbox lock, as
+// the app and CLI share the data directory. The items fn returns are saved
+// when it reports a change.
+func (p *PushInbox) update(fn func(stored []models.AnimeNews) ([]models.AnimeNews, bool)) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	lock, err := os.OpenFile(p.lockPath, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open push inbox lock: %w", err)
+	}
+	defer lock.Close()
+
+	if err := filelock.Lock(lock, pushInboxLockTimeout); err != nil {
+		if errors.Is(err, filelock.ErrLocked) {
+			return fmt.Errorf("push inbox is still locked by another process after %s: %w", pushInboxLockTimeout, err)
+		}
+		return fmt.Errorf("failed to lock push inbox: %w", err)
+	}
+	defer filelock.Unlock(lock)
+
+	var stored []models.AnimeNews
+	data, err := os.ReadFile(p.path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read push inbox: %w", err)
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &stored); err != nil {
+			return fmt.Errorf("failed to decode push inbox: %w", err)
+		}
+	}
+
+	updated, changed := fn(stored)
+	if !changed {
+		return nil
+	}
+
+	data, err = json.MarshalIndent(updated, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode push inbox: %w", err)
+	}
+
+	if err := atomicfile.WriteFile(p.path, data); err != nil {
+		return fmt.Errorf("failed to write push inbox: %w", err)
+	}
+
+	return nil
+}
+
+// pushInboxKey identifies an item across notifications
+func pushInboxKey(item models.AnimeNews) string {
+	if item.Link != "" {
+		return item.Link
+	}
+	if item.GUID != "" {
+		return item.Source + " " + item.GUID
+	}
+	return item.Source + " " + item.Title
+}
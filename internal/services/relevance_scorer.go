@@ -0,0 +1,142 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"go-test/internal/config"
+)
+
+// RelevanceScorer decides whether a feed item is anime news using weighted
+// positive and negative keywords
+type RelevanceScorer struct {
+	cfg      *config.RelevanceConfig
+	positive []weightedKeyword
+	negative []weightedKeyword
+}
+
+// KeywordMatch is a single keyword hit that contributed to a score
+type KeywordMatch struct {
+	Keyword string
+	Field   string
+	Points  float64
+}
+
+// RelevanceResult is the score of one item and why it passed or failed
+type RelevanceResult struct {
+	Score     float64
+	Threshold float64
+	Passed    bool
+	Matches   []KeywordMatch
+}
+
+// RelevanceDecision records the scoring result of a feed item
+type RelevanceDecision struct {
+	Title  string
+	Link   string
+	Result RelevanceResult
+}
+
+type weightedKeyword struct {
+	keyword string
+	weight  float64
+	pattern *regexp.Regexp
+}
+
+// NewRelevanceScorer creates a scorer from validated relevance settings
+func NewRelevanceScorer(cfg *config.RelevanceConfig) *RelevanceScorer {
+	return &RelevanceScorer{
+		cfg:      cfg,
+		positive: compileKeywords(cfg.Positive),
+		negative: compileKeywords(cfg.Negative),
+	}
+}
+
+// Score rates an item from source. feedKeywords are the feed's own keyword
+// overrides from the registry; they count as positive keywords.
+func (rs *RelevanceScorer) Score(title, body, source string, feedKeywords []string) RelevanceResult {
+	result := RelevanceResult{Threshold: rs.cfg.ThresholdFor(source)}
+
+	positive := rs.positive
+	if len(feedKeywords) > 0 {
+		extra := make(map[string]float64, len(feedKeywords))
+		for _, keyword := range feedKeywords {
+			extra[keyword] = rs.cfg.FeedKeywordWeight
+		}
+		positive = append(append([]weightedKeyword{}, rs.positive...), compileKeywords(extra)...)
+	}
+
+	fields := []struct {
+		name       string
+		text       string
+		multiplier float64
+	}{
+		{"title", title, rs.cfg.TitleMultiplier},
+		{"body", body, rs.cfg.BodyMultiplier},
+	}
+
+	for _, field := range fields {
+		for _, kw := range positive {
+			if kw.pattern.MatchString(field.text) {
+				result.add(kw.keyword, field.name, kw.weight*field.multiplier)
+			}
+		}
+		for _, kw := range rs.negative {
+			if kw.pattern.MatchString(field.text) {
+				result.add(kw.keyword, field.name, -kw.weight*field.multiplier)
+			}
+		}
+	}
+
+	result.Passed = result.Score >= result.Threshold
+	return result
+}
+
+func (r *RelevanceResult) add(keyword, field string, points float64) {
+	r.Score += points
+	r.Matches = append(r.Matches, KeywordMatch{Keyword: keyword, Field: field, Points: points})
+}
+
+// Explain describes the score in one line, e.g.
+// `passed: score 7.0 >= 3.0 (+6.0 title "anime", +1.0 body "season")`
+func (r RelevanceResult) Explain() string {
+	verdict, comparison := "failed", "<"
+	if r.Passed {
+		verdict, comparison = "passed", ">="
+	}
+
+	if len(r.Matches) == 0 {
+		return fmt.Sprintf("%s: score 0.0 %s %.1f (no keywords matched)", verdict, comparison, r.Threshold)
+	}
+
+	parts := make([]string, 0, len(r.Matches))
+	for _, match := range r.Matches {
+		parts = append(parts, fmt.Sprintf("%+.1f %s %q", match.Points, match.Field, match.Keyword))
+	}
+
+	return fmt.Sprintf("%s: score %.1f %s %.1f (%s)", verdict, r.Score, comparison, r.Threshold, strings.Join(parts, ", "))
+}
+
+// compileKeywords builds whole-word, case-insensitive matchers in a stable order
+func compileKeywords(weights map[string]float64) []weightedKeyword {
+	keywords := make([]weightedKeyword, 0, len(weights))
+	for keyword, weight := range weights {
+		keyword = strings.ToLower(strings.TrimSpace(keyword))
+		if keyword == "" {
+			continue
+		}
+		keywords = append(keywords, weightedKeyword{
+			keyword: keyword,
+			weight:  weight,
+			pattern: regexp.MustCompile(`(?i)\b` + regexp.QuoteMeta(keyword) + `\b`),
+		})
+	}
+
+	sort.Slice(keywords, func(i, j int) bool {
+		return keywords[i].keyword < keywords[j].keyword
+	})
+
+	return keywords
+}
@@ -0,0 +1,78 @@
+package services
+
+import (
+	"testing"
+
+	"go-test/internal/config"
+)
+
+func newTestScorer() *RelevanceScorer {
+	return NewRelevanceScorer(&config.RelevanceConfig{
+		Threshold:         3,
+		TitleMultiplier:   2,
+		BodyMultiplier:    1,
+		FeedKeywordWeight: 3,
+		Positive:          map[string]float64{"anime": 3, "season": 0.5, "studio": 0.5, "one piece": 3},
+		Negative:          map[string]float64{"nfl": 5, "football": 3},
+		SourceThresholds:  map[string]float64{"Anime News Network": 1},
+	})
+}
+
+func TestRelevanceScorer_Score(t *testing.T) {
+	tests := []struct {
+		name         string
+		title        string
+		body         string
+		source       string
+		feedKeywords []string
+		wantScore    float64
+		wantPassed   bool
+	}{
+		{"anime in title", "New anime announced", "", "Otaku News", nil, 6, true},
+		{"anime in body only", "Big announcement", "The anime airs in July.", "Otaku News", nil, 3, true},
+		{"generic words only", "New season for the studio", "", "Otaku News", nil, 2, false},
+		{"sports season", "NFL season kicks off", "Football is back, and the studio show returns.", "Otaku News", nil, -11.5, false},
+		{"whole words only", "Seasoning tips from a studious chef", "", "Otaku News", nil, 0, false},
+		{"multi-word keyword", "One Piece chapter delayed", "", "Otaku News", nil, 6, true},
+		{"lower source threshold", "New season starts", "", "Anime News Network", nil, 1, true},
+		{"feed keyword override", "Gundam model kit released", "", "Otaku News", []string{"gundam"}, 6, true},
+	}
+
+	scorer := newTestScorer()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := scorer.Score(tt.title, tt.body, tt.source, tt.feedKeywords)
+
+			if result.Score != tt.wantScore {
+				t.Errorf("Score() score = %.2f; want %.2f (%s)", result.Score, tt.wantScore, result.Explain())
+			}
+
+			if result.Passed != tt.wantPassed {
+				t.Errorf("Score() passed = %t; want %t (%s)", result.Passed, tt.wantPassed, result.Explain())
+			}
+		})
+	}
+}
+
+func TestRelevanceResult_Explain(t *testing.T) {
+	scorer := newTestScorer()
+
+	tests := []struct {
+		name     string
+		title    string
+		body     string
+		expected string
+	}{
+		{"passed", "New anime", "A new season.", `passed: score 6.5 >= 3.0 (+6.0 title "anime", +0.5 body "season")`},
+		{"failed", "NFL news", "", `failed: score -10.0 < 3.0 (-10.0 title "nfl")`},
+		{"no matches", "Weather report", "", "failed: score 0.0 < 3.0 (no keywords matched)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := scorer.Score(tt.title, tt.body, "Otaku News", nil).Explain(); result != tt.expected {
+				t.Errorf("Explain() = %q; want %q", result, tt.expected)
+			}
+		})
+	}
+}
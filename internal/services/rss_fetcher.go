@@ -1,108 +1,515 @@
 package services
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"html"
+	"io"
 	"log"
+	"net/http"
+	"regexp"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
+	"go-test/internal/config"
 	"go-test/internal/models"
 
 	"github.com/mmcdole/gofeed"
 )
 
+// titleMarkupPattern matches the inline tags feeds sometimes leave in titles
+var titleMarkupPattern = regexp.MustCompile(`(?i)<\s*/?\s*(a|b|i|em|strong|span|br|p|div|img)\b[^>]*>`)
+
+const (
+	feedUserAgent = "AnimeNewsAI/1.0"
+	maxFeedSize   = 10 << 20 // 10MB
+)
+
 // RSSFetcher handles fetching anime news from RSS feeds
 type RSSFetcher struct {
-	parser *gofeed.Parser
-	feeds  []string
+	feeds         []config.FeedConfig
+	maxConcurrent int
+	feedTimeout   time.Duration
+	httpClient    *http.Client
+	cache         *FeedCache
+	health        *FeedHealthTracker
+	scorer        *RelevanceScorer
+	clusterer     *storyClusterer
+	push          PushSource
+	inbox         *PushInbox
+	priorities    map[string]float64 // feed weight by source name
+	maxItems      int
+	maxAge        time.Duration
+	seen          *SeenItemsIndex
+	now           func() time.Time
+}
+
+// PushSource reports feeds whose updates currently arrive by push, so
+// polling them would only repeat what was already delivered
+type PushSource interface {
+	IsActive(feedURL string) bool
+}
+
+// FeedResult describes the outcome of fetching a single feed
+type FeedResult struct {
+	FeedURL     string
+	Source      string
+	Items       int
+	Latency     time.Duration
+	NotModified bool
+	Skipped     bool
+	Pushed      bool
+	Err         error
+	Decisions   []RelevanceDecision
+}
+
+// Rejected returns the relevance decisions of items that were filtered out
+func (r FeedResult) Rejected() []RelevanceDecision {
+	var rejected []RelevanceDecision
+	for _, decision := range r.Decisions {
+		if !decision.Result.Passed {
+			rejected = append(rejected, decision)
+		}
+	}
+	return rejected
+}
+
+// FetchReport collects the per-feed outcomes of one fetch run
+type FetchReport struct {
+	Results []FeedResult
+
+	// TooOld is the number of items dropped for being older than the recency window
+	TooOld int
+
+	// Clustered is the number of items folded into another feed's copy of the same story
+	Clustered int
+
+	// Pushed is the number of items read back from the push inbox
+	Pushed int
+}
+
+// Failed returns the results of feeds that could not be fetched
+func (r *FetchReport) Failed() []FeedResult {
+	var failed []FeedResult
+	for _, result := range r.Results {
+		if result.Err != nil {
+			failed = append(failed, result)
+		}
+	}
+	return failed
 }
 
-// NewRSSFetcher creates a new RSS fetcher instance
-func NewRSSFetcher() *RSSFetcher {
+// NewRSSFetcher creates a new RSS fetcher from the feed registry file
+func NewRSSFetcher(cfg *config.Config) (*RSSFetcher, error) {
+	registry, err := config.LoadFeedRegistry(cfg.FeedsFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var cache *FeedCache
+	if cfg.FeedCacheDir != "" {
+		cache = NewFeedCache(cfg.FeedCacheDir)
+	}
+
+	relevance, err := config.LoadRelevanceConfig(cfg.RelevanceFile)
+	if err != nil {
+		return nil, err
+	}
+
+	health, err := NewFeedHealthTracker(cfg.FeedHealthFile, cfg.FeedFailureThreshold, cfg.FeedBackoff, cfg.FeedMaxBackoff)
+	if err != nil {
+		return nil, err
+	}
+
+	seen, err := NewSeenItemsIndex(cfg.SeenItemsFile)
+	if err != nil {
+		return nil, err
+	}
+
+	feeds := registry.EnabledFeeds()
+	priorities := make(map[string]float64, len(feeds))
+	for _, feed := range feeds {
+		priorities[feed.Name] = feed.Weight
+	}
+
+	var inbox *PushInbox
+	if cfg.PushInboxFile != "" {
+		inbox = NewPushInbox(cfg.PushInboxFile)
+	}
+
 	return &RSSFetcher{
-		parser: gofeed.NewParser(),
-		feeds: []string{
-			"https://www.animenewsnetwork.com/all/rss.xml",
-			"https://feeds.crunchyroll.com/news.rss",
-			"https://myanimelist.net/rss/news.xml",
-			"https://www.otakunews.com/feed/",
-			"https://animehunch.com/feed/",
+		feeds:         feeds,
+		maxConcurrent: cfg.FeedConcurrency,
+		feedTimeout:   cfg.FeedTimeout,
+		httpClient:    &http.Client{},
+		cache:         cache,
+		health:        health,
+		scorer:        NewRelevanceScorer(relevance),
+		clusterer: &storyClusterer{
+			threshold:  cfg.ClusterSimilarity,
+			window:     cfg.ClusterWindow,
+			priorities: priorities,
 		},
-	}
+		priorities: priorities,
+		maxItems:   cfg.FeedMaxItems,
+		maxAge:     cfg.FeedMaxAge,
+		seen:       seen,
+		inbox:      inbox,
+		now:        time.Now,
+	}, nil
+}
+
+// SetTransport replaces the transport used to download feeds
+func (rf *RSSFetcher) SetTransport(rt http.RoundTripper) {
+	rf.httpClient.Transport = rt
 }
 
-// FetchAnimeNews fetches latest anime news from RSS feeds
-func (rf *RSSFetcher) FetchAnimeNews(ctx context.Context) ([]models.AnimeNews, error) {
+// FetchAnimeNews fetches latest anime news from all RSS feeds concurrently.
+// Every feed gets its own deadline derived from ctx, and a slow or failing
+// feed never blocks the others. The returned report lists the outcome of
+// each feed in configuration order. Feeds whose circuit breaker is open
+// are skipped until their back-off window has passed, and feeds with an
+// active push subscription are not polled at all; their items come from the
+// push inbox instead and go through the same recency, clustering and
+// ordering steps.
+func (rf *RSSFetcher) FetchAnimeNews(ctx context.Context) ([]models.AnimeNews, *FetchReport, error) {
+	type feedOutcome struct {
+		index  int
+		news   []models.AnimeNews
+		result FeedResult
+	}
+
+	limit := rf.maxConcurrent
+	if limit <= 0 {
+		limit = 1
+	}
+
+	outcomes := make(chan feedOutcome)
+	sem := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+
+	for i, feed := range rf.feeds {
+		wg.Add(1)
+		go func(index int, feed config.FeedConfig) {
+			defer wg.Done()
+
+			if rf.push != nil && rf.push.IsActive(feed.URL) {
+				outcomes <- feedOutcome{index: index, result: FeedResult{
+					FeedURL: feed.URL,
+					Source:  feed.Name,
+					Pushed:  true,
+				}}
+				return
+			}
+
+			if rf.health != nil && !rf.health.Allow(feed.URL) {
+				outcomes <- feedOutcome{index: index, result: FeedResult{
+					FeedURL: feed.URL,
+					Source:  feed.Name,
+					Skipped: true,
+				}}
+				return
+			}
+
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				outcomes <- feedOutcome{index: index, result: FeedResult{
+					FeedURL: feed.URL,
+					Source:  feed.Name,
+					Err:     ctx.Err(),
+				}}
+				return
+			}
+
+			news, result := rf.fetchWithDeadline(ctx, feed)
+			rf.recordHealth(ctx, feed, result)
+			outcomes <- feedOutcome{index: index, news: news, result: result}
+		}(i, feed)
+	}
+
+	go func() {
+		wg.Wait()
+		close(outcomes)
+	}()
+
+	// Collect outcomes by feed position as each feed finishes, then merge
+	// them in registry order so the result does not depend on network timing
+	collected := make([]feedOutcome, len(rf.feeds))
+	for outcome := range outcomes {
+		collected[outcome.index] = outcome
+	}
+
 	var allNews []models.AnimeNews
+	report := &FetchReport{Results: make([]FeedResult, len(rf.feeds))}
+	for i, outcome := range collected {
+		report.Results[i] = outcome.result
+		if outcome.result.Err != nil {
+			log.Printf("Error fetching from %s: %v", outcome.result.FeedURL, outcome.result.Err)
+			continue // Continue with other feeds even if one fails
+		}
+		allNews = append(allNews, outcome.news...)
+	}
 
-	for _, feedURL := range rf.feeds {
-		select {
-		case <-ctx.Done():
-			return nil, ctx.Err()
-		default:
+	if rf.health != nil {
+		if err := rf.health.Save(); err != nil {
+			log.Printf("Failed to save feed health: %v", err)
 		}
+	}
 
-		news, err := rf.fetchFromFeed(ctx, feedURL)
-		if err != nil {
-			log.Printf("Error fetching from %s: %v", feedURL, err)
-			continue // Continue with other feeds even if one fails
+	if rf.seen != nil {
+		if err := rf.seen.Save(); err != nil {
+			log.Printf("Failed to save seen items: %v", err)
 		}
+	}
 
-		allNews = append(allNews, news...)
+	if err := ctx.Err(); err != nil {
+		return nil, report, err
+	}
+
+	var cutoff time.Time
+	if rf.maxAge > 0 {
+		cutoff = rf.clock().Add(-rf.maxAge)
+	}
+
+	// Add the items delivered by push that polling did not return as well
+	if rf.inbox != nil {
+		pushed, err := rf.inbox.Items(cutoff)
+		if err != nil {
+			log.Printf("Failed to read push inbox: %v", err)
+		}
+		before := len(allNews)
+		allNews = mergePushed(allNews, pushed)
+		report.Pushed = len(allNews) - before
 	}
 
-	// Sort by published date (newest first)
-	for i := 0; i < len(allNews)-1; i++ {
-		for j := i + 1; j < len(allNews); j++ {
-			if allNews[i].PublishedAt.Before(allNews[j].PublishedAt) {
-				allNews[i], allNews[j] = allNews[j], allNews[i]
+	// Drop items older than the recency window
+	if rf.maxAge > 0 {
+		recent := allNews[:0]
+		for _, item := range allNews {
+			if !item.PublishedAt.Before(cutoff) {
+				recent = append(recent, item)
 			}
 		}
+		report.TooOld = len(allNews) - len(recent)
+		allNews = recent
 	}
 
-	// Return top 15 most recent
-	if len(allNews) > 15 {
-		allNews = allNews[:15]
+	// Fold copies of the same story from different feeds into one item
+	if rf.clusterer != nil {
+		before := len(allNews)
+		allNews = rf.clusterer.cluster(allNews)
+		report.Clustered = before - len(allNews)
 	}
 
-	return allNews, nil
+	rf.sortNews(allNews)
+
+	if rf.maxItems > 0 && len(allNews) > rf.maxItems {
+		allNews = allNews[:rf.maxItems]
+	}
+
+	return allNews, report, nil
 }
 
-func (rf *RSSFetcher) fetchFromFeed(ctx context.Context, feedURL string) ([]models.AnimeNews, error) {
-	feed, err := rf.parser.ParseURLWithContext(feedURL, ctx)
+// mergePushed appends the pushed items whose link is not among the polled ones
+func mergePushed(polled, pushed []models.AnimeNews) []models.AnimeNews {
+	links := make(map[string]bool, len(polled))
+	for _, item := range polled {
+		links[item.Link] = true
+	}
+
+	for _, item := range pushed {
+		if item.Link != "" && links[item.Link] {
+			continue
+		}
+		polled = append(polled, item)
+	}
+	return polled
+}
+
+// sortNews orders items newest first. Items published at the same time are
+// ordered by source priority and then by relevance score. The sort is
+// stable, so remaining ties keep the order of news, which FetchAnimeNews
+// builds in registry order.
+func (rf *RSSFetcher) sortNews(news []models.AnimeNews) {
+	sort.SliceStable(news, func(i, j int) bool {
+		a, b := news[i], news[j]
+		if !a.PublishedAt.Equal(b.PublishedAt) {
+			return a.PublishedAt.After(b.PublishedAt)
+		}
+		if pa, pb := rf.priorities[a.Source], rf.priorities[b.Source]; pa != pb {
+			return pa > pb
+		}
+		return a.Score > b.Score
+	})
+}
+
+func (rf *RSSFetcher) clock() time.Time {
+	if rf.now != nil {
+		return rf.now()
+	}
+	return time.Now()
+}
+
+// recordHealth updates the circuit breaker with the outcome of a fetch.
+// Failures caused by the whole cycle being cancelled are not the feed's fault.
+func (rf *RSSFetcher) recordHealth(ctx context.Context, feed config.FeedConfig, result FeedResult) {
+	if rf.health == nil || ctx.Err() != nil {
+		return
+	}
+
+	if result.Err != nil {
+		rf.health.RecordFailure(feed.URL, feed.Name, result.Err)
+	} else {
+		rf.health.RecordSuccess(feed.URL, feed.Name)
+	}
+}
+
+// SetPushSource stops polling feeds while push delivers their updates
+func (rf *RSSFetcher) SetPushSource(push PushSource) {
+	rf.push = push
+}
+
+// Feeds returns the enabled feeds from the registry
+func (rf *RSSFetcher) Feeds() []config.FeedConfig {
+	return append([]config.FeedConfig(nil), rf.feeds...)
+}
+
+// FeedHealth returns the health state of every configured feed
+func (rf *RSSFetcher) FeedHealth() []FeedHealth {
+	states := make([]FeedHealth, 0, len(rf.feeds))
+	for _, feed := range rf.feeds {
+		health := FeedHealth{URL: feed.URL}
+		if rf.health != nil {
+			health = rf.health.Get(feed.URL)
+		}
+		health.Source = feed.Name
+		states = append(states, health)
+	}
+	return states
+}
+
+// fetchWithDeadline fetches a single feed under its own timeout
+func (rf *RSSFetcher) fetchWithDeadline(ctx context.Context, feed config.FeedConfig) ([]models.AnimeNews, FeedResult) {
+	result := FeedResult{
+		FeedURL: feed.URL,
+		Source:  feed.Name,
+	}
+
+	feedCtx := ctx
+	if rf.feedTimeout > 0 {
+		var cancel context.CancelFunc
+		feedCtx, cancel = context.WithTimeout(ctx, rf.feedTimeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	news, err := rf.fetchFromFeed(feedCtx, feed, &result)
+	result.Latency = time.Since(start)
+	result.Items = len(news)
+	result.Err = err
+
+	return news, result
+}
+
+// fetchFromFeed downloads and parses one feed, recording cache use in result
+func (rf *RSSFetcher) fetchFromFeed(ctx context.Context, feed config.FeedConfig, result *FeedResult) ([]models.AnimeNews, error) {
+	body, notModified, err := rf.downloadFeed(ctx, feed.URL)
+	if err != nil {
+		return nil, err
+	}
+	result.NotModified = notModified
+
+	return rf.parseFeed(feed, body, result)
+}
+
+// ReceivePush parses a feed document delivered by push and keeps its items
+// in the push inbox, from where every following fetch picks them up
+func (rf *RSSFetcher) ReceivePush(feed config.FeedConfig, body []byte) ([]models.AnimeNews, FeedResult, error) {
+	news, result, err := rf.ParseFeed(feed, body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse feed %s: %w", feedURL, err)
+		return nil, result, err
+	}
+
+	if rf.inbox == nil {
+		return news, result, errors.New("no push inbox configured")
+	}
+	if err := rf.inbox.Add(news); err != nil {
+		return news, result, fmt.Errorf("failed to store pushed items: %w", err)
+	}
+
+	return news, result, nil
+}
+
+// ParseFeed turns a feed document delivered outside of polling, such as a
+// WebSub notification, into news items with the same cleaning, relevance
+// filtering and media extraction as fetched feeds
+func (rf *RSSFetcher) ParseFeed(feed config.FeedConfig, body []byte) ([]models.AnimeNews, FeedResult, error) {
+	result := FeedResult{
+		FeedURL: feed.URL,
+		Source:  feed.Name,
+	}
+
+	news, err := rf.parseFeed(feed, body, &result)
+	result.Items = len(news)
+	result.Err = err
+
+	return news, result, err
+}
+
+// parseFeed parses a feed body, recording relevance decisions in result
+func (rf *RSSFetcher) parseFeed(feed config.FeedConfig, body []byte, result *FeedResult) ([]models.AnimeNews, error) {
+	// gofeed parsers keep internal state, so each fetch gets its own
+	parsed, err := gofeed.NewParser().Parse(bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse feed %s: %w", feed.URL, err)
 	}
 
 	var news []models.AnimeNews
 
-	for _, item := range feed.Items {
+	for _, item := range parsed.Items {
 		if item == nil {
 			continue
 		}
 
-		// Filter for anime-related content
-		if !rf.isAnimeRelated(item.Title, item.Description) {
-			continue
-		}
+		title := cleanTitle(item.Title)
 
-		publishedAt := time.Now()
-		if item.PublishedParsed != nil {
-			publishedAt = *item.PublishedParsed
+		var description SanitizedHTML
+		if item.Description != "" {
+			description = SanitizeHTML(item.Description, item.Link)
 		}
 
-		description := ""
-		if item.Description != "" {
-			description = rf.cleanHTML(item.Description)
+		// Filter for anime-related content
+		var score float64
+		if rf.scorer != nil {
+			relevance := rf.scorer.Score(title, description.Text, feed.Name, feed.Keywords)
+			result.Decisions = append(result.Decisions, RelevanceDecision{
+				Title:  title,
+				Link:   item.Link,
+				Result: relevance,
+			})
+			if !relevance.Passed {
+				continue
+			}
+			score = relevance.Score
 		}
 
+		publishedAt := rf.publishedAt(feed, item)
+
 		newsItem := models.AnimeNews{
-			Title:       rf.cleanHTML(item.Title),
-			Summary:     description,
+			Title:       title,
+			Summary:     description.Text,
 			Link:        item.Link,
-			Source:      rf.extractSourceName(feedURL),
+			GUID:        item.GUID,
+			Source:      feed.Name,
 			PublishedAt: publishedAt,
+			Media:       extractMedia(item, description.Images),
+			Score:       score,
 		}
 
 		news = append(news, newsItem)
@@ -111,66 +518,106 @@ func (rf *RSSFetcher) fetchFromFeed(ctx context.Context, feedURL string) ([]mode
 	return news, nil
 }
 
-func (rf *RSSFetcher) isAnimeRelated(title, description string) bool {
-	content := strings.ToLower(title + " " + description)
+// publishedAt returns the item's publication date, falling back to its
+// update date and then to when the item was first seen. Using the first-seen
+// time keeps undated items from looking new on every fetch.
+func (rf *RSSFetcher) publishedAt(feed config.FeedConfig, item *gofeed.Item) time.Time {
+	if item.PublishedParsed != nil {
+		return *item.PublishedParsed
+	}
+	if item.UpdatedParsed != nil {
+		return *item.UpdatedParsed
+	}
+	if rf.seen == nil {
+		return rf.clock()
+	}
 
-	// Check for anime-related keywords
-	animeKeywords := []string{
-		"anime", "manga", "otaku", "cosplay", "convention",
-		"studio", "season", "episode", "character", "series",
-		"crunchyroll", "funimation", "viz", "shonen", "seinen",
-		"shoujo", "josei", "mecha", "isekai", "slice of life",
-		"romance", "action", "adventure", "fantasy", "sci-fi",
-		"dragon ball", "naruto", "one piece", "attack on titan",
-		"demon slayer", "my hero academia", "jujutsu kaisen",
+	key := item.GUID
+	if key == "" {
+		key = item.Link
+	}
+	if key == "" {
+		key = item.Title
 	}
+	return rf.seen.FirstSeen(feed.URL + " " + key)
+}
 
-	for _, keyword := range animeKeywords {
-		if strings.Contains(content, keyword) {
-			return true
+// downloadFeed fetches the raw feed body, using a conditional GET when a
+// cached copy exists. The returned flag is true when the server answered
+// 304 Not Modified and the cached body was reused.
+func (rf *RSSFetcher) downloadFeed(ctx context.Context, feedURL string) ([]byte, bool, error) {
+	var cached *CachedFeed
+	if rf.cache != nil {
+		entry, err := rf.cache.Get(feedURL)
+		if err != nil {
+			log.Printf("Ignoring feed cache for %s: %v", feedURL, err)
 		}
+		cached = entry
 	}
 
-	return false
-}
-
-func (rf *RSSFetcher) cleanHTML(content string) string {
-	// Simple HTML tag removal
-	content = strings.ReplaceAll(content, "<br>", " ")
-	content = strings.ReplaceAll(content, "<br/>", " ")
-	content = strings.ReplaceAll(content, "<br />", " ")
-	content = strings.ReplaceAll(content, "</p>", " ")
-	content = strings.ReplaceAll(content, "<p>", "")
+	req, err := http.NewRequestWithContext(ctx, "GET", feedURL, nil)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create feed request: %w", err)
+	}
 
-	// Remove common HTML tags
-	tags := []string{"<b>", "</b>", "<i>", "</i>", "<strong>", "</strong>",
-		"<em>", "</em>", "<u>", "</u>", "<div>", "</div>", "<span>", "</span>"}
+	req.Header.Set("User-Agent", feedUserAgent)
+	if cached != nil {
+		if cached.ETag != "" {
+			req.Header.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
 
-	for _, tag := range tags {
-		content = strings.ReplaceAll(content, tag, "")
+	client := rf.httpClient
+	if client == nil {
+		client = http.DefaultClient
 	}
 
-	// Clean up extra whitespace
-	content = strings.TrimSpace(content)
-	words := strings.Fields(content)
-	return strings.Join(words, " ")
-}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to fetch feed %s: %w", feedURL, err)
+	}
+	defer resp.Body.Close()
 
-func (rf *RSSFetcher) extractSourceName(feedURL string) string {
-	if strings.Contains(feedURL, "animenewsnetwork") {
-		return "Anime News Network"
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		return cached.Body, true, nil
 	}
-	if strings.Contains(feedURL, "crunchyroll") {
-		return "Crunchyroll"
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, false, fmt.Errorf("feed %s returned status: %d", feedURL, resp.StatusCode)
 	}
-	if strings.Contains(feedURL, "myanimelist") {
-		return "MyAnimeList"
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxFeedSize))
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read feed %s: %w", feedURL, err)
 	}
-	if strings.Contains(feedURL, "otakunews") {
-		return "Otaku News"
+
+	etag := resp.Header.Get("ETag")
+	lastModified := resp.Header.Get("Last-Modified")
+	if rf.cache != nil && (etag != "" || lastModified != "") {
+		entry := &CachedFeed{
+			URL:          feedURL,
+			ETag:         etag,
+			LastModified: lastModified,
+			FetchedAt:    time.Now(),
+			Body:         body,
+		}
+		if err := rf.cache.Put(entry); err != nil {
+			log.Printf("Failed to cache feed %s: %v", feedURL, err)
+		}
 	}
-	if strings.Contains(feedURL, "animehunch") {
-		return "Anime Hunch"
+
+	return body, false, nil
+}
+
+// cleanTitle decodes entities in a feed item title. Titles are plain text
+// more often than not, so they only go through the HTML sanitizer when
+// they actually contain markup; otherwise "<Oshi no Ko>" would be eaten as a tag.
+func cleanTitle(title string) string {
+	if titleMarkupPattern.MatchString(title) {
+		return strings.Join(strings.Fields(SanitizeHTML(title, "").Text), " ")
 	}
-	return "Unknown Source"
+	return strings.Join(strings.Fields(html.UnescapeString(title)), " ")
 }
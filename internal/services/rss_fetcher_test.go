@@ -0,0 +1,355 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"go-test/internal/config"
+	"go-test/internal/models"
+)
+
+const testFeedTemplate = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0">
+<channel>
+<title>Test Feed</title>
+<item>
+<title>%s anime season announced</title>
+<link>https://example.com/%s</link>
+<description>A new anime season is coming.</description>
+<pubDate>Mon, 02 Jan 2006 15:04:05 GMT</pubDate>
+</item>
+</channel>
+</rss>`
+
+func TestRSSFetcher_FetchAnimeNews(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/fast", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, testFeedTemplate, "Fast", "fast")
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(2 * time.Second):
+		case <-r.Context().Done():
+		}
+	})
+	mux.HandleFunc("/broken", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "boom", http.StatusInternalServerError)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	fetcher := &RSSFetcher{
+		feeds: []config.FeedConfig{
+			{URL: server.URL + "/slow", Name: "Slow"},
+			{URL: server.URL + "/fast", Name: "Fast"},
+			{URL: server.URL + "/broken", Name: "Broken"},
+		},
+		maxConcurrent: 2,
+		feedTimeout:   200 * time.Millisecond,
+	}
+
+	start := time.Now()
+	news, report, err := fetcher.FetchAnimeNews(context.Background())
+	if err != nil {
+		t.Fatalf("FetchAnimeNews() error = %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("FetchAnimeNews() took %s; slow feed was not cut off", elapsed)
+	}
+
+	if len(news) != 1 || news[0].Link != "https://example.com/fast" {
+		t.Fatalf("FetchAnimeNews() news = %+v; want only the fast feed item", news)
+	}
+
+	if len(report.Results) != 3 {
+		t.Fatalf("report has %d results; want 3", len(report.Results))
+	}
+
+	tests := []struct {
+		name      string
+		index     int
+		wantErr   bool
+		wantItems int
+	}{
+		{"slow feed times out", 0, true, 0},
+		{"fast feed succeeds", 1, false, 1},
+		{"broken feed fails", 2, true, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := report.Results[tt.index]
+			if (result.Err != nil) != tt.wantErr {
+				t.Errorf("result.Err = %v; wantErr %t", result.Err, tt.wantErr)
+			}
+			if result.Items != tt.wantItems {
+				t.Errorf("result.Items = %d; want %d", result.Items, tt.wantItems)
+			}
+		})
+	}
+
+	if failed := report.Failed(); len(failed) != 2 {
+		t.Errorf("report.Failed() = %d results; want 2", len(failed))
+	}
+}
+
+func TestRSSFetcher_ConditionalGet(t *testing.T) {
+	var fullResponses, conditionalRequests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			conditionalRequests++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		fullResponses++
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+		fmt.Fprintf(w, testFeedTemplate, "Cached", "cached")
+	}))
+	defer server.Close()
+
+	fetcher := &RSSFetcher{
+		feeds:         []config.FeedConfig{{URL: server.URL, Name: "Cached"}},
+		maxConcurrent: 1,
+		httpClient:    server.Client(),
+		cache:         NewFeedCache(t.TempDir()),
+	}
+
+	for i, wantNotModified := range []bool{false, true, true} {
+		news, report, err := fetcher.FetchAnimeNews(context.Background())
+		if err != nil {
+			t.Fatalf("fetch %d: FetchAnimeNews() error = %v", i, err)
+		}
+
+		if len(news) != 1 || news[0].Link != "https://example.com/cached" {
+			t.Errorf("fetch %d: news = %+v; want the cached item", i, news)
+		}
+
+		if got := report.Results[0].NotModified; got != wantNotModified {
+			t.Errorf("fetch %d: NotModified = %t; want %t", i, got, wantNotModified)
+		}
+	}
+
+	if fullResponses != 1 || conditionalRequests != 2 {
+		t.Errorf("server saw %d full and %d conditional requests; want 1 and 2", fullResponses, conditionalRequests)
+	}
+}
+
+func TestRSSFetcher_MergesInRegistryOrder(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/late", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		fmt.Fprintf(w, testFeedTemplate, "Late", "late")
+	})
+	mux.HandleFunc("/early", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, testFeedTemplate, "Early", "early")
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	// Both items share a date and priority, so only registry order decides
+	fetcher := &RSSFetcher{
+		feeds: []config.FeedConfig{
+			{URL: server.URL + "/late", Name: "Late"},
+			{URL: server.URL + "/early", Name: "Early"},
+		},
+		maxConcurrent: 2,
+	}
+
+	news, _, err := fetcher.FetchAnimeNews(context.Background())
+	if err != nil {
+		t.Fatalf("FetchAnimeNews() error = %v", err)
+	}
+	if len(news) != 2 || news[0].Link != "https://example.com/late" || news[1].Link != "https://example.com/early" {
+		t.Errorf("FetchAnimeNews() news = %+v; want the late feed's item first, as in the registry", news)
+	}
+}
+
+type stubPushSource map[string]bool
+
+func (s stubPushSource) IsActive(feedURL string) bool { return s[feedURL] }
+
+func TestRSSFetcher_SkipsPushedFeeds(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		fmt.Fprintf(w, testFeedTemplate, "Polled", "polled")
+	}))
+	defer server.Close()
+
+	fetcher := &RSSFetcher{
+		feeds: []config.FeedConfig{
+			{URL: server.URL + "/pushed", Name: "Pushed"},
+			{URL: server.URL + "/polled", Name: "Polled"},
+		},
+		maxConcurrent: 1,
+	}
+	fetcher.SetPushSource(stubPushSource{server.URL + "/pushed": true})
+
+	news, report, err := fetcher.FetchAnimeNews(context.Background())
+	if err != nil {
+		t.Fatalf("FetchAnimeNews() error = %v", err)
+	}
+
+	if requests != 1 || len(news) != 1 {
+		t.Errorf("FetchAnimeNews() made %d requests and returned %d items; want only the polled feed", requests, len(news))
+	}
+	if !report.Results[0].Pushed || report.Results[1].Pushed {
+		t.Errorf("report = %+v; want only the first feed marked as pushed", report.Results)
+	}
+}
+
+func TestRSSFetcher_ReadsPushInbox(t *testing.T) {
+	now := time.Date(2025, 10, 1, 12, 0, 0, 0, time.UTC)
+	item := func(slug string, age time.Duration) string {
+		return fmt.Sprintf("<item><title>%s anime news</title><link>https://example.com/%s</link><pubDate>%s</pubDate></item>",
+			slug, slug, now.Add(-age).Format(time.RFC1123Z))
+	}
+	rss := func(items ...string) []byte {
+		return []byte(`<?xml version="1.0"?><rss version="2.0"><channel><title>Feed</title>` + strings.Join(items, "") + `</channel></rss>`)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(rss(item("polled", 2*time.Hour)))
+	}))
+	defer server.Close()
+
+	inbox := NewPushInbox(filepath.Join(t.TempDir(), "push_inbox.json"))
+	inbox.now = func() time.Time { return now }
+
+	pushedFeed := config.FeedConfig{URL: server.URL + "/pushed", Name: "Pushed"}
+	fetcher := &RSSFetcher{
+		feeds:         []config.FeedConfig{pushedFeed, {URL: server.URL + "/polled", Name: "Polled"}},
+		maxConcurrent: 1,
+		maxAge:        72 * time.Hour,
+		inbox:         inbox,
+		now:           func() time.Time { return now },
+	}
+	fetcher.SetPushSource(stubPushSource{pushedFeed.URL: true})
+
+	// The notification repeats an item polling also returns and one that is too old
+	pushed, _, err := fetcher.ReceivePush(pushedFeed, rss(item("fresh", time.Hour), item("old", 10*24*time.Hour), item("polled", 2*time.Hour)))
+	if err != nil || len(pushed) != 3 {
+		t.Fatalf("ReceivePush() = %d items, %v; want 3 items", len(pushed), err)
+	}
+
+	// Pushed items stay in the inbox, so a cycle that fails to publish them can try again
+	for i := 0; i < 2; i++ {
+		news, report, err := fetcher.FetchAnimeNews(context.Background())
+		if err != nil {
+			t.Fatalf("fetch %d: FetchAnimeNews() error = %v", i, err)
+		}
+
+		var links []string
+		for _, item := range news {
+			links = append(links, item.Link)
+		}
+		want := []string{"https://example.com/fresh", "https://example.com/polled"}
+		if !reflect.DeepEqual(links, want) {
+			t.Errorf("fetch %d: links = %v; want %v", i, links, want)
+		}
+		if report.Pushed != 1 {
+			t.Errorf("fetch %d: Pushed = %d; want 1", i, report.Pushed)
+		}
+	}
+
+	stored, err := inbox.Items(time.Time{})
+	if err != nil || len(stored) != 2 {
+		t.Errorf("inbox holds %d items, %v; want the old item forgotten", len(stored), err)
+	}
+}
+
+func TestRSSFetcher_RecencyAndUndatedItems(t *testing.T) {
+	now := time.Date(2025, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `<?xml version="1.0"?><rss version="2.0"><channel><title>Feed</title>
+<item><title>Fresh anime news</title><link>https://example.com/fresh</link><pubDate>%s</pubDate></item>
+<item><title>Undated anime news</title><link>https://example.com/undated</link><guid>undated-1</guid></item>
+<item><title>Old anime news</title><link>https://example.com/old</link><pubDate>%s</pubDate></item>
+</channel></rss>`, now.Add(-time.Hour).Format(time.RFC1123Z), now.Add(-10*24*time.Hour).Format(time.RFC1123Z))
+	}))
+	defer server.Close()
+
+	seenPath := filepath.Join(t.TempDir(), "seen.json")
+	newFetcher := func(clock time.Time) *RSSFetcher {
+		seen, err := NewSeenItemsIndex(seenPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		seen.now = func() time.Time { return clock }
+
+		return &RSSFetcher{
+			feeds:         []config.FeedConfig{{URL: server.URL, Name: "Feed"}},
+			maxConcurrent: 1,
+			maxAge:        72 * time.Hour,
+			seen:          seen,
+			now:           func() time.Time { return clock },
+		}
+	}
+
+	// First fetch: the undated item is first seen now, so it sorts above the fresh item
+	news, report, err := newFetcher(now).FetchAnimeNews(context.Background())
+	if err != nil {
+		t.Fatalf("FetchAnimeNews() error = %v", err)
+	}
+	if len(news) != 2 || news[0].Link != "https://example.com/undated" || !news[0].PublishedAt.Equal(now) {
+		t.Fatalf("first fetch = %+v; want the undated item dated now, then the fresh item", news)
+	}
+	if report.TooOld != 1 {
+		t.Errorf("report.TooOld = %d; want the old item dropped", report.TooOld)
+	}
+
+	// A day later the undated item keeps its first-seen date instead of jumping to the top again
+	later := now.Add(24 * time.Hour)
+	news, _, err = newFetcher(later).FetchAnimeNews(context.Background())
+	if err != nil {
+		t.Fatalf("FetchAnimeNews() error = %v", err)
+	}
+	if len(news) != 2 || !news[0].PublishedAt.Equal(now) {
+		t.Errorf("second fetch = %+v; want the undated item still dated %s", news, now)
+	}
+
+	// The cap keeps only the newest items
+	capped := newFetcher(later)
+	capped.maxItems = 1
+	news, _, err = capped.FetchAnimeNews(context.Background())
+	if err != nil {
+		t.Fatalf("FetchAnimeNews() error = %v", err)
+	}
+	if len(news) != 1 || news[0].Link != "https://example.com/undated" {
+		t.Errorf("capped fetch = %+v; want only the newest item", news)
+	}
+}
+
+func TestRSSFetcher_SortNews(t *testing.T) {
+	at := time.Date(2025, 10, 1, 12, 0, 0, 0, time.UTC)
+	fetcher := &RSSFetcher{priorities: map[string]float64{"ANN": 5, "MAL": 3}}
+
+	news := []models.AnimeNews{
+		{Link: "mal-low", Source: "MAL", PublishedAt: at, Score: 2},
+		{Link: "older", Source: "ANN", PublishedAt: at.Add(-time.Minute), Score: 10},
+		{Link: "mal-high", Source: "MAL", PublishedAt: at, Score: 8},
+		{Link: "ann", Source: "ANN", PublishedAt: at, Score: 1},
+		{Link: "unknown", Source: "Blog", PublishedAt: at, Score: 9},
+		{Link: "mal-tie", Source: "MAL", PublishedAt: at, Score: 2},
+	}
+	fetcher.sortNews(news)
+
+	var got []string
+	for _, item := range news {
+		got = append(got, item.Link)
+	}
+	want := []string{"ann", "mal-high", "mal-low", "mal-tie", "unknown", "older"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("sortNews() order = %v; want %v", got, want)
+	}
+}
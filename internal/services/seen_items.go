@@ -0,0 +1,108 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go-test/internal/atomicfile"
+)
+
+// seenItemRetention is how long an item that no longer appears in any feed stays in the index
+const seenItemRetention = 30 * 24 * time.Hour
+
+// SeenItem records when a feed item was first and last seen
+type SeenItem struct {
+	Key       string    `json:"key"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// SeenItemsIndex remembers when feed items were first seen, so items
+// without a publication date keep a stable date across fetches instead of
+// looking brand new every time
+type SeenItemsIndex struct {
+	path string
+
+	mu    sync.Mutex
+	items map[string]*SeenItem
+	now   func() time.Time
+}
+
+// NewSeenItemsIndex creates an index and loads any state saved at path
+func NewSeenItemsIndex(path string) (*SeenItemsIndex, error) {
+	index := &SeenItemsIndex{
+		path:  path,
+		items: make(map[string]*SeenItem),
+		now:   time.Now,
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return index, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read seen items file: %w", err)
+	}
+
+	var saved []SeenItem
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, fmt.Errorf("failed to decode seen items file: %w", err)
+	}
+
+	for i := range saved {
+		index.items[saved[i].Key] = &saved[i]
+	}
+
+	return index, nil
+}
+
+// FirstSeen returns when the item with key was first seen, recording it as
+// seen now if it is new
+func (s *SeenItemsIndex) FirstSeen(key string) time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	item, ok := s.items[key]
+	if !ok {
+		item = &SeenItem{Key: key, FirstSeen: now}
+		s.items[key] = item
+	}
+	item.LastSeen = now
+
+	return item.FirstSeen
+}
+
+// Save drops items not seen for the retention period and writes the index to disk
+func (s *SeenItemsIndex) Save() error {
+	s.mu.Lock()
+	cutoff := s.now().Add(-seenItemRetention)
+	items := make([]SeenItem, 0, len(s.items))
+	for key, item := range s.items {
+		if item.LastSeen.Before(cutoff) {
+			delete(s.items, key)
+			continue
+		}
+		items = append(items, *item)
+	}
+	s.mu.Unlock()
+
+	data, err := json.MarshalIndent(items, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode seen items: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("failed to create seen items directory: %w", err)
+	}
+
+	if err := atomicfile.WriteFile(s.path, data); err != nil {
+		return fmt.Errorf("failed to write seen items file: %w", err)
+	}
+
+	return nil
+}
@@ -1,111 +1,189 @@
 package services
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
-	"io"
+	"log"
 	"net/http"
 	"strings"
 	"time"
 
 	"go-test/internal/models"
+	"go-test/internal/prompts"
 )
 
+// revisionPromptName is the template that asks the model to fix a rejected draft
+const revisionPromptName = "revision"
+
 // SinhalaWriter handles AI-powered Sinhala content generation
 type SinhalaWriter struct {
-	apiKey     string
-	baseURL    string
-	httpClient *http.Client
+	llm     LLMProvider
+	prompts *prompts.Library
+	persona string
+
+	// validator, when set, rejects drafts that are regenerated up to maxAttempts times in all
+	validator   *PostValidator
+	maxAttempts int
+
+	// cache, when set, answers repeated prompts without calling the model
+	cache *LLMCache
 }
 
-// NewSinhalaWriter creates a new Sinhala writer instance
-func NewSinhalaWriter(apiKey string) *SinhalaWriter {
-	return &SinhalaWriter{
-		apiKey:  apiKey,
-		baseURL: "https://generativelanguage.googleapis.com/v1beta/models/gemini-2.5-pro:generateContent",
-		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
-		},
-	}
+// PostPrompt is the article data persona templates are rendered with
+type PostPrompt struct {
+	Title   string
+	Summary string
+	Link    string
+	Source  string
 }
 
-// Using existing Gemini types from gemini.go
+// WrittenPost is a generated post and the ID of the prompt template
+// version that produced it
+type WrittenPost struct {
+	Text   string
+	Prompt string
 
-// WriteAnimePostInMyStyle generates a Sinhala post using AI
-func (sw *SinhalaWriter) WriteAnimePostInMyStyle(ctx context.Context, title, summary, link string) (string, error) {
-	prompt := sw.buildPersonaPrompt(title, summary, link)
-
-	reqBody := GeminiRequest{
-		Contents: []GeminiContent{
-			{
-				Parts: []GeminiPart{
-					{Text: prompt},
-				},
-			},
-		},
-	}
+	// Attempts counts the drafts written; Rejections lists why the earlier ones failed validation
+	Attempts   int
+	Rejections []string
+}
 
-	jsonBody, err := json.Marshal(reqBody)
-	if err != nil {
-		return "", fmt.Errorf("failed to marshal request: %w", err)
-	}
+// PostRejectedError is returned when no draft passed validation
+type PostRejectedError struct {
+	Attempts   int
+	Rejections []string
+}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", sw.baseURL+"?key="+sw.apiKey, bytes.NewBuffer(jsonBody))
-	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
-	}
+func (e *PostRejectedError) Error() string {
+	return fmt.Sprintf("all %d drafts failed validation: %s", e.Attempts, strings.Join(e.Rejections, "; "))
+}
 
-	req.Header.Set("Content-Type", "application/json")
+// revisionPrompt is the data the revision template is rendered with
+type revisionPrompt struct {
+	Prompt   string
+	Draft    string
+	Problems []string
+}
 
-	resp, err := sw.httpClient.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("failed to make request: %w", err)
+// NewSinhalaWriter creates a new Sinhala writer that writes with llm in
+// the voice of persona, a template in the library's personas directory
+func NewSinhalaWriter(llm LLMProvider, library *prompts.Library, persona string) (*SinhalaWriter, error) {
+	if !library.Has(prompts.PersonaPrefix + persona) {
+		return nil, fmt.Errorf("unknown persona %q", persona)
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", fmt.Errorf("failed to read response: %w", err)
-	}
+	return &SinhalaWriter{
+		llm:     llm,
+		prompts: library,
+		persona: persona,
+	}, nil
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
+// SetValidator makes the writer check every draft with validator and ask
+// the model to fix rejected ones, writing at most maxAttempts drafts
+func (sw *SinhalaWriter) SetValidator(validator *PostValidator, maxAttempts int) error {
+	if !sw.prompts.Has(revisionPromptName) {
+		return fmt.Errorf("prompt template %q is required to regenerate rejected posts", revisionPromptName)
 	}
-
-	var geminiResp GeminiResponse
-	if err := json.Unmarshal(body, &geminiResp); err != nil {
-		return "", fmt.Errorf("failed to unmarshal response: %w", err)
+	if maxAttempts < 1 {
+		maxAttempts = 1
 	}
 
-	if len(geminiResp.Candidates) == 0 || len(geminiResp.Candidates[0].Content.Parts) == 0 {
-		return "", fmt.Errorf("no content generated by AI")
-	}
+	sw.validator = validator
+	sw.maxAttempts = maxAttempts
+	return nil
+}
 
-	generatedText := geminiResp.Candidates[0].Content.Parts[0].Text
-	return strings.TrimSpace(generatedText), nil
+// SetCache makes the writer reuse the responses stored in cache. Only
+// drafts that pass validation are stored.
+func (sw *SinhalaWriter) SetCache(cache *LLMCache) {
+	sw.cache = cache
 }
 
-func (sw *SinhalaWriter) buildPersonaPrompt(title, summary, link string) string {
-	prompt := `You are a casual Sri Lankan anime fan writing for friends. Write in natural Sinhala with mixed English - just like how real Sri Lankans talk. Keep it simple, casual and fun.
+// SetTransport replaces the transport used for model requests
+func (sw *SinhalaWriter) SetTransport(rt http.RoundTripper) {
+	sw.llm.SetTransport(rt)
+}
 
-**Style:**
-- Mix Sinhala and English naturally (like "anime එකක්", "game එක", "trailer එක")
-- Use casual words: "අයියේ", "අක්කේ", "කොල්ලා", "කෙල්ලටත්" 
-- Common expressions: "ඒකනේ", "මේකද", "කොහොමද", "නේද"
-- Keep it short and excited
-- Add some emojis
-- End with a question
+// Model returns the name of the model that writes the posts
+func (sw *SinhalaWriter) Model() string {
+	return sw.llm.Model()
+}
 
-**News:**
-Title: %s
-Summary: %s
-Link: %s
+// WriteAnimePostInMyStyle generates a Sinhala post using AI
+func (sw *SinhalaWriter) WriteAnimePostInMyStyle(ctx context.Context, title, summary, link string) (string, error) {
+	post, err := sw.WritePost(ctx, PostPrompt{Title: title, Summary: summary, Link: link})
+	if err != nil {
+		return "", err
+	}
+	return post.Text, nil
+}
+
+// WritePost generates a Sinhala post for news with the writer's persona.
+// With a validator set, rejected drafts are sent back with the problems
+// found until one passes or the attempts run out, which returns a
+// *PostRejectedError.
+func (sw *SinhalaWriter) WritePost(ctx context.Context, news PostPrompt) (*WrittenPost, error) {
+	prompt, err := sw.prompts.Render(prompts.PersonaPrefix+sw.persona, news)
+	if err != nil {
+		return nil, err
+	}
 
-Write a casual post now:`
+	post := &WrittenPost{Prompt: prompt.ID()}
+	version, request := prompt.ID(), prompt.Text
+	for {
+		generatedText, cached, err := sw.generate(ctx, version, request)
+		if err != nil {
+			return nil, err
+		}
+		post.Text = strings.TrimSpace(generatedText)
+		post.Attempts++
+
+		var problems []string
+		if sw.validator != nil {
+			problems = sw.validator.Validate(post.Text, news.Link)
+		}
+		if len(problems) == 0 {
+			if sw.cache != nil && !cached {
+				if err := sw.cache.Put(sw.llm, version, request, post.Text); err != nil {
+					log.Printf("⚠️  Failed to cache post: %v", err)
+				}
+			}
+			return post, nil
+		}
+
+		rejection := fmt.Sprintf("attempt %d: %s", post.Attempts, strings.Join(problems, ", "))
+		post.Rejections = append(post.Rejections, rejection)
+		log.Printf("♻️  Draft rejected, %s", rejection)
+
+		if post.Attempts >= sw.maxAttempts {
+			return nil, &PostRejectedError{Attempts: post.Attempts, Rejections: post.Rejections}
+		}
+
+		revision, err := sw.prompts.Render(revisionPromptName, revisionPrompt{
+			Prompt:   prompt.Text,
+			Draft:    post.Text,
+			Problems: problems,
+		})
+		if err != nil {
+			return nil, err
+		}
+		version, request = revision.ID(), revision.Text
+	}
+}
+
+// generate answers request from the cache if it can and from the model
+// otherwise; cached reports which
+func (sw *SinhalaWriter) generate(ctx context.Context, version, request string) (text string, cached bool, err error) {
+	if sw.cache != nil {
+		if text, ok := sw.cache.Get(sw.llm, version, request); ok {
+			log.Printf("💾 Using cached %s response for %s", sw.llm.Name(), version)
+			return text, true, nil
+		}
+	}
 
-	return fmt.Sprintf(prompt, title, summary, link)
+	text, err = sw.llm.Generate(ctx, request)
+	return text, false, err
 }
 
 // CreateSinhalaPost creates a complete Sinhala post record
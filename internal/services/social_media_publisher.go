@@ -4,11 +4,19 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
+	"strconv"
 	"time"
 )
 
+// ErrPostNotSent marks a publish error after which the post is known not to
+// have gone out. Other errors, such as a timeout while waiting for the
+// platform's answer, leave it unknown whether the post was sent.
+var ErrPostNotSent = errors.New("post was not sent")
+
 // SocialMediaPublisher handles publishing posts to social media platforms
 type SocialMediaPublisher struct {
 	telegramBotToken string
@@ -27,6 +35,11 @@ func NewSocialMediaPublisher(telegramBotToken, telegramChatID string) *SocialMed
 	}
 }
 
+// SetTransport replaces the transport used for Telegram Bot API calls
+func (smp *SocialMediaPublisher) SetTransport(rt http.RoundTripper) {
+	smp.httpClient.Transport = rt
+}
+
 // TelegramMessage represents a Telegram API message
 type TelegramMessage struct {
 	ChatID    string `json:"chat_id"`
@@ -36,22 +49,29 @@ type TelegramMessage struct {
 
 // TelegramResponse represents the response from Telegram API
 type TelegramResponse struct {
-	OK          bool   `json:"ok"`
-	Description string `json:"description,omitempty"`
-	ErrorCode   int    `json:"error_code,omitempty"`
+	OK          bool            `json:"ok"`
+	Description string          `json:"description,omitempty"`
+	ErrorCode   int             `json:"error_code,omitempty"`
+	Result      json.RawMessage `json:"result,omitempty"`
+}
+
+// PublishReceipt identifies a published post
+type PublishReceipt struct {
+	Channel   string // platform and destination, e.g. "telegram:@animenews"
+	MessageID string // empty when the platform's answer did not include a readable ID
 }
 
 // PublishPost publishes a post to the configured social media platform
-func (smp *SocialMediaPublisher) PublishPost(ctx context.Context, postText string) (string, error) {
+func (smp *SocialMediaPublisher) PublishPost(ctx context.Context, postText string) (*PublishReceipt, error) {
 	if smp.telegramBotToken != "" && smp.telegramChatID != "" {
 		return smp.publishToTelegram(ctx, postText)
 	}
 
-	return "", fmt.Errorf("no social media platform configured")
+	return nil, fmt.Errorf("%w: no social media platform configured", ErrPostNotSent)
 }
 
 // publishToTelegram publishes a post to Telegram
-func (smp *SocialMediaPublisher) publishToTelegram(ctx context.Context, postText string) (string, error) {
+func (smp *SocialMediaPublisher) publishToTelegram(ctx context.Context, postText string) (*PublishReceipt, error) {
 	url := fmt.Sprintf("https://api.telegram.org/bot%s/sendMessage", smp.telegramBotToken)
 
 	message := TelegramMessage{
@@ -61,32 +81,51 @@ func (smp *SocialMediaPublisher) publishToTelegram(ctx context.Context, postText
 
 	jsonBody, err := json.Marshal(message)
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal Telegram message: %w", err)
+		return nil, fmt.Errorf("%w: failed to marshal Telegram message: %v", ErrPostNotSent, err)
 	}
 
 	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonBody))
 	if err != nil {
-		return "", fmt.Errorf("failed to create Telegram request: %w", err)
+		return nil, fmt.Errorf("%w: failed to create Telegram request: %v", ErrPostNotSent, err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
 
 	resp, err := smp.httpClient.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("failed to send Telegram message: %w", err)
+		return nil, fmt.Errorf("failed to send Telegram message: %w", err)
 	}
 	defer resp.Body.Close()
 
 	var telegramResp TelegramResponse
 	if err := json.NewDecoder(resp.Body).Decode(&telegramResp); err != nil {
-		return "", fmt.Errorf("failed to decode Telegram response: %w", err)
+		return nil, fmt.Errorf("failed to decode Telegram response: %w", err)
 	}
 
+	// Telegram answers ok=false only for messages it did not send
 	if !telegramResp.OK {
-		return "", fmt.Errorf("Telegram API error: %s (code: %d)", telegramResp.Description, telegramResp.ErrorCode)
+		return nil, fmt.Errorf("%w: Telegram API error: %s (code: %d)", ErrPostNotSent, telegramResp.Description, telegramResp.ErrorCode)
+	}
+
+	receipt := &PublishReceipt{Channel: "telegram:" + smp.telegramChatID}
+
+	// Telegram accepted the post, so it is out even if its ID can't be read;
+	// failing here would get it released and posted again
+	var sent struct {
+		MessageID int64 `json:"message_id"`
+	}
+	if err := json.Unmarshal(telegramResp.Result, &sent); err != nil {
+		log.Printf("⚠️  Telegram sent the post but its message ID could not be decoded: %v", err)
+		return receipt, nil
+	}
+
+	if sent.MessageID == 0 {
+		log.Printf("⚠️  Telegram sent the post but its answer has no message ID")
+		return receipt, nil
 	}
 
-	return "Successfully posted to Telegram!", nil
+	receipt.MessageID = strconv.FormatInt(sent.MessageID, 10)
+	return receipt, nil
 }
 
 // TestConnection tests the connection to the social media platform
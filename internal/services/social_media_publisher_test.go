@@ -0,0 +1,72 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// roundTripFunc answers requests without a network
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+func TestSocialMediaPublisher_PublishPost(t *testing.T) {
+	tests := []struct {
+		name          string
+		response      string
+		wantMessageID string
+		wantErr       bool
+	}{
+		{"sent", `{"ok":true,"result":{"message_id":42}}`, "42", false},
+		{"sent with an unreadable result", `{"ok":true,"result":"unexpected"}`, "", false},
+		{"sent with a null result", `{"ok":true,"result":null}`, "", false},
+		{"sent with an empty result", `{"ok":true,"result":{}}`, "", false},
+		{"sent without a result", `{"ok":true}`, "", false},
+		{"rejected", `{"ok":false,"error_code":400,"description":"Bad Request: chat not found"}`, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			publisher := NewSocialMediaPublisher("token", "@anime")
+			publisher.SetTransport(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Header:     http.Header{"Content-Type": {"application/json"}},
+					Body:       io.NopCloser(strings.NewReader(tt.response)),
+					Request:    req,
+				}, nil
+			}))
+
+			receipt, err := publisher.PublishPost(context.Background(), "post")
+			if tt.wantErr {
+				if !errors.Is(err, ErrPostNotSent) {
+					t.Fatalf("PublishPost() = %+v, %v; want ErrPostNotSent", receipt, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("PublishPost() error = %v", err)
+			}
+			if receipt.Channel != "telegram:@anime" || receipt.MessageID != tt.wantMessageID {
+				t.Errorf("PublishPost() = %+v; want message %q on telegram:@anime", receipt, tt.wantMessageID)
+			}
+		})
+	}
+}
+
+func TestSocialMediaPublisher_PublishPostUnknownOutcome(t *testing.T) {
+	publisher := NewSocialMediaPublisher("token", "@anime")
+	publisher.SetTransport(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return nil, context.DeadlineExceeded
+	}))
+
+	// The request may have reached Telegram before the answer timed out
+	_, err := publisher.PublishPost(context.Background(), "post")
+	if err == nil || errors.Is(err, ErrPostNotSent) {
+		t.Errorf("PublishPost() error = %v; want an error that does not claim the post was not sent", err)
+	}
+}
@@ -0,0 +1,229 @@
+package services
+
+import (
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"go-test/internal/models"
+)
+
+// titleStopwords carry no meaning for story identity and are dropped before
+// shingling. Besides English stopwords this includes the stock vocabulary of
+// anime headlines; otherwise "Frieren Anime Gets 2nd Season" and "Dandadan
+// Anime Gets 2nd Season" would look like the same story.
+var titleStopwords = map[string]bool{
+	"a": true, "an": true, "the": true, "and": true, "or": true, "of": true,
+	"to": true, "in": true, "on": true, "for": true, "with": true, "at": true,
+	"by": true, "from": true, "is": true, "are": true, "its": true, "new": true,
+	"anime": true, "manga": true, "tv": true, "series": true, "season": true,
+	"gets": true, "announced": true, "announces": true, "confirmed": true,
+	"reveals": true, "revealed": true, "officially": true, "official": true,
+	"trailer": true, "visual": true, "premiere": true, "premieres": true,
+}
+
+// romanNumerals are the sequel numbers titles write as Roman numerals. "i"
+// and "x" are left out, as they are far more often a word ("Spy x Family").
+var romanNumerals = map[string]string{
+	"ii": "2", "iii": "3", "iv": "4", "v": "5", "vi": "6", "vii": "7", "viii": "8", "ix": "9",
+}
+
+// storyClusterer groups near-identical stories from different feeds using
+// the Jaccard similarity of word-bigram and character-trigram shingles of
+// their normalized titles. Titles must also mention the same numbers, so
+// "Season 2" and "Season 3" or two chapter numbers never merge.
+type storyClusterer struct {
+	threshold  float64
+	window     time.Duration
+	priorities map[string]float64
+}
+
+// cluster merges items that describe the same story. Each cluster keeps one
+// representative, chosen by source priority, and records the links of the
+// other members as alternates.
+func (sc *storyClusterer) cluster(items []models.AnimeNews) []models.AnimeNews {
+	if sc.threshold <= 0 || len(items) < 2 {
+		return items
+	}
+
+	shingles := make([]map[string]bool, len(items))
+	numbers := make([]string, len(items))
+	for i, item := range items {
+		shingles[i] = titleShingles(item.Title)
+		numbers[i] = titleNumbers(item.Title)
+	}
+
+	// Union-find over all pairs that are similar and close in time
+	parent := make([]int, len(items))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	for i := 0; i < len(items); i++ {
+		for j := i + 1; j < len(items); j++ {
+			if sc.window > 0 && absDuration(items[i].PublishedAt.Sub(items[j].PublishedAt)) > sc.window {
+				continue
+			}
+			// Equal number sets also keep merges from chaining across sequels
+			if numbers[i] == numbers[j] && jaccard(shingles[i], shingles[j]) >= sc.threshold {
+				parent[find(j)] = find(i)
+			}
+		}
+	}
+
+	groups := make(map[int][]int)
+	var roots []int
+	for i := range items {
+		root := find(i)
+		if _, ok := groups[root]; !ok {
+			roots = append(roots, root)
+		}
+		groups[root] = append(groups[root], i)
+	}
+
+	clustered := make([]models.AnimeNews, 0, len(roots))
+	for _, root := range roots {
+		members := groups[root]
+		sort.SliceStable(members, func(a, b int) bool {
+			return sc.preferred(items[members[a]], items[members[b]])
+		})
+
+		representative := items[members[0]]
+		for _, member := range members[1:] {
+			representative.AlternateLinks = appendUnique(representative.AlternateLinks, items[member].Link)
+			representative.AlternateLinks = appendUnique(representative.AlternateLinks, items[member].AlternateLinks...)
+		}
+		clustered = append(clustered, representative)
+	}
+
+	return clustered
+}
+
+// preferred reports whether a should represent a cluster ahead of b:
+// higher source priority first, then the earlier report
+func (sc *storyClusterer) preferred(a, b models.AnimeNews) bool {
+	if pa, pb := sc.priorities[a.Source], sc.priorities[b.Source]; pa != pb {
+		return pa > pb
+	}
+	return a.PublishedAt.Before(b.PublishedAt)
+}
+
+// normalizeTitle lowercases a title, strips punctuation and drops stopwords
+func normalizeTitle(title string) []string {
+	words := strings.FieldsFunc(strings.ToLower(title), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+
+	kept := words[:0]
+	for _, word := range words {
+		if !titleStopwords[word] {
+			kept = append(kept, word)
+		}
+	}
+	return kept
+}
+
+// titleShingles returns word bigrams plus character trigrams of the normalized
+// title. Bigrams reward shared phrases, trigrams tolerate small spelling changes.
+func titleShingles(title string) map[string]bool {
+	words := normalizeTitle(title)
+	shingles := make(map[string]bool)
+
+	for i := 0; i+1 < len(words); i++ {
+		shingles["w:"+words[i]+" "+words[i+1]] = true
+	}
+	if len(words) == 1 {
+		shingles["w:"+words[0]] = true
+	}
+
+	runes := []rune(strings.Join(words, " "))
+	for i := 0; i+3 <= len(runes); i++ {
+		shingles["c:"+string(runes[i:i+3])] = true
+	}
+
+	return shingles
+}
+
+// titleNumbers returns the numbers a title mentions as a sorted key, with
+// ordinals and Roman numerals read as plain numbers: "3rd Season" and
+// "Season III" both give "3"
+func titleNumbers(title string) string {
+	seen := make(map[string]bool)
+	for _, word := range strings.FieldsFunc(strings.ToLower(title), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	}) {
+		if number, ok := romanNumerals[word]; ok {
+			seen[number] = true
+			continue
+		}
+
+		suffix := strings.TrimLeftFunc(word, unicode.IsDigit)
+		if suffix == word {
+			continue
+		}
+		switch suffix {
+		case "", "st", "nd", "rd", "th":
+			number := strings.TrimLeft(word[:len(word)-len(suffix)], "0")
+			if number == "" {
+				number = "0"
+			}
+			seen[number] = true
+		}
+	}
+
+	numbers := make([]string, 0, len(seen))
+	for number := range seen {
+		numbers = append(numbers, number)
+	}
+	sort.Strings(numbers)
+	return strings.Join(numbers, " ")
+}
+
+func jaccard(a, b map[string]bool) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+
+	intersection := 0
+	for shingle := range a {
+		if b[shingle] {
+			intersection++
+		}
+	}
+
+	return float64(intersection) / float64(len(a)+len(b)-intersection)
+}
+
+func appendUnique(list []string, values ...string) []string {
+	for _, value := range values {
+		if value == "" {
+			continue
+		}
+		found := false
+		for _, existing := range list {
+			if existing == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			list = append(list, value)
+		}
+	}
+	return list
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
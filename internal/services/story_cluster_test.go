@@ -0,0 +1,102 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"go-test/internal/models"
+)
+
+func TestTitleSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b string
+		same bool
+	}{
+		{"Jujutsu Kaisen Season 3 Gets October Premiere", "Jujutsu Kaisen 3rd Season Premieres in October", true},
+		{"Frieren Anime Gets 2nd Season in January 2026", "'Frieren' Anime Gets 2nd Season in January 2026!", true},
+		{"Oshi no Ko Season 3 Announced", "Oshi No Ko Season 3 Officially Announced", true},
+		{"Chainsaw Man Movie Tops Box Office", "Chainsaw Man Movie Tops Japanese Box Office Again", true},
+		{"Frieren Anime Gets 2nd Season", "Dandadan Anime Gets 2nd Season", false},
+		{"One Piece Chapter 1120 Delayed", "One Piece Film Red Streams on Netflix", false},
+		{"Spy x Family Season 3 Trailer Released", "Spy x Family Code: White Movie Blu-ray Release Date", false},
+		// Sequels and chapters differ only in their number
+		{"Frieren Season 2 Announced", "Frieren Season 3 Announced", false},
+		{"One Piece Chapter 1120 Delayed", "One Piece Chapter 1121 Delayed", false},
+		{"Overlord Season IV Announced", "Overlord Season 5 Announced", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.a+" / "+tt.b, func(t *testing.T) {
+			similarity := jaccard(titleShingles(tt.a), titleShingles(tt.b))
+			sameNumbers := titleNumbers(tt.a) == titleNumbers(tt.b)
+			if same := sameNumbers && similarity >= 0.6; same != tt.same {
+				t.Errorf("similarity(%q, %q) = %.2f, same numbers %t; want same story = %t", tt.a, tt.b, similarity, sameNumbers, tt.same)
+			}
+		})
+	}
+}
+
+func TestTitleNumbers(t *testing.T) {
+	tests := map[string]string{
+		"Jujutsu Kaisen Season 3 Gets October Premiere":  "3",
+		"Jujutsu Kaisen 3rd Season Premieres in October": "3",
+		"Overlord Season IV":                             "4",
+		"Frieren Gets 2nd Season in January 2026":        "2 2026",
+		"Spy x Family Code: White":                       "",
+		"Mob Psycho 100 Episode 07":                      "100 7",
+		"Kaiju No. 8 Part 2":                             "2 8",
+	}
+
+	for title, want := range tests {
+		if got := titleNumbers(title); got != want {
+			t.Errorf("titleNumbers(%q) = %q; want %q", title, got, want)
+		}
+	}
+}
+
+func TestStoryClusterer_Cluster(t *testing.T) {
+	now := time.Date(2025, 10, 1, 12, 0, 0, 0, time.UTC)
+	clusterer := &storyClusterer{
+		threshold: 0.6,
+		window:    48 * time.Hour,
+		priorities: map[string]float64{
+			"Anime News Network": 5,
+			"Crunchyroll":        4,
+			"MyAnimeList":        3,
+		},
+	}
+
+	items := []models.AnimeNews{
+		{Title: "'Oshi no Ko' Season 3 Officially Announced", Link: "https://mal.example/oshi", Source: "MyAnimeList", PublishedAt: now},
+		{Title: "Oshi no Ko Season 3 Announced", Link: "https://ann.example/oshi", Source: "Anime News Network", PublishedAt: now.Add(-time.Hour)},
+		{Title: "Dandadan Anime Gets 2nd Season", Link: "https://cr.example/dandadan", Source: "Crunchyroll", PublishedAt: now.Add(-2 * time.Hour)},
+		{Title: "OSHI NO KO Season 3 announced!", Link: "https://cr.example/oshi", Source: "Crunchyroll", PublishedAt: now.Add(-3 * time.Hour)},
+		// The sequel shares all but its number with the stories above
+		{Title: "Oshi no Ko Season 4 Officially Announced", Link: "https://ann.example/oshi-4", Source: "Anime News Network", PublishedAt: now.Add(-4 * time.Hour)},
+		// Same headline a week later is a different story
+		{Title: "Dandadan Anime Gets 2nd Season", Link: "https://mal.example/dandadan-old", Source: "MyAnimeList", PublishedAt: now.Add(-7 * 24 * time.Hour)},
+	}
+
+	clustered := clusterer.cluster(items)
+
+	type summary struct {
+		Link       string
+		Alternates []string
+	}
+	var got []summary
+	for _, item := range clustered {
+		got = append(got, summary{item.Link, item.AlternateLinks})
+	}
+
+	want := []summary{
+		{"https://ann.example/oshi", []string{"https://cr.example/oshi", "https://mal.example/oshi"}},
+		{"https://cr.example/dandadan", nil},
+		{"https://ann.example/oshi-4", nil},
+		{"https://mal.example/dandadan-old", nil},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("cluster() = %+v; want %+v", got, want)
+	}
+}
@@ -0,0 +1,220 @@
+package services
+
+import (
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// trackingParams are query parameters that identify a campaign or click
+// rather than the page, matched exactly
+var trackingParams = map[string]bool{
+	"fbclid": true, "gclid": true, "dclid": true, "msclkid": true, "yclid": true,
+	"igshid": true, "mc_cid": true, "mc_eid": true, "_ga": true, "_gl": true,
+	"ref": true, "ref_src": true, "ref_url": true, "referrer": true,
+	"cmpid": true, "ocid": true, "spm": true,
+	"amp_js_v": true, "usqp": true,
+}
+
+// ampFlagParams are query parameters that ask for the AMP version of a page,
+// with the values that do so. Other values are left alone, as they may
+// select content.
+var ampFlagParams = map[string][]string{
+	"amp":        {"", "1", "true"},
+	"outputtype": {"amp"},
+}
+
+// trackingParamPrefixes are prefixes of tracking parameter families
+var trackingParamPrefixes = []string{"utm_", "hsa_", "pk_", "mtm_", "__twitter"}
+
+// redirectParams maps redirect wrappers to the parameter holding the target
+var redirectParams = map[string]string{
+	"google.com/url":        "q",
+	"l.facebook.com/l.php":  "u",
+	"lm.facebook.com/l.php": "u",
+	"out.reddit.com/":       "url",
+	"t.umblr.com/redirect":  "z",
+	"href.li/":              "",
+}
+
+// canonicalizeURL reduces the variants of an article URL that feeds hand
+// out to one form: https, no "www." host prefix, no default port, fragment,
+// tracking parameters or trailing slash, and AMP cache or redirect wrappers
+// resolved to the page they point at. The "amp." host prefix and "/amp"
+// path markers are only removed from URLs that are known to be AMP pages,
+// i.e. served through an AMP cache, with an AMP flag parameter, or from an
+// "amp." subdomain with an AMP path, since a regular site may live on an
+// "amp." host (amp.dev) or have paths ending in "/amp". Anything that is
+// not an absolute http(s) URL is returned trimmed but otherwise unchanged.
+func canonicalizeURL(raw string) string {
+	raw = strings.TrimSpace(raw)
+	parsed, err := url.Parse(raw)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return raw
+	}
+
+	// Unwrap redirects and AMP caches a few levels deep
+	amp := false
+	for i := 0; i < 3; i++ {
+		if isAMPCacheHost(parsed.Hostname()) {
+			amp = true
+		}
+		target := unwrapURL(parsed)
+		if target == nil {
+			break
+		}
+		parsed = target
+	}
+
+	query := parsed.Query()
+	if stripAMPFlags(query) {
+		amp = true
+	}
+
+	host := strings.ToLower(parsed.Hostname())
+	host = strings.TrimSuffix(host, ".")
+	host = strings.TrimPrefix(host, "www.")
+
+	// "amp." is only a subdomain if a registrable domain remains after it
+	path := parsed.EscapedPath()
+	ampHost := strings.HasPrefix(host, "amp.") && strings.Contains(host[len("amp."):], ".")
+	if ampHost && stripAMPPath(path) != path {
+		amp = true
+	}
+	if amp {
+		if ampHost {
+			host = host[len("amp."):]
+		}
+		path = stripAMPPath(path)
+	}
+	path = strings.TrimRight(path, "/")
+
+	if port := parsed.Port(); port != "" && port != "80" && port != "443" {
+		host = net.JoinHostPort(host, port)
+	}
+
+	canonical := "https://" + host + path
+	if query := canonicalQuery(query); query != "" {
+		canonical += "?" + query
+	}
+	return canonical
+}
+
+// stripAMPPath removes the "/amp" suffix or prefix and ".amp" extension
+// that AMP pages add to the article path
+func stripAMPPath(path string) string {
+	for _, suffix := range []string{"/amp/", "/amp", ".amp"} {
+		if strings.HasSuffix(path, suffix) && len(path) > len(suffix) {
+			return strings.TrimSuffix(path, suffix)
+		}
+	}
+	if strings.HasPrefix(path, "/amp/") {
+		return path[len("/amp"):]
+	}
+	return path
+}
+
+// stripAMPFlags deletes the parameters that request the AMP version of a
+// page and reports whether there were any
+func stripAMPFlags(query url.Values) bool {
+	found := false
+	for name, values := range query {
+		flags, ok := ampFlagParams[strings.ToLower(name)]
+		if !ok || len(values) != 1 {
+			continue
+		}
+		for _, flag := range flags {
+			if strings.EqualFold(values[0], flag) {
+				query.Del(name)
+				found = true
+				break
+			}
+		}
+	}
+	return found
+}
+
+// isAMPCacheHost reports whether host is the Google AMP cache
+func isAMPCacheHost(host string) bool {
+	return strings.HasSuffix(strings.ToLower(host), ".cdn.ampproject.org")
+}
+
+// unwrapURL returns the target of a known redirect wrapper or AMP cache
+// URL, or nil if u is neither
+func unwrapURL(u *url.URL) *url.URL {
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+
+	// https://example-com.cdn.ampproject.org/c/s/example.com/article
+	if isAMPCacheHost(host) {
+		path := strings.TrimPrefix(u.Path, "/")
+		for _, mode := range []string{"c/", "v/", "i/"} {
+			path = strings.TrimPrefix(path, mode)
+		}
+		scheme := "http"
+		if strings.HasPrefix(path, "s/") {
+			scheme, path = "https", path[len("s/"):]
+		}
+		if target, err := url.Parse(scheme + "://" + path); err == nil && target.Host != "" {
+			target.RawQuery = u.RawQuery
+			return target
+		}
+		return nil
+	}
+
+	for wrapper, param := range redirectParams {
+		wrapperHost, wrapperPath, _ := strings.Cut(wrapper, "/")
+		if host != wrapperHost || !strings.HasPrefix(strings.TrimPrefix(u.Path, "/"), wrapperPath) {
+			continue
+		}
+
+		var raw string
+		if param == "" {
+			// href.li/?https://example.com/article
+			raw = u.RawQuery
+		} else {
+			raw = u.Query().Get(param)
+		}
+		if target, err := url.Parse(raw); err == nil && (target.Scheme == "http" || target.Scheme == "https") && target.Host != "" {
+			return target
+		}
+	}
+
+	return nil
+}
+
+// canonicalQuery drops tracking parameters and sorts the rest
+func canonicalQuery(query url.Values) string {
+	for name := range query {
+		lower := strings.ToLower(name)
+		if trackingParams[lower] {
+			query.Del(name)
+			continue
+		}
+		for _, prefix := range trackingParamPrefixes {
+			if strings.HasPrefix(lower, prefix) {
+				query.Del(name)
+				break
+			}
+		}
+	}
+
+	if len(query) == 0 {
+		return ""
+	}
+
+	// url.Values.Encode sorts by key; keep value order stable as well
+	for _, values := range query {
+		sort.Strings(values)
+	}
+	return query.Encode()
+}
+
+// articleKey is the link an article is recorded under: its canonical URL,
+// or the feed GUID when the item has no usable link
+func articleKey(link, guid string) string {
+	if canonical := canonicalizeURL(link); canonical != "" {
+		return canonical
+	}
+	return strings.TrimSpace(guid)
+}
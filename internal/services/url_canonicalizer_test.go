@@ -0,0 +1,68 @@
+package services
+
+import "testing"
+
+func TestCanonicalizeURL(t *testing.T) {
+	const article = "https://animenewsnetwork.com/news/2025-10-01/frieren-season-2"
+
+	tests := []struct {
+		name     string
+		raw      string
+		expected string
+	}{
+		{"already canonical", article, article},
+		{"tracking parameters", article + "?utm_source=rss&utm_medium=feed&fbclid=abc", article},
+		{"http and www", "http://www.animenewsnetwork.com/news/2025-10-01/frieren-season-2", article},
+		{"upper-case host, default port and fragment", "https://AnimeNewsNetwork.com:443/news/2025-10-01/frieren-season-2#comments", article},
+		{"trailing slash", article + "/", article},
+		{"AMP path suffix with AMP flag", article + "/amp/?amp=1", article},
+		{"AMP path prefix on AMP host", "https://amp.animenewsnetwork.com/amp/news/2025-10-01/frieren-season-2", article},
+		{"AMP host and parameter", "https://amp.animenewsnetwork.com/news/2025-10-01/frieren-season-2?amp=1", article},
+		{"AMP output type", article + ".amp?outputType=amp", article},
+		{"path ending in amp on a regular page", "https://example.com/guides/how-to-build-an-amp", "https://example.com/guides/how-to-build-an-amp"},
+		{"amp path segment on a regular page", "https://example.com/reviews/amp/", "https://example.com/reviews/amp"},
+		{"amp prefix on a regular page", "https://example.com/amp/specs", "https://example.com/amp/specs"},
+		{"amp parameter with content", "https://example.com/search?amp=studio-trigger", "https://example.com/search?amp=studio-trigger"},
+		{"amp.dev is a site of its own", "https://amp.dev/documentation/guides/", "https://amp.dev/documentation/guides"},
+		{"amp.dev with an AMP flag keeps its host", "https://amp.dev/documentation?amp=1", "https://amp.dev/documentation"},
+		{"amp subdomain without other AMP signs", "https://amp.example.com/news/1", "https://amp.example.com/news/1"},
+		{"AMP cache", "https://animenewsnetwork-com.cdn.ampproject.org/c/s/animenewsnetwork.com/news/2025-10-01/frieren-season-2/amp", article},
+		{"Google redirect", "https://www.google.com/url?q=" + article + "%3Futm_campaign%3Dx&sa=D", article},
+		{"Facebook redirect", "https://l.facebook.com/l.php?u=http%3A%2F%2Fwww.animenewsnetwork.com%2Fnews%2F2025-10-01%2Ffrieren-season-2&h=AT0", article},
+		{"meaningful parameters are kept and sorted", "https://example.com/read?page=2&id=7&utm_term=x", "https://example.com/read?id=7&page=2"},
+		{"non-default port is kept", "http://localhost:8080/post/1/", "https://localhost:8080/post/1"},
+		{"root path", "https://www.example.com/", "https://example.com"},
+		{"not a URL", "  tag:example.com,2025:42 ", "tag:example.com,2025:42"},
+		{"empty", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := canonicalizeURL(tt.raw)
+			if result != tt.expected {
+				t.Errorf("canonicalizeURL(%q) = %q; want %q", tt.raw, result, tt.expected)
+			}
+			if again := canonicalizeURL(result); again != result {
+				t.Errorf("canonicalizeURL is not idempotent: %q -> %q", result, again)
+			}
+		})
+	}
+}
+
+func TestArticleKey(t *testing.T) {
+	tests := []struct {
+		link     string
+		guid     string
+		expected string
+	}{
+		{"https://www.example.com/a?utm_source=rss", "a-1", "https://example.com/a"},
+		{"", "urn:uuid:1234", "urn:uuid:1234"},
+		{"", "", ""},
+	}
+
+	for _, tt := range tests {
+		if result := articleKey(tt.link, tt.guid); result != tt.expected {
+			t.Errorf("articleKey(%q, %q) = %q; want %q", tt.link, tt.guid, result, tt.expected)
+		}
+	}
+}
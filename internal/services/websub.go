@@ -0,0 +1,482 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go-test/internal/config"
+)
+
+// webSubRetryDelay is how long a subscription that was not verified waits before it is requested again
+const webSubRetryDelay = 10 * time.Minute
+
+// WebSubHandler receives the body of a verified content notification for feed
+type WebSubHandler func(feed config.FeedConfig, body []byte)
+
+// WebSubSubscriber subscribes to the WebSub hubs advertised by feeds and
+// serves the callback the hubs deliver to. It implements http.Handler for
+// the callback and PushSource for the RSS fetcher, so feeds with a verified
+// subscription are not polled.
+type WebSubSubscriber struct {
+	callbackBase string
+	secret       []byte
+	lease        time.Duration
+	httpClient   *http.Client
+	onContent    WebSubHandler
+	now          func() time.Time
+
+	mu   sync.Mutex
+	subs map[string]*webSubSubscription // keyed by callback ID
+}
+
+// webSubSubscription is the state of one topic subscription at a hub
+type webSubSubscription struct {
+	id          string
+	feed        config.FeedConfig
+	topic       string
+	hub         string
+	verified    bool
+	expiresAt   time.Time
+	requestedAt time.Time
+	leaving     bool
+}
+
+// NewWebSubSubscriber creates a subscriber whose callbacks live under
+// cfg.WebSubCallbackURL. Without WEBSUB_SECRET a random secret is used,
+// which is fine because subscriptions are renewed on every start.
+func NewWebSubSubscriber(cfg *config.Config, onContent WebSubHandler) *WebSubSubscriber {
+	secret := []byte(cfg.WebSubSecret)
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			panic(fmt.Sprintf("failed to generate WebSub secret: %v", err))
+		}
+	}
+
+	return &WebSubSubscriber{
+		callbackBase: strings.TrimSuffix(cfg.WebSubCallbackURL, "/"),
+		secret:       secret,
+		lease:        cfg.WebSubLease,
+		httpClient:   &http.Client{Timeout: cfg.RequestTimeout},
+		onContent:    onContent,
+		now:          time.Now,
+		subs:         make(map[string]*webSubSubscription),
+	}
+}
+
+// SetTransport replaces the transport used for discovery and hub requests
+func (ws *WebSubSubscriber) SetTransport(rt http.RoundTripper) {
+	ws.httpClient.Transport = rt
+}
+
+// Subscribe discovers the hub of every feed and asks it for a subscription.
+// Feeds without a hub are left to polling. It returns how many feeds
+// advertised a hub; the subscriptions only become active once the hub has
+// verified them through the callback.
+func (ws *WebSubSubscriber) Subscribe(ctx context.Context, feeds []config.FeedConfig) int {
+	withHub := 0
+	for _, feed := range feeds {
+		hub, topic, err := ws.discover(ctx, feed.URL)
+		if err != nil {
+			log.Printf("WebSub discovery failed for %s: %v", feed.URL, err)
+			continue
+		}
+		if hub == "" {
+			continue
+		}
+		withHub++
+
+		sub := &webSubSubscription{
+			id:    shortHash(topic),
+			feed:  feed,
+			topic: topic,
+			hub:   hub,
+		}
+
+		ws.mu.Lock()
+		ws.subs[sub.id] = sub
+		ws.mu.Unlock()
+
+		if err := ws.request(ctx, sub, "subscribe"); err != nil {
+			log.Printf("WebSub subscription to %s at %s failed: %v", topic, hub, err)
+		}
+	}
+	return withHub
+}
+
+// Run renews subscriptions before their lease runs out and retries ones
+// the hub never verified, until ctx is cancelled
+func (ws *WebSubSubscriber) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, sub := range ws.dueForRenewal() {
+				if err := ws.request(ctx, sub, "subscribe"); err != nil {
+					log.Printf("WebSub renewal of %s failed: %v", sub.topic, err)
+				}
+			}
+		}
+	}
+}
+
+// Unsubscribe asks every hub to end its subscription, e.g. on shutdown
+func (ws *WebSubSubscriber) Unsubscribe(ctx context.Context) {
+	ws.mu.Lock()
+	var subs []*webSubSubscription
+	for _, sub := range ws.subs {
+		sub.leaving = true
+		subs = append(subs, sub)
+	}
+	ws.mu.Unlock()
+
+	for _, sub := range subs {
+		if err := ws.request(ctx, sub, "unsubscribe"); err != nil {
+			log.Printf("WebSub unsubscribe from %s failed: %v", sub.topic, err)
+		}
+	}
+}
+
+// IsActive reports whether feedURL has a verified, unexpired subscription
+func (ws *WebSubSubscriber) IsActive(feedURL string) bool {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	now := ws.now()
+	for _, sub := range ws.subs {
+		if sub.feed.URL == feedURL && sub.verified && now.Before(sub.expiresAt) {
+			return true
+		}
+	}
+	return false
+}
+
+// ServeHTTP handles hub verification requests (GET) and content
+// notifications (POST) for callback URLs of the form <base>/<id>
+func (ws *WebSubSubscriber) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+
+	ws.mu.Lock()
+	sub, ok := ws.subs[id]
+	ws.mu.Unlock()
+
+	switch r.Method {
+	case http.MethodGet:
+		ws.handleVerification(w, r, sub, ok)
+	case http.MethodPost:
+		if !ok {
+			// 410 tells the hub to drop a subscription we no longer know about
+			http.Error(w, "unknown subscription", http.StatusGone)
+			return
+		}
+		ws.handleContent(w, r, sub)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (ws *WebSubSubscriber) handleVerification(w http.ResponseWriter, r *http.Request, sub *webSubSubscription, ok bool) {
+	query := r.URL.Query()
+	mode := query.Get("hub.mode")
+	topic := query.Get("hub.topic")
+
+	if !ok || topic != sub.topic {
+		http.Error(w, "unknown subscription", http.StatusNotFound)
+		return
+	}
+
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	switch mode {
+	case "denied":
+		sub.verified = false
+		log.Printf("WebSub hub denied subscription to %s: %s", topic, query.Get("hub.reason"))
+		w.WriteHeader(http.StatusOK)
+		return
+
+	case "subscribe":
+		if sub.leaving {
+			http.Error(w, "not subscribing", http.StatusNotFound)
+			return
+		}
+		lease := ws.lease
+		if seconds, err := strconv.Atoi(query.Get("hub.lease_seconds")); err == nil && seconds > 0 {
+			lease = time.Duration(seconds) * time.Second
+		}
+		sub.verified = true
+		sub.expiresAt = ws.now().Add(lease)
+		log.Printf("WebSub subscription to %s verified for %s", topic, lease)
+
+	case "unsubscribe":
+		if !sub.leaving {
+			http.Error(w, "not unsubscribing", http.StatusNotFound)
+			return
+		}
+		delete(ws.subs, sub.id)
+
+	default:
+		http.Error(w, "unsupported hub.mode", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(http.StatusOK)
+	io.WriteString(w, query.Get("hub.challenge"))
+}
+
+// handleContent checks the HMAC signature of a notification and hands
+// valid content on. Invalid notifications are still acknowledged, as the
+// spec requires, but ignored.
+func (ws *WebSubSubscriber) handleContent(w http.ResponseWriter, r *http.Request, sub *webSubSubscription) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxFeedSize))
+	if err != nil {
+		http.Error(w, "failed to read body", http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+
+	if !verifySignature(r.Header.Get("X-Hub-Signature"), ws.subscriptionSecret(sub.topic), body) {
+		log.Printf("WebSub notification for %s has an invalid signature, ignoring it", sub.topic)
+		return
+	}
+
+	if ws.onContent != nil {
+		// Publishing can take a while; the hub only waits for the acknowledgement
+		go ws.onContent(sub.feed, body)
+	}
+}
+
+// request sends a subscribe or unsubscribe request to the subscription's hub
+func (ws *WebSubSubscriber) request(ctx context.Context, sub *webSubSubscription, mode string) error {
+	form := url.Values{
+		"hub.mode":     {mode},
+		"hub.topic":    {sub.topic},
+		"hub.callback": {ws.callbackBase + "/" + sub.id},
+	}
+	if mode == "subscribe" {
+		form.Set("hub.secret", ws.subscriptionSecret(sub.topic))
+		if ws.lease > 0 {
+			form.Set("hub.lease_seconds", strconv.Itoa(int(ws.lease.Seconds())))
+		}
+	}
+
+	ws.mu.Lock()
+	sub.requestedAt = ws.now()
+	ws.mu.Unlock()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", sub.hub, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("failed to create hub request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("User-Agent", feedUserAgent)
+
+	resp, err := ws.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach hub %s: %w", sub.hub, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("hub %s returned status %d: %s", sub.hub, resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+
+	return nil
+}
+
+// dueForRenewal returns subscriptions whose lease ends within a tenth of
+// its length, and unverified ones whose last request is older than the retry delay
+func (ws *WebSubSubscriber) dueForRenewal() []*webSubSubscription {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	now := ws.now()
+	var due []*webSubSubscription
+	for _, sub := range ws.subs {
+		if sub.leaving {
+			continue
+		}
+		if sub.verified && now.Add(ws.lease/10).After(sub.expiresAt) {
+			due = append(due, sub)
+		} else if !sub.verified && now.Sub(sub.requestedAt) >= webSubRetryDelay {
+			due = append(due, sub)
+		}
+	}
+	return due
+}
+
+// subscriptionSecret derives a distinct secret per topic, so one hub
+// cannot sign notifications for another hub's topics
+func (ws *WebSubSubscriber) subscriptionSecret(topic string) string {
+	mac := hmac.New(sha256.New, ws.secret)
+	mac.Write([]byte(topic))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// discover finds a feed's hub and canonical topic URL from its HTTP Link
+// headers or, failing that, the atom:link elements in the feed itself.
+// An empty hub means the feed does not support WebSub.
+func (ws *WebSubSubscriber) discover(ctx context.Context, feedURL string) (hub, topic string, err error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", feedURL, nil)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create feed request: %w", err)
+	}
+	req.Header.Set("User-Agent", feedUserAgent)
+
+	resp, err := ws.httpClient.Do(req)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to fetch feed %s: %w", feedURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", "", fmt.Errorf("feed %s returned status: %d", feedURL, resp.StatusCode)
+	}
+
+	hub, topic = parseLinkHeaders(resp.Header.Values("Link"))
+	if hub == "" {
+		hub, topic = parseFeedLinks(io.LimitReader(resp.Body, maxFeedSize))
+	}
+	if topic == "" {
+		topic = feedURL
+	}
+
+	return hub, topic, nil
+}
+
+// parseLinkHeaders extracts the hub and self URLs from RFC 8288 Link headers
+func parseLinkHeaders(headers []string) (hub, self string) {
+	for _, header := range headers {
+		for _, link := range strings.Split(header, ",") {
+			target, params, found := strings.Cut(link, ";")
+			if !found {
+				continue
+			}
+			target = strings.Trim(strings.TrimSpace(target), "<>")
+
+			for _, param := range strings.Split(params, ";") {
+				key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+				if !strings.EqualFold(key, "rel") {
+					continue
+				}
+				for _, rel := range strings.Fields(strings.Trim(value, `"`)) {
+					switch strings.ToLower(rel) {
+					case "hub":
+						if hub == "" {
+							hub = target
+						}
+					case "self":
+						if self == "" {
+							self = target
+						}
+					}
+				}
+			}
+		}
+	}
+	return hub, self
+}
+
+// parseFeedLinks extracts the hub and self URLs from the link elements of
+// an Atom feed or the atom:link elements of an RSS channel
+func parseFeedLinks(r io.Reader) (hub, self string) {
+	decoder := xml.NewDecoder(r)
+	decoder.Strict = false
+
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return hub, self
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		// Hub links belong to the channel; stop once the items begin
+		if start.Name.Local == "item" || start.Name.Local == "entry" {
+			return hub, self
+		}
+		if start.Name.Local != "link" {
+			continue
+		}
+
+		var rel, href string
+		for _, attr := range start.Attr {
+			switch attr.Name.Local {
+			case "rel":
+				rel = attr.Value
+			case "href":
+				href = attr.Value
+			}
+		}
+
+		switch {
+		case rel == "hub" && hub == "":
+			hub = href
+		case rel == "self" && self == "":
+			self = href
+		}
+	}
+}
+
+// verifySignature checks an X-Hub-Signature header of the form "method=hex"
+func verifySignature(header, secret string, body []byte) bool {
+	method, signature, found := strings.Cut(header, "=")
+	if !found {
+		return false
+	}
+
+	var newHash func() hash.Hash
+	switch strings.ToLower(method) {
+	case "sha1":
+		newHash = sha1.New
+	case "sha256":
+		newHash = sha256.New
+	case "sha384":
+		newHash = sha512.New384
+	case "sha512":
+		newHash = sha512.New
+	default:
+		return false
+	}
+
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(newHash, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+// shortHash returns a stable, URL-safe identifier for s
+func shortHash(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:8])
+}
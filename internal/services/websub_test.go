@@ -0,0 +1,183 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"go-test/internal/config"
+)
+
+func TestWebSubSubscriber(t *testing.T) {
+	var hubURL, feedURL string
+
+	feeds := http.NewServeMux()
+	feeds.HandleFunc("/hub.xml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `<?xml version="1.0"?>
+<rss version="2.0" xmlns:atom="http://www.w3.org/2005/Atom"><channel>
+<title>Pushed</title><link>https://example.com/</link>
+<atom:link rel="hub" href="%s"/><atom:link rel="self" href="%s/hub.xml"/>
+<item><title>Item</title><link>https://example.com/1</link></item>
+</channel></rss>`, hubURL, feedURL)
+	})
+	feeds.HandleFunc("/plain.xml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<rss version="2.0"><channel><title>Polled</title></channel></rss>`)
+	})
+	feedServer := httptest.NewServer(feeds)
+	defer feedServer.Close()
+	feedURL = feedServer.URL
+
+	var subscriber *WebSubSubscriber
+	callbackServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		subscriber.ServeHTTP(w, r)
+	}))
+	defer callbackServer.Close()
+
+	// The hub verifies the intent synchronously and remembers the callback and secret
+	var callback, secret string
+	hub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Error(err)
+			return
+		}
+		callback, secret = r.PostForm.Get("hub.callback"), r.PostForm.Get("hub.secret")
+
+		verify := callback + "?" + url.Values{
+			"hub.mode":          {r.PostForm.Get("hub.mode")},
+			"hub.topic":         {r.PostForm.Get("hub.topic")},
+			"hub.challenge":     {"challenge-123"},
+			"hub.lease_seconds": {"600"},
+		}.Encode()
+		resp, err := http.Get(verify)
+		if err != nil {
+			t.Errorf("verification request failed: %v", err)
+			return
+		}
+		defer resp.Body.Close()
+
+		echoed, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != http.StatusOK || string(echoed) != "challenge-123" {
+			t.Errorf("verification = %d %q; want 200 with the challenge echoed", resp.StatusCode, echoed)
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer hub.Close()
+	hubURL = hub.URL
+
+	delivered := make(chan string, 1)
+	subscriber = NewWebSubSubscriber(&config.Config{
+		WebSubCallbackURL: callbackServer.URL + "/websub",
+		WebSubSecret:      "test-secret",
+		WebSubLease:       time.Hour,
+		RequestTimeout:    5 * time.Second,
+	}, func(feed config.FeedConfig, body []byte) {
+		delivered <- feed.Name + ": " + string(body)
+	})
+
+	hubFeed := config.FeedConfig{URL: feedServer.URL + "/hub.xml", Name: "Pushed"}
+	plainFeed := config.FeedConfig{URL: feedServer.URL + "/plain.xml", Name: "Polled"}
+
+	if got := subscriber.Subscribe(context.Background(), []config.FeedConfig{hubFeed, plainFeed}); got != 1 {
+		t.Fatalf("Subscribe() = %d; want 1 feed with a hub", got)
+	}
+	if !subscriber.IsActive(hubFeed.URL) {
+		t.Error("IsActive(hub feed) = false after verification; want true")
+	}
+	if subscriber.IsActive(plainFeed.URL) {
+		t.Error("IsActive(plain feed) = true; want it left to polling")
+	}
+
+	notify := func(target, signature string) int {
+		req, _ := http.NewRequest("POST", target, strings.NewReader("<rss/>"))
+		req.Header.Set("X-Hub-Signature", signature)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("<rss/>"))
+	valid := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	// A bad signature is acknowledged but never delivered
+	if status := notify(callback, "sha256=00"); status != http.StatusAccepted {
+		t.Errorf("notification with bad signature status = %d; want 202", status)
+	}
+	select {
+	case body := <-delivered:
+		t.Errorf("notification with bad signature delivered %q", body)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if status := notify(callback, valid); status != http.StatusAccepted {
+		t.Errorf("signed notification status = %d; want 202", status)
+	}
+	select {
+	case body := <-delivered:
+		if body != "Pushed: <rss/>" {
+			t.Errorf("delivered %q; want the pushed body", body)
+		}
+	case <-time.After(time.Second):
+		t.Error("signed notification was not delivered")
+	}
+
+	if status := notify(callbackServer.URL+"/websub/unknown", valid); status != http.StatusGone {
+		t.Errorf("notification for unknown subscription status = %d; want 410", status)
+	}
+
+	resp, err := http.Get(callback + "?hub.mode=subscribe&hub.topic=https://other.example/feed&hub.challenge=x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("verification for another topic status = %d; want 404", resp.StatusCode)
+	}
+}
+
+func TestParseLinkHeaders(t *testing.T) {
+	hub, self := parseLinkHeaders([]string{
+		`<https://example.com/feed>; rel="self", <https://hub.example.com/>; rel="hub"`,
+	})
+
+	if hub != "https://hub.example.com/" || self != "https://example.com/feed" {
+		t.Errorf("parseLinkHeaders() = %q, %q; want the hub and self links", hub, self)
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	body := []byte("payload")
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write(body)
+	signature := hex.EncodeToString(mac.Sum(nil))
+
+	tests := []struct {
+		header   string
+		expected bool
+	}{
+		{"sha256=" + signature, true},
+		{"SHA256=" + signature, true},
+		{"sha1=" + signature, false},
+		{"md5=" + signature, false},
+		{signature, false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if result := verifySignature(tt.header, "secret", body); result != tt.expected {
+			t.Errorf("verifySignature(%q) = %t; want %t", tt.header, result, tt.expected)
+		}
+	}
+}